@@ -66,6 +66,10 @@ type MutableStatement struct {
 	// Name is the name of the variable to which we're assigning
 	Name *Identifier
 
+	// Pattern is set instead of Name when destructuring, to an
+	// *ArrayPattern or *HashPattern
+	Pattern Expression
+
 	// Value is the thing we're storing in the variable.
 	Value Expression
 }
@@ -79,7 +83,11 @@ func (ls *MutableStatement) TokenLiteral() string { return ls.Token.Literal }
 func (ls *MutableStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.TokenLiteral())
+	if ls.Pattern != nil {
+		out.WriteString(ls.Pattern.String())
+	} else {
+		out.WriteString(ls.Name.TokenLiteral())
+	}
 	out.WriteString(" = ")
 	if ls.Value != nil {
 		out.WriteString(ls.Value.String())
@@ -97,6 +105,10 @@ type LetStatement struct {
 	// Name is the name of the variable we're setting
 	Name *Identifier
 
+	// Pattern is set instead of Name when destructuring, to an
+	// *ArrayPattern or *HashPattern
+	Pattern Expression
+
 	// Value contains the value which is to be set
 	Value Expression
 }
@@ -110,7 +122,11 @@ func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.TokenLiteral())
+	if ls.Pattern != nil {
+		out.WriteString(ls.Pattern.String())
+	} else {
+		out.WriteString(ls.Name.TokenLiteral())
+	}
 	out.WriteString(" = ")
 	if ls.Value != nil {
 		out.WriteString(ls.Value.String())
@@ -119,6 +135,27 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+// ExportStatement marks a top-level let-statement as part of a module's
+// public interface. Once a module exports anything, only exported names
+// are visible to importers.
+type ExportStatement struct {
+	// Token is the token
+	Token token.Token
+
+	// Statement is the declaration being exported
+	Statement *LetStatement
+}
+
+func (es *ExportStatement) statementNode() {}
+
+// TokenLiteral returns the literal token.
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+
+// String returns this object as a string.
+func (es *ExportStatement) String() string {
+	return es.TokenLiteral() + " " + es.Statement.String()
+}
+
 // Identifier holds a single identifier.
 type Identifier struct {
 	// Token is the literal token
@@ -300,6 +337,46 @@ func (pe *PostfixExpression) String() string {
 	return out.String()
 }
 
+// TryExpression holds `value?`, which returns the value from the function
+// it's in if it's an error.
+type TryExpression struct {
+	// Token is the '?' token
+	Token token.Token
+
+	// Value is the expression that might be an error
+	Value Expression
+}
+
+func (te *TryExpression) expressionNode() {}
+
+// TokenLiteral returns the literal token.
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+
+// String returns this object as a string.
+func (te *TryExpression) String() string {
+	return "(" + te.Value.String() + ")?"
+}
+
+// YieldExpression holds `yield value`, which pauses a generator, giving the
+// value to whatever's iterating over it.
+type YieldExpression struct {
+	// Token is the 'yield' token
+	Token token.Token
+
+	// Value is what's yielded
+	Value Expression
+}
+
+func (ye *YieldExpression) expressionNode() {}
+
+// TokenLiteral returns the literal token.
+func (ye *YieldExpression) TokenLiteral() string { return ye.Token.Literal }
+
+// String returns this object as a string.
+func (ye *YieldExpression) String() string {
+	return "yield " + ye.Value.String()
+}
+
 // NullLiteral represents a literal null
 type NullLiteral struct {
 	// Token holds the actual token
@@ -339,6 +416,9 @@ type BlockStatement struct {
 
 	// Statements contain the set of statements within the block
 	Statements []Statement
+
+	// Rbrace is the closing brace, if the block had braces
+	Rbrace token.Token
 }
 
 func (bs *BlockStatement) statementNode() {}
@@ -404,6 +484,9 @@ type ForeachStatement struct {
 	// Ident is the variable we'll set with each item, for the blocks' scope
 	Ident string
 
+	// Pattern is set instead of Ident when destructuring each item
+	Pattern Expression
+
 	// Value is the thing we'll range over.
 	Value Expression
 
@@ -420,7 +503,11 @@ func (fes *ForeachStatement) TokenLiteral() string { return fes.Token.Literal }
 func (fes *ForeachStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString("foreach ")
-	out.WriteString(fes.Ident)
+	if fes.Pattern != nil {
+		out.WriteString(fes.Pattern.String())
+	} else {
+		out.WriteString(fes.Ident)
+	}
 	out.WriteString(" ")
 	out.WriteString(fes.Value.String())
 	out.WriteString(fes.Body.String())
@@ -493,11 +580,19 @@ type FunctionLiteral struct {
 	// specified
 	Defaults map[string]Expression
 
+	// Patterns holds the parameters that destructure their argument,
+	// keyed by the parameter's name, which is the pattern as a string
+	Patterns map[string]Expression
+
 	// Body contains the set of statements within the function.
 	Body *BlockStatement
 
 	// DocString
 	DocString *DocStringLiteral
+
+	// Generator is true if the body yields, which makes calling the
+	// function return a generator instead of running it
+	Generator bool
 }
 
 func (fl *FunctionLiteral) expressionNode() {}
@@ -512,8 +607,7 @@ func (fl *FunctionLiteral) String() string {
 	for _, p := range fl.Parameters {
 		params = append(params, p.String())
 	}
-	out.WriteString(fl.TokenLiteral())
-	out.WriteString("(")
+	out.WriteString("fn(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
 	out.WriteString(fl.Body.String())
@@ -567,6 +661,9 @@ type CallExpression struct {
 
 	// Arguments are the arguments to be applied
 	Arguments []Expression
+
+	// Rparen is the closing paren
+	Rparen token.Token
 }
 
 func (ce *CallExpression) expressionNode() {}
@@ -582,6 +679,9 @@ func (ce *CallExpression) String() string {
 		args = append(args, a.String())
 	}
 	out.WriteString(ce.Function.String())
+	if Optional(ce) {
+		out.WriteString("?.")
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(args, ", "))
 	out.WriteString(")")
@@ -629,6 +729,9 @@ type ArrayLiteral struct {
 
 	// Elements holds the members of the array.
 	Elements []Expression
+
+	// Rbracket is the closing bracket
+	Rbracket token.Token
 }
 
 func (al *ArrayLiteral) expressionNode() {}
@@ -671,12 +774,115 @@ func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
 	out.WriteString(ie.Left.String())
+	if Optional(ie) {
+		out.WriteString("?.")
+	}
 	out.WriteString("[")
 	out.WriteString(ie.Index.String())
 	out.WriteString("])")
 	return out.String()
 }
 
+// Member returns true for a member access like a.b or a?.b, as opposed to
+// an index like a[b].
+func (ie *IndexExpression) Member() bool {
+	return ie.Token.Type == token.PERIOD ||
+		ie.Token.Type == token.QUESTION_PERIOD
+}
+
+// Root returns what a chain of member accesses and indexes like a.b[c].d
+// starts with, a in that case.
+func Root(e Expression) Expression {
+	for {
+		ie, ok := e.(*IndexExpression)
+		if !ok {
+			return e
+		}
+		e = ie.Left
+	}
+}
+
+// DottedName returns the name a chain of member accesses like fs.open or
+// core.test.skip spells out, and false if it's anything else.
+func DottedName(e Expression) (string, bool) {
+	switch e := e.(type) {
+	case *Identifier:
+		return e.Value, true
+	case *IndexExpression:
+		if e.Token.Type != token.PERIOD {
+			return "", false
+		}
+		left, ok := DottedName(e.Left)
+		if !ok {
+			return "", false
+		}
+		return left + "." + e.Index.(*StringLiteral).Value, true
+	}
+	return "", false
+}
+
+// Optional returns true for a member access, index, slice, or call written
+// with ?., like a?.b, a?.[i], or f?.(x). Those give null if what they're
+// used on is null, and so does the rest of the chain they start.
+func Optional(node Node) bool {
+	switch n := node.(type) {
+	case *IndexExpression:
+		return n.Token.Type == token.QUESTION_PERIOD ||
+			n.Token.Type == token.QUESTION_LBRACKET
+	case *SliceExpression:
+		return n.Token.Type == token.QUESTION_LBRACKET
+	case *CallExpression:
+		return n.Token.Type == token.QUESTION_LPAREN
+	}
+	return false
+}
+
+// SliceExpression holds a slice of an array or string, like xs[1:3],
+// s[-3:], or xs[::2].
+type SliceExpression struct {
+	// Token is the '[' token
+	Token token.Token
+
+	// Left is the thing being sliced
+	Left Expression
+
+	// Start, End, and Step are nil if they're left out
+	Start Expression
+	End   Expression
+	Step  Expression
+}
+
+func (se *SliceExpression) expressionNode() {}
+
+// TokenLiteral returns the literal token.
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+
+// String returns this object as a string.
+func (se *SliceExpression) String() string {
+	part := func(e Expression) string {
+		if e == nil {
+			return ""
+		}
+		return e.String()
+	}
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	if Optional(se) {
+		out.WriteString("?.")
+	}
+	out.WriteString("[")
+	out.WriteString(part(se.Start))
+	out.WriteString(":")
+	out.WriteString(part(se.End))
+	if se.Step != nil {
+		out.WriteString(":")
+		out.WriteString(se.Step.String())
+	}
+	out.WriteString("])")
+	return out.String()
+}
+
 // HashLiteral holds a hash definition
 type HashLiteral struct {
 	// Token holds the token
@@ -684,6 +890,9 @@ type HashLiteral struct {
 
 	// Pairs stores the name/value sets of the hash-content
 	Pairs map[Expression]Expression
+
+	// Rbrace is the closing brace
+	Rbrace token.Token
 }
 
 func (hl *HashLiteral) expressionNode() {}
@@ -711,9 +920,13 @@ func (hl *HashLiteral) String() string {
 // Specifically "x += y" is defined as an assignment-statement with
 // the operator set to "+=". The same applies for "+=", "-=", "*=", and
 // "/=".
+//
+// Assigning to an element, as in "xs[0] = y" or "h.k += 1", sets Target to
+// the index expression, and Name to the variable holding the array or hash.
 type AssignStatement struct {
 	Token    token.Token
 	Name     *Identifier
+	Target   *IndexExpression
 	Operator string
 	Value    Expression
 }
@@ -726,7 +939,11 @@ func (as *AssignStatement) TokenLiteral() string { return as.Token.Literal }
 // String returns this object as a string.
 func (as *AssignStatement) String() string {
 	var out bytes.Buffer
-	out.WriteString(as.Name.String())
+	if as.Target != nil {
+		out.WriteString(as.Target.String())
+	} else {
+		out.WriteString(as.Name.String())
+	}
 	out.WriteString(as.Operator)
 	out.WriteString(as.Value.String())
 	return out.String()
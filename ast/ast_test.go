@@ -26,3 +26,59 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestInspect(t *testing.T) {
+	ident := func(name string) *Identifier {
+		return &Identifier{
+			Token: token.Token{Type: token.IDENT, Literal: name},
+			Value: name,
+		}
+	}
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Name: ident("x"),
+				Value: &CallExpression{
+					Function:  ident("f"),
+					Arguments: []Expression{ident("a"), ident("b")},
+				},
+			},
+			&ExpressionStatement{
+				Expression: &FunctionLiteral{
+					Parameters: []*Identifier{ident("c")},
+					Body: &BlockStatement{
+						Statements: []Statement{
+							&ReturnStatement{ReturnValue: ident("d")},
+						},
+					},
+				},
+			},
+			// the parser produces these for some syntax errors
+			(*LetStatement)(nil),
+		},
+	}
+
+	names := ""
+	Inspect(program, func(n Node) bool {
+		if i, ok := n.(*Identifier); ok {
+			names += i.Value
+		}
+		return true
+	})
+	if names != "xfabcd" {
+		t.Errorf("wrong identifiers visited. got=%q", names)
+	}
+
+	// returning false skips children
+	names = ""
+	Inspect(program, func(n Node) bool {
+		if i, ok := n.(*Identifier); ok {
+			names += i.Value
+		}
+		_, isFn := n.(*FunctionLiteral)
+		return !isFn
+	})
+	if names != "xfab" {
+		t.Errorf("wrong identifiers visited. got=%q", names)
+	}
+}
@@ -0,0 +1,240 @@
+package ast
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/zautumnz/keai/token"
+)
+
+// ArrayPattern destructures an array, as in `let [a, b = 2, ...rest] = xs`.
+type ArrayPattern struct {
+	// Token is the '[' token
+	Token token.Token
+
+	// Elements are bound to the array's elements in order
+	Elements []*PatternElement
+
+	// Rest, if set, gets an array of the elements left over
+	Rest *Identifier
+}
+
+func (ap *ArrayPattern) expressionNode() {}
+
+// TokenLiteral returns the literal token.
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+
+// String returns this object as a string.
+func (ap *ArrayPattern) String() string {
+	elements := make([]string, 0)
+	for _, e := range ap.Elements {
+		elements = append(elements, e.String())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// HashPattern destructures a hash, as in `let {name, age: years} = person`.
+type HashPattern struct {
+	// Token is the '{' token
+	Token token.Token
+
+	// Pairs are bound to the values of their keys
+	Pairs []*PatternElement
+}
+
+func (hp *HashPattern) expressionNode() {}
+
+// TokenLiteral returns the literal token.
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+
+// String returns this object as a string.
+func (hp *HashPattern) String() string {
+	pairs := make([]string, 0)
+	for _, p := range hp.Pairs {
+		pairs = append(pairs, p.String())
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// PatternElement is one part of a pattern: the name, or nested pattern, a
+// value is bound to, and what to bind if the value is missing.
+type PatternElement struct {
+	// Key is the hash key to bind, in hash patterns
+	Key string
+
+	// Target is an *Identifier, *ArrayPattern, or *HashPattern
+	Target Expression
+
+	// Default is used when the array is too short or the hash doesn't
+	// have the key. This is optional.
+	Default Expression
+}
+
+// KeyPrefix returns the `key: ` before the target of an element of a hash
+// pattern, or nothing if the key is the target's name, as in `{name}`.
+func (pe *PatternElement) KeyPrefix() string {
+	if i, ok := pe.Target.(*Identifier); pe.Key == "" ||
+		(ok && i.Value == pe.Key) {
+		return ""
+	}
+	if isName(pe.Key) {
+		return pe.Key + ": "
+	}
+	return strconv.Quote(pe.Key) + ": "
+}
+
+// String returns this element as it'd be written in a pattern.
+func (pe *PatternElement) String() string {
+	var out bytes.Buffer
+	out.WriteString(pe.KeyPrefix())
+	out.WriteString(pe.Target.String())
+	if pe.Default != nil {
+		out.WriteString(" = ")
+		out.WriteString(pe.Default.String())
+	}
+	return out.String()
+}
+
+// isName returns true if s can be written as a bare key in hash patterns.
+func isName(s string) bool {
+	for i, r := range s {
+		isLetter := r == '_' || r == '$' ||
+			(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		if !isLetter && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// MatchExpression holds `match value { pattern => result, ... }`.
+type MatchExpression struct {
+	// Token is the 'match' token
+	Token token.Token
+
+	// Value is what's matched against the patterns
+	Value Expression
+
+	// Arms are tried in order, until one matches
+	Arms []*MatchArm
+
+	// Rbrace is the closing brace
+	Rbrace token.Token
+}
+
+func (me *MatchExpression) expressionNode() {}
+
+// TokenLiteral returns the literal token.
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+
+// String returns this object as a string.
+func (me *MatchExpression) String() string {
+	arms := make([]string, 0)
+	for _, a := range me.Arms {
+		arms = append(arms, a.String())
+	}
+	return "match " + me.Value.String() + " { " +
+		strings.Join(arms, ", ") + " }"
+}
+
+// MatchArm is one `pattern if guard => result` in a match.
+type MatchArm struct {
+	// Pattern is a literal, a range like 1..9, a type name like integer,
+	// _ to match anything, any other name to bind the value to, or an
+	// *ArrayPattern or *HashPattern made of patterns
+	Pattern Expression
+
+	// Guard has to be true too for the arm to match. This is optional.
+	Guard Expression
+
+	// Body is evaluated if the arm matches
+	Body *BlockStatement
+}
+
+// String returns this arm as a string.
+func (ma *MatchArm) String() string {
+	var out bytes.Buffer
+	out.WriteString(ma.Pattern.String())
+	if ma.Guard != nil {
+		out.WriteString(" if ")
+		out.WriteString(ma.Guard.String())
+	}
+	out.WriteString(" => ")
+	out.WriteString(ma.Body.String())
+	return out.String()
+}
+
+// TypePatterns are the names that match values of a type in a match, as
+// util.type names them.
+var TypePatterns = map[string]bool{
+	"array":     true,
+	"boolean":   true,
+	"builtin":   true,
+	"error":     true,
+	"file":      true,
+	"float":     true,
+	"function":  true,
+	"generator": true,
+	"hash":      true,
+	"integer":   true,
+	"iterator":  true,
+	"module":    true,
+	"range":     true,
+	"string":    true,
+}
+
+// MatchNames returns the identifiers the pattern of a match arm binds,
+// which are the names in it other than _ and type names.
+func MatchNames(pattern Expression) []*Identifier {
+	names := []*Identifier{}
+	for _, name := range PatternNames(pattern) {
+		if name.Value != "_" && !TypePatterns[name.Value] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// PatternNames returns the identifiers a pattern binds, in order. A plain
+// identifier binds just itself.
+func PatternNames(pattern Expression) []*Identifier {
+	names := []*Identifier{}
+	switch p := pattern.(type) {
+	case *Identifier:
+		names = append(names, p)
+	case *ArrayPattern:
+		for _, e := range p.Elements {
+			names = append(names, PatternNames(e.Target)...)
+		}
+		if p.Rest != nil {
+			names = append(names, p.Rest)
+		}
+	case *HashPattern:
+		for _, e := range p.Pairs {
+			names = append(names, PatternNames(e.Target)...)
+		}
+	}
+	return names
+}
+
+// Names returns the names a let binds: its name, or the names in its
+// pattern.
+func (ls *LetStatement) Names() []*Identifier {
+	if ls.Pattern != nil {
+		return PatternNames(ls.Pattern)
+	}
+	return []*Identifier{ls.Name}
+}
+
+// Names returns the names a mutable binds: its name, or the names in its
+// pattern.
+func (ls *MutableStatement) Names() []*Identifier {
+	if ls.Pattern != nil {
+		return PatternNames(ls.Pattern)
+	}
+	return []*Identifier{ls.Name}
+}
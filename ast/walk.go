@@ -0,0 +1,153 @@
+package ast
+
+import (
+	"reflect"
+
+	"github.com/zautumnz/keai/token"
+)
+
+// Inspect traverses the tree rooted at node in depth-first order, calling
+// f for each node. If f returns false, the children of that node are
+// skipped. Nil nodes are never passed to f.
+func Inspect(node Node, f func(Node) bool) {
+	if isNil(node) || !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, s := range n.Statements {
+			Inspect(s, f)
+		}
+	case *BlockStatement:
+		for _, s := range n.Statements {
+			Inspect(s, f)
+		}
+	case *MutableStatement:
+		Inspect(n.Name, f)
+		Inspect(n.Pattern, f)
+		Inspect(n.Value, f)
+	case *LetStatement:
+		Inspect(n.Name, f)
+		Inspect(n.Pattern, f)
+		Inspect(n.Value, f)
+	case *ExportStatement:
+		Inspect(n.Statement, f)
+	case *ReturnStatement:
+		Inspect(n.ReturnValue, f)
+	case *ExpressionStatement:
+		Inspect(n.Expression, f)
+	case *PrefixExpression:
+		Inspect(n.Right, f)
+	case *InfixExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *IfExpression:
+		Inspect(n.Condition, f)
+		Inspect(n.Consequence, f)
+		Inspect(n.Alternative, f)
+	case *ForeachStatement:
+		Inspect(n.Pattern, f)
+		Inspect(n.Value, f)
+		Inspect(n.Body, f)
+	case *ForLoopExpression:
+		Inspect(n.Condition, f)
+		Inspect(n.Consequence, f)
+	case *ImportExpression:
+		Inspect(n.Name, f)
+	case *FunctionLiteral:
+		Inspect(n.DocString, f)
+		for _, p := range n.Parameters {
+			if pattern, ok := n.Patterns[p.Value]; ok {
+				Inspect(pattern, f)
+			} else {
+				Inspect(p, f)
+			}
+			if d, ok := n.Defaults[p.Value]; ok {
+				Inspect(d, f)
+			}
+		}
+		Inspect(n.Body, f)
+	case *SpreadLiteral:
+		Inspect(n.Right, f)
+	case *TryExpression:
+		Inspect(n.Value, f)
+	case *YieldExpression:
+		Inspect(n.Value, f)
+	case *CallExpression:
+		Inspect(n.Function, f)
+		for _, a := range n.Arguments {
+			Inspect(a, f)
+		}
+	case *ArrayLiteral:
+		for _, e := range n.Elements {
+			Inspect(e, f)
+		}
+	case *IndexExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Index, f)
+	case *SliceExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Start, f)
+		Inspect(n.End, f)
+		Inspect(n.Step, f)
+	case *HashLiteral:
+		for k, v := range n.Pairs {
+			Inspect(k, f)
+			Inspect(v, f)
+		}
+	case *MatchExpression:
+		Inspect(n.Value, f)
+		for _, a := range n.Arms {
+			Inspect(a.Pattern, f)
+			Inspect(a.Guard, f)
+			Inspect(a.Body, f)
+		}
+	case *ArrayPattern:
+		for _, e := range n.Elements {
+			Inspect(e.Target, f)
+			Inspect(e.Default, f)
+		}
+		Inspect(n.Rest, f)
+	case *HashPattern:
+		for _, e := range n.Pairs {
+			Inspect(e.Target, f)
+			Inspect(e.Default, f)
+		}
+	case *AssignStatement:
+		if n.Target != nil {
+			Inspect(n.Target, f)
+		} else {
+			Inspect(n.Name, f)
+		}
+		Inspect(n.Value, f)
+	}
+}
+
+// isNil returns true for nil interfaces and for interfaces holding a nil
+// pointer, which the parser produces for some syntax errors.
+func isNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// TokenOf returns the token stored in a node, which has its position in the
+// source. Programs don't have one, so they get an empty token.
+func TokenOf(node Node) token.Token {
+	if isNil(node) {
+		return token.Token{}
+	}
+	v := reflect.Indirect(reflect.ValueOf(node))
+	if v.Kind() != reflect.Struct {
+		return token.Token{}
+	}
+	f := v.FieldByName("Token")
+	if !f.IsValid() {
+		return token.Token{}
+	}
+	t, _ := f.Interface().(token.Token)
+	return t
+}
@@ -0,0 +1,365 @@
+// Package bundle packs a keai program, the modules it imports, and the
+// files it reads into a copy of the keai executable. The result runs the
+// program on hosts that don't have keai installed.
+//
+// The files are stored in a zip archive appended to the executable,
+// followed by a trailer holding the archive's size and a magic string. At
+// startup keai checks its own executable for a trailer, and if there is
+// one it runs the bundled program, reading files from the archive through
+// io/fs like the embedded stdlib.
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zautumnz/keai/ast"
+	"github.com/zautumnz/keai/evaluator"
+	"github.com/zautumnz/keai/lexer"
+	"github.com/zautumnz/keai/parser"
+)
+
+const (
+	// magic ends every bundled executable.
+	magic = "KEAIBNDL"
+
+	// trailerSize is the size of the archive length plus magic.
+	trailerSize = 8 + len(magic)
+
+	// manifestName is the name of the manifest inside the archive.
+	manifestName = "bundle.json"
+
+	// mainName is the name of the program inside the archive.
+	mainName = "main.keai"
+)
+
+// Manifest describes a bundle.
+type Manifest struct {
+	// Program is the file the program was built from
+	Program string `json:"program"`
+
+	// Modules are the names of the bundled modules
+	Modules []string `json:"modules"`
+
+	// Assets are the paths of the bundled files
+	Assets []string `json:"assets"`
+}
+
+// Bundle is a program bundled into an executable.
+type Bundle struct {
+	Manifest
+
+	// FS holds the bundled files, see evaluator.SetBundle
+	FS fs.FS
+
+	// Main holds the source of the program
+	Main []byte
+}
+
+// Options configures Build.
+type Options struct {
+	// Program is the keai file to build
+	Program string
+
+	// Output is where to write the executable
+	Output string
+
+	// Assets are extra files or directories to bundle, for example the
+	// directory given to http's static()
+	Assets []string
+
+	// Runtime is the keai executable to copy; it defaults to the one
+	// that's running
+	Runtime string
+
+	// Log receives warnings, for example about imports which can't be
+	// bundled because their names aren't string literals
+	Log io.Writer
+}
+
+// collector gathers the files of a program.
+type collector struct {
+	files    map[string][]byte
+	manifest Manifest
+	log      io.Writer
+	err      error
+}
+
+// Build writes a standalone executable for a program. Every import with a
+// literal name is resolved the same way the interpreter resolves it, and
+// bundled along with its own imports. Templates passed to fs.tmpl as
+// literals are bundled, as are any extra assets.
+func Build(opts Options) error {
+	if opts.Log == nil {
+		opts.Log = ioutil.Discard
+	}
+
+	c := &collector{
+		files:    map[string][]byte{},
+		manifest: Manifest{Program: filepath.Base(opts.Program)},
+		log:      opts.Log,
+	}
+
+	src, err := ioutil.ReadFile(opts.Program)
+	if err != nil {
+		return err
+	}
+	c.files[mainName] = src
+	c.addProgram(src, opts.Program)
+	for _, a := range opts.Assets {
+		c.addAsset(a)
+	}
+	if c.err != nil {
+		return c.err
+	}
+
+	if opts.Runtime == "" {
+		opts.Runtime, err = os.Executable()
+		if err != nil {
+			return err
+		}
+	}
+	runtime, err := readRuntime(opts.Runtime)
+	if err != nil {
+		return err
+	}
+
+	archive, err := c.archive()
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	out.Write(runtime)
+	out.Write(archive)
+	binary.Write(&out, binary.LittleEndian, uint64(len(archive)))
+	out.WriteString(magic)
+
+	return ioutil.WriteFile(opts.Output, out.Bytes(), 0755)
+}
+
+// addProgram bundles the imports and templates used by a program.
+func (c *collector) addProgram(src []byte, filename string) {
+	l := lexer.New(string(src))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		c.fail(fmt.Errorf(
+			"error parsing %s:\n\t%s",
+			filename,
+			strings.Join(p.Errors(), "\n\t"),
+		))
+		return
+	}
+
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ImportExpression:
+			if name, ok := literal(n.Name); ok {
+				c.addModule(name)
+			} else {
+				fmt.Fprintf(
+					c.log,
+					"warning: %s: can't bundle import(%s), its name isn't a literal\n",
+					filename,
+					n.Name.String(),
+				)
+			}
+		case *ast.CallExpression:
+			fn, _ := ast.DottedName(n.Function)
+			if fn == "fs.tmpl" && len(n.Arguments) > 0 {
+				if path, ok := literal(n.Arguments[0]); ok {
+					c.addAsset(path)
+				}
+			}
+		}
+		return true
+	})
+}
+
+// literal returns the value of a string literal without interpolations.
+func literal(e ast.Expression) (string, bool) {
+	s, ok := e.(*ast.StringLiteral)
+	if !ok || strings.Contains(s.Value, "{{") {
+		return "", false
+	}
+	return s.Value, true
+}
+
+// addModule bundles an imported module and its own imports.
+func (c *collector) addModule(name string) {
+	p := evaluator.BundleModulePath(name)
+	if _, ok := c.files[p]; ok {
+		return
+	}
+
+	filename := evaluator.FindModule(name)
+	if filename == "" {
+		c.fail(fmt.Errorf("can't bundle module '%s': module not found", name))
+		return
+	}
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		c.fail(err)
+		return
+	}
+
+	c.files[p] = src
+	c.manifest.Modules = append(c.manifest.Modules, name)
+	c.addProgram(src, filename)
+}
+
+// addAsset bundles a file, or every file in a directory.
+func (c *collector) addAsset(path string) {
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		c.files[evaluator.BundleAssetPath(p)] = b
+		return nil
+	})
+	if err != nil {
+		c.fail(fmt.Errorf("can't bundle asset: %s", err))
+		return
+	}
+	c.manifest.Assets = append(c.manifest.Assets, path)
+}
+
+// fail records the first error.
+func (c *collector) fail(err error) {
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// archive zips up everything that was collected.
+func (c *collector) archive() ([]byte, error) {
+	m, err := json.MarshalIndent(c.manifest, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	c.files[manifestName] = m
+
+	names := []string{}
+	for name := range c.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range names {
+		f, err := w.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(c.files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readRuntime reads an executable, leaving off any bundle it already has,
+// so bundled programs can build other programs.
+func readRuntime(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if size, ok := archiveSize(b[max(0, len(b)-trailerSize):]); ok {
+		end := int64(len(b)) - int64(trailerSize) - size
+		if end >= 0 {
+			b = b[:end]
+		}
+	}
+	return b, nil
+}
+
+// archiveSize reads a trailer, returning false if it isn't one.
+func archiveSize(trailer []byte) (int64, bool) {
+	if len(trailer) != trailerSize || string(trailer[8:]) != magic {
+		return 0, false
+	}
+	return int64(binary.LittleEndian.Uint64(trailer[:8])), true
+}
+
+// max returns the larger of two ints.
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Open returns the bundle in an executable, or nil if it doesn't have one.
+// The executable is kept open for as long as the program runs.
+func Open(path string) (*Bundle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil || info.Size() < int64(trailerSize) {
+		f.Close()
+		return nil, err
+	}
+
+	trailer := make([]byte, trailerSize)
+	_, err = f.ReadAt(trailer, info.Size()-int64(trailerSize))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	size, ok := archiveSize(trailer)
+	if !ok {
+		f.Close()
+		return nil, nil
+	}
+
+	start := info.Size() - int64(trailerSize) - size
+	if start < 0 {
+		f.Close()
+		return nil, fmt.Errorf("corrupt bundle in %s", path)
+	}
+	zr, err := zip.NewReader(io.NewSectionReader(f, start, size), size)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	b := &Bundle{FS: zr}
+	m, err := fs.ReadFile(zr, manifestName)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := json.Unmarshal(m, &b.Manifest); err != nil {
+		f.Close()
+		return nil, err
+	}
+	b.Main, err = fs.ReadFile(zr, mainName)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return b, nil
+}
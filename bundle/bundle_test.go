@@ -0,0 +1,140 @@
+package bundle
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates files (relative path -> contents) under dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBuildAndOpen(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"keai.json": `{"name": "app"}`,
+		"keai_modules/greet/main.keai": `
+let words = import("greet/words")
+export let hi = fn(n) { words.hello + " " + n }`,
+		"keai_modules/greet/words.keai": `export let hello = "hi"`,
+		"app.keai": `
+let greet = import("greet")
+let dynamic = import("greet/{{name}}")
+print(greet.hi("there"), fs.tmpl("tpl/page.html"))`,
+		"tpl/page.html":      "<p>page</p>",
+		"static/index.html":  "<p>index</p>",
+		"static/css/app.css": "p {}",
+		"runtime":            "not really keai",
+	})
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	var log strings.Builder
+	opts := Options{
+		Program: "app.keai",
+		Output:  "app",
+		Assets:  []string{"static"},
+		Runtime: "runtime",
+		Log:     &log,
+	}
+	if err := Build(opts); err != nil {
+		t.Fatalf("error building: %s", err)
+	}
+	if !strings.Contains(log.String(), "can't bundle import") {
+		t.Errorf("expected a warning about the dynamic import, got %q",
+			log.String())
+	}
+
+	out, err := ioutil.ReadFile("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(out), "not really keai") {
+		t.Errorf("output doesn't start with the runtime")
+	}
+
+	b, err := Open("app")
+	if err != nil || b == nil {
+		t.Fatalf("error opening bundle: %v", err)
+	}
+	if b.Program != "app.keai" {
+		t.Errorf("wrong program. got=%q", b.Program)
+	}
+	if !strings.Contains(string(b.Main), `import("greet")`) {
+		t.Errorf("wrong main. got=%q", b.Main)
+	}
+
+	expected := map[string]string{
+		"modules/greet.keai":        "export let hi",
+		"modules/greet/words.keai":  `export let hello = "hi"`,
+		"assets/tpl/page.html":      "<p>page</p>",
+		"assets/static/index.html":  "<p>index</p>",
+		"assets/static/css/app.css": "p {}",
+	}
+	for name, contents := range expected {
+		got, err := fs.ReadFile(b.FS, name)
+		if err != nil {
+			t.Errorf("%s not bundled: %s", name, err)
+			continue
+		}
+		if !strings.Contains(string(got), contents) {
+			t.Errorf("wrong contents for %s. got=%q", name, got)
+		}
+	}
+
+	// building from a bundled executable leaves off its bundle
+	opts.Runtime = "app"
+	opts.Output = "app2"
+	if err := Build(opts); err != nil {
+		t.Fatalf("error rebuilding: %s", err)
+	}
+	out2, err := ioutil.ReadFile("app2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out2) != len(out) {
+		t.Errorf("rebuilt bundle has a different size. expected=%d, got=%d",
+			len(out), len(out2))
+	}
+
+	if b, err := Open("runtime"); b != nil || err != nil {
+		t.Errorf("expected no bundle in a plain executable, got %v, %v", b, err)
+	}
+}
+
+func TestBuildMissingModule(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"app.keai": `let x = import("does/not/exist")`,
+		"runtime":  "",
+	})
+
+	err := Build(Options{
+		Program: filepath.Join(dir, "app.keai"),
+		Output:  filepath.Join(dir, "app"),
+		Runtime: filepath.Join(dir, "runtime"),
+	})
+	if err == nil || !strings.Contains(err.Error(), "module not found") {
+		t.Errorf("expected a module not found error, got %v", err)
+	}
+}
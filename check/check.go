@@ -0,0 +1,614 @@
+// Package check implements keai check, which looks for mistakes that would
+// otherwise only show up when a program runs. It resolves names the way
+// object.Environment does: functions and foreach loops get their own
+// scopes, while if and for blocks share the scope they're in.
+//
+// Function bodies run later than the code around them, so they're checked
+// once the scope they're defined in is complete, which lets a function
+// call another one that's defined after it.
+package check
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/zautumnz/keai/ast"
+	"github.com/zautumnz/keai/lexer"
+	"github.com/zautumnz/keai/parser"
+	"github.com/zautumnz/keai/token"
+)
+
+// Problem is a mistake found in a file.
+type Problem struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+// String returns the problem as file:line:column: message.
+func (p Problem) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", p.File, p.Line, p.Column, p.Message)
+}
+
+// Checker checks programs. It knows the names every program can use
+// without defining them.
+type Checker struct {
+	globals map[string]bool
+
+	// namespaces holds what globals like fs.open are in, like fs
+	namespaces map[string]bool
+}
+
+// New returns a Checker that knows about the given builtins and the
+// top-level lets in the stdlib programs.
+func New(builtins []string, stdlib ...*ast.Program) *Checker {
+	c := &Checker{globals: map[string]bool{}, namespaces: map[string]bool{}}
+	for _, name := range builtins {
+		c.globals[name] = true
+	}
+	for _, program := range stdlib {
+		for _, s := range program.Statements {
+			if e, ok := s.(*ast.ExportStatement); ok {
+				s = e.Statement
+			}
+			if l, ok := s.(*ast.LetStatement); ok {
+				for _, name := range l.Names() {
+					c.globals[name.Value] = true
+				}
+			}
+		}
+	}
+	for name := range c.globals {
+		for i := strings.LastIndex(name, "."); i > 0; {
+			name = name[:i]
+			c.namespaces[name] = true
+			i = strings.LastIndex(name, ".")
+		}
+	}
+	return c
+}
+
+// Source parses and checks a file, returning an error if it doesn't
+// parse.
+func (c *Checker) Source(file string, src []byte) ([]Problem, error) {
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf(
+			"parse errors:\n\t%s",
+			strings.Join(p.Errors(), "\n\t"),
+		)
+	}
+	return c.Check(file, program), nil
+}
+
+// walk resolves every name in a program.
+func (c *Checker) walk(file string, program *ast.Program) *walker {
+	w := &walker{Checker: c, file: file}
+	top := w.newScope(nil)
+	top.top = true
+	w.statements(program.Statements, top)
+
+	// function bodies can queue more function bodies
+	for len(w.pending) > 0 {
+		f := w.pending[0]
+		w.pending = w.pending[1:]
+		f()
+	}
+	return w
+}
+
+// Check checks a program, returning its problems sorted by position.
+func (c *Checker) Check(file string, program *ast.Program) []Problem {
+	w := c.walk(file, program)
+
+	for _, s := range w.scopes {
+		if s.top {
+			continue
+		}
+		for _, b := range s.names {
+			if b.used || strings.HasPrefix(b.name, "_") {
+				continue
+			}
+			if b.kind == parameter {
+				w.report(b.tok, "parameter %s is unused", b.name)
+			} else {
+				w.report(b.tok, "%s declared and not used", b.name)
+			}
+		}
+	}
+
+	sort.SliceStable(w.problems, func(i, j int) bool {
+		a, b := w.problems[i], w.problems[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return w.problems
+}
+
+// Definition is where a name used in a program was declared.
+type Definition struct {
+	// Use is where the name was used, or declared
+	Use token.Token
+
+	// Token is the name in the declaration
+	Token token.Token
+
+	// Node is what declared it: an *ast.LetStatement, *ast.MutableStatement,
+	// *ast.ForeachStatement, or the *ast.FunctionLiteral for a parameter
+	Node ast.Node
+}
+
+// Definitions returns the declaration of every name in a program that's
+// declared in it, in the order they're found. Globals aren't included.
+func (c *Checker) Definitions(program *ast.Program) []Definition {
+	return c.walk("", program).definitions
+}
+
+// kinds of binding
+const (
+	constant  = "let"
+	mutable   = "mutable"
+	parameter = "parameter"
+	variable  = "foreach variable"
+)
+
+// binding is a name defined in a scope.
+type binding struct {
+	name string
+	kind string
+	tok  token.Token
+	node ast.Node
+	used bool
+}
+
+// scope mirrors an object.Environment.
+type scope struct {
+	names map[string]*binding
+	outer *scope
+
+	// top is true for the top level of a file
+	top bool
+
+	// permit is set for a foreach scope, which only holds the loop
+	// variables; anything else set in it is set in the outer scope
+	permit []string
+}
+
+// walker holds the state of one Check.
+type walker struct {
+	*Checker
+	file     string
+	problems []Problem
+	scopes   []*scope
+
+	// pending holds function bodies to check later
+	pending []func()
+
+	// quiet stops reports, for names used in string interpolation,
+	// which may not be meant to be variables at all
+	quiet bool
+
+	definitions []Definition
+}
+
+func (w *walker) report(tok token.Token, format string, a ...interface{}) {
+	if w.quiet {
+		return
+	}
+	w.problems = append(w.problems, Problem{
+		File:    w.file,
+		Line:    tok.Line,
+		Column:  tok.Column,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+// define records that tok refers to b.
+func (w *walker) define(tok token.Token, b *binding) {
+	if !w.quiet && b.node != nil {
+		w.definitions = append(w.definitions, Definition{
+			Use:   tok,
+			Token: b.tok,
+			Node:  b.node,
+		})
+	}
+}
+
+func (w *walker) newScope(outer *scope) *scope {
+	s := &scope{names: map[string]*binding{}, outer: outer}
+	w.scopes = append(w.scopes, s)
+	return s
+}
+
+// lookup finds the binding for a name, like Environment.Get.
+func (s *scope) lookup(name string) *binding {
+	for ; s != nil; s = s.outer {
+		if b, ok := s.names[name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+// declare adds a binding to a scope, reporting if it shadows one in an
+// outer scope.
+func (w *walker) declare(
+	s *scope,
+	name string,
+	kind string,
+	tok token.Token,
+	node ast.Node,
+) *binding {
+	if outer := s.outer.lookup(name); outer != nil && outer.tok.Line > 0 {
+		w.report(
+			tok,
+			"%s shadows the declaration on line %d",
+			name,
+			outer.tok.Line,
+		)
+	}
+	b := &binding{name: name, kind: kind, tok: tok, node: node}
+	s.names[name] = b
+	w.define(tok, b)
+	return b
+}
+
+// use marks a name as used, reporting it if it isn't defined.
+func (w *walker) use(s *scope, name string, tok token.Token) {
+	if b := s.lookup(name); b != nil {
+		b.used = true
+		w.define(tok, b)
+		return
+	}
+	if w.globals[name] || w.namespace(s, name) {
+		return
+	}
+	if strings.Contains(name, ".") {
+		w.report(tok, "unknown builtin: %s", name)
+	} else {
+		w.report(tok, "undefined: %s", name)
+	}
+}
+
+// dotted returns the name a member access like fs.open spells out, and a
+// token spanning it, if it should be used as one name. That's the case
+// when it starts with a name that isn't bound, and either it's bound
+// itself or what it's in is a namespace, so fs.nope is an unknown builtin
+// but sys.STDOUT.write is write on sys.STDOUT.
+func (w *walker) dotted(
+	n *ast.IndexExpression,
+	s *scope,
+) (string, token.Token, bool) {
+	name, ok := ast.DottedName(n)
+	if !ok {
+		return "", token.Token{}, false
+	}
+	root := ast.Root(n).(*ast.Identifier)
+	if s.lookup(root.Value) != nil || w.globals[root.Value] {
+		return "", token.Token{}, false
+	}
+	tok := root.Token
+	tok.Literal = name
+	member := n.Index.(*ast.StringLiteral).Token
+	tok.EndLine, tok.EndColumn = member.EndLine, member.EndColumn
+	if s.lookup(name) != nil || w.globals[name] {
+		return name, tok, true
+	}
+	return name, tok, w.namespace(s, name[:strings.LastIndex(name, ".")])
+}
+
+// namespaceLet checks `let x.y = ...`, which only works if x isn't bound,
+// like evaluator.checkNamespaceLet.
+func (w *walker) namespaceLet(s *scope, name *ast.Identifier) {
+	i := strings.Index(name.Value, ".")
+	if i < 0 {
+		return
+	}
+	root := name.Value[:i]
+	if s.lookup(root) != nil || w.globals[root] {
+		w.report(
+			name.Token,
+			"can't add %s to %s, which isn't a namespace",
+			name.Value[i+1:],
+			root,
+		)
+	}
+}
+
+// namespace returns true if name is something like fs, which holds
+// builtins or lets like fs.open, in the stdlib or a scope.
+func (w *walker) namespace(s *scope, name string) bool {
+	if w.namespaces[name] {
+		return true
+	}
+	for ; s != nil; s = s.outer {
+		for key := range s.names {
+			if strings.HasPrefix(key, name+".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// assign checks setting an existing name with = or an operator like += or
+// ++.
+func (w *walker) assign(s *scope, name string, tok token.Token) {
+	b := s.lookup(name)
+	if b == nil {
+		if !w.globals[name] {
+			w.report(tok, "undefined: %s", name)
+		}
+		return
+	}
+	w.define(tok, b)
+	if b.kind == constant {
+		w.report(
+			tok,
+			"cannot assign to constant %s (declared on line %d)",
+			name,
+			b.tok.Line,
+		)
+	}
+}
+
+// mutable checks a mutable statement, which works like Environment.Set:
+// it sets the name in the scope or the one just outside it if it's already
+// there, and otherwise defines it.
+func (w *walker) mutable(
+	s *scope,
+	ident *ast.Identifier,
+	stmt *ast.MutableStatement,
+) {
+	name, tok := ident.Value, ident.Token
+	for len(s.permit) > 0 && !contains(s.permit, name) {
+		s = s.outer
+	}
+
+	if s.top {
+		w.report(tok, "mutable %s at the top level; use let", name)
+	}
+
+	for _, in := range []*scope{s, s.outer} {
+		if in == nil {
+			continue
+		}
+		if b, ok := in.names[name]; ok {
+			w.define(tok, b)
+			if b.kind == constant {
+				w.report(
+					tok,
+					"cannot assign to constant %s (declared on line %d)",
+					name,
+					b.tok.Line,
+				)
+			}
+			return
+		}
+	}
+	w.declare(s, name, mutable, tok, stmt)
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// statements checks a list of statements, reporting the first one after
+// a return.
+func (w *walker) statements(stmts []ast.Statement, s *scope) {
+	returned := false
+	for _, stmt := range stmts {
+		if returned {
+			w.report(ast.TokenOf(stmt), "unreachable code")
+			returned = false
+		}
+		w.node(stmt, s)
+		if _, ok := stmt.(*ast.ReturnStatement); ok {
+			returned = true
+		}
+	}
+}
+
+// interpolation matches the {{...}} parts of a string.
+var interpolation = regexp.MustCompile(`(?s)(\\)?\{\{(.*?)\}\}`)
+
+func (w *walker) node(node ast.Node, s *scope) {
+	switch n := node.(type) {
+	case nil:
+	case *ast.Program:
+		w.statements(n.Statements, s)
+	case *ast.BlockStatement:
+		if n != nil {
+			w.statements(n.Statements, s)
+		}
+	case *ast.ExpressionStatement:
+		w.node(n.Expression, s)
+	case *ast.ReturnStatement:
+		w.node(n.ReturnValue, s)
+	case *ast.ExportStatement:
+		w.node(n.Statement, s)
+	case *ast.LetStatement:
+		w.node(n.Value, s)
+		if n.Name != nil {
+			w.namespaceLet(s, n.Name)
+		}
+		for _, name := range n.Names() {
+			w.declare(s, name.Value, constant, name.Token, n)
+		}
+		w.defaults(n.Pattern, s)
+	case *ast.MutableStatement:
+		w.node(n.Value, s)
+		for _, name := range n.Names() {
+			w.mutable(s, name, n)
+		}
+		w.defaults(n.Pattern, s)
+	case *ast.AssignStatement:
+		if n.Target != nil {
+			// the array or hash is read, and the indexes used
+			w.node(n.Target, s)
+		} else if n.Operator != "=" {
+			w.use(s, n.Name.Value, n.Name.Token)
+		}
+		w.node(n.Value, s)
+		w.assign(s, n.Name.Value, n.Name.Token)
+	case *ast.PostfixExpression:
+		w.use(s, n.Token.Literal, n.Token)
+		w.assign(s, n.Token.Literal, n.Token)
+	case *ast.Identifier:
+		w.use(s, n.Value, n.Token)
+	case *ast.StringLiteral:
+		w.interpolated(n.Value, s)
+	case *ast.PrefixExpression:
+		w.node(n.Right, s)
+	case *ast.InfixExpression:
+		w.node(n.Left, s)
+		w.node(n.Right, s)
+	case *ast.IfExpression:
+		w.node(n.Condition, s)
+		w.node(n.Consequence, s)
+		if n.Alternative != nil {
+			w.node(n.Alternative, s)
+		}
+	case *ast.ForLoopExpression:
+		w.node(n.Condition, s)
+		w.node(n.Consequence, s)
+	case *ast.ForeachStatement:
+		w.node(n.Value, s)
+		loop := w.newScope(s)
+		if n.Pattern != nil {
+			for _, name := range ast.PatternNames(n.Pattern) {
+				loop.permit = append(loop.permit, name.Value)
+				w.declare(loop, name.Value, variable, name.Token, n)
+			}
+		} else {
+			loop.permit = []string{n.Ident}
+			w.declare(loop, n.Ident, variable, n.Token, n)
+		}
+		if n.Index != "" {
+			loop.permit = append(loop.permit, n.Index)
+			w.declare(loop, n.Index, variable, n.Token, n)
+		}
+		w.defaults(n.Pattern, loop)
+		w.node(n.Body, loop)
+	case *ast.MatchExpression:
+		w.node(n.Value, s)
+		for _, arm := range n.Arms {
+			scope := w.newScope(s)
+			for _, name := range ast.MatchNames(arm.Pattern) {
+				scope.permit = append(scope.permit, name.Value)
+				w.declare(scope, name.Value, constant, name.Token, n)
+			}
+			w.defaults(arm.Pattern, scope)
+			w.node(arm.Guard, scope)
+			w.node(arm.Body, scope)
+		}
+	case *ast.FunctionLiteral:
+		w.pending = append(w.pending, func() {
+			w.function(n, s)
+		})
+	case *ast.ImportExpression:
+		w.node(n.Name, s)
+	case *ast.CallExpression:
+		w.node(n.Function, s)
+		for _, a := range n.Arguments {
+			w.node(a, s)
+		}
+	case *ast.ArrayLiteral:
+		for _, e := range n.Elements {
+			w.node(e, s)
+		}
+	case *ast.HashLiteral:
+		for k, v := range n.Pairs {
+			w.node(k, s)
+			w.node(v, s)
+		}
+	case *ast.IndexExpression:
+		if name, tok, ok := w.dotted(n, s); ok {
+			w.use(s, name, tok)
+			return
+		}
+		w.node(n.Left, s)
+		if n.Token.Type != token.PERIOD &&
+			n.Token.Type != token.QUESTION_PERIOD {
+			w.node(n.Index, s)
+		}
+	case *ast.SliceExpression:
+		w.node(n.Left, s)
+		w.node(n.Start, s)
+		w.node(n.End, s)
+		w.node(n.Step, s)
+	case *ast.SpreadLiteral:
+		w.node(n.Right, s)
+	case *ast.TryExpression:
+		w.node(n.Value, s)
+	case *ast.YieldExpression:
+		w.node(n.Value, s)
+	}
+}
+
+// function checks the body of a function literal defined in s.
+func (w *walker) function(fn *ast.FunctionLiteral, s *scope) {
+	body := w.newScope(s)
+	// methods get self; it's fine for other functions not to use it
+	body.names["self"] = &binding{name: "self", used: true}
+	for _, p := range fn.Parameters {
+		if pattern, ok := fn.Patterns[p.Value]; ok {
+			for _, name := range ast.PatternNames(pattern) {
+				w.declare(body, name.Value, parameter, name.Token, fn)
+			}
+		} else {
+			w.declare(body, p.Value, parameter, p.Token, fn)
+		}
+	}
+	for _, p := range fn.Parameters {
+		if d, ok := fn.Defaults[p.Value]; ok {
+			w.node(d, body)
+		}
+		w.defaults(fn.Patterns[p.Value], body)
+	}
+	w.node(fn.Body, body)
+}
+
+// defaults checks the default values in a pattern, if there is one.
+func (w *walker) defaults(pattern ast.Expression, s *scope) {
+	var elements []*ast.PatternElement
+	switch p := pattern.(type) {
+	case *ast.ArrayPattern:
+		elements = p.Elements
+	case *ast.HashPattern:
+		elements = p.Pairs
+	}
+	for _, e := range elements {
+		w.node(e.Default, s)
+		w.defaults(e.Target, s)
+	}
+}
+
+// interpolated marks the names used in a string's {{...}} parts.
+func (w *walker) interpolated(str string, s *scope) {
+	for _, m := range interpolation.FindAllStringSubmatch(str, -1) {
+		if m[1] != "" {
+			continue
+		}
+		p := parser.New(lexer.New(m[2]))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			continue
+		}
+		quiet := w.quiet
+		w.quiet = true
+		w.node(program, s)
+		w.quiet = quiet
+	}
+}
@@ -0,0 +1,136 @@
+package check
+
+import (
+	"testing"
+
+	"github.com/zautumnz/keai/lexer"
+	"github.com/zautumnz/keai/parser"
+)
+
+func TestCheck(t *testing.T) {
+	stdlib := parser.New(lexer.New("let string.shout = fn () { self }"))
+	c := New([]string{"print", "fs.open"}, stdlib.ParseProgram())
+
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1\nprint(x, string.shout)", nil},
+		{"print(y)", []string{"f.keai:1:7: undefined: y"}},
+		{"fs.nope()", []string{"f.keai:1:1: unknown builtin: fs.nope"}},
+		{"print(fs, fs.open.name, string)", nil},
+		{"nope.x", []string{"f.keai:1:1: undefined: nope"}},
+		{"let app.x = 1\nprint(app.x, app.y)", []string{
+			"f.keai:2:14: unknown builtin: app.y",
+		}},
+		{"let h = {}\nlet h.k = 1", []string{
+			"f.keai:2:5: can't add k to h, which isn't a namespace",
+		}},
+		{"let print.k = 1", []string{
+			"f.keai:1:5: can't add k to print, which isn't a namespace",
+		}},
+		{
+			"let x = 1\nx = 2\nx += 1",
+			[]string{
+				"f.keai:2:1: cannot assign to constant x (declared on line 1)",
+				"f.keai:3:1: cannot assign to constant x (declared on line 1)",
+			},
+		},
+		{
+			"mutable x = 1",
+			[]string{"f.keai:1:9: mutable x at the top level; use let"},
+		},
+		{
+			"let f = fn (a, _b) {\n    let c = 1\n}",
+			[]string{
+				"f.keai:1:13: parameter a is unused",
+				"f.keai:2:9: c declared and not used",
+			},
+		},
+		{
+			"let x = 1\nlet f = fn (x) { x }",
+			[]string{"f.keai:2:13: x shadows the declaration on line 1"},
+		},
+		{
+			"let f = fn () {\n    return 1\n    print(2)\n}",
+			[]string{"f.keai:3:5: unreachable code"},
+		},
+		// function bodies can use names defined after them
+		{"let f = fn () { g() }\nlet g = fn () { f() }", nil},
+		// foreach only keeps its own variables; n is the outer n
+		{
+			`let f = fn () {
+    mutable n = 0
+    foreach i, x in [1] {
+        mutable n = x
+        n++
+    }
+    return "{{n}}"
+}`,
+			[]string{"f.keai:3:5: i declared and not used"},
+		},
+		{
+			"let f = fn ([a, b], {c: d}) {\n    let [e, ...g] = [a]\n    g\n}",
+			[]string{
+				"f.keai:1:17: parameter b is unused",
+				"f.keai:1:25: parameter d is unused",
+				"f.keai:2:10: e declared and not used",
+			},
+		},
+		{
+			`let xs = [1]
+let f = fn (i) {
+    mutable h = {}
+    h[i] = 1
+    xs[0] = 2
+}`,
+			[]string{
+				"f.keai:5:5: cannot assign to constant xs (declared on line 1)",
+			},
+		},
+		{
+			"let f = fn (v) {\n    match v {\n        [a, b] => a,\n        _ => b,\n    }\n}",
+			[]string{
+				"f.keai:3:13: b declared and not used",
+				"f.keai:4:14: undefined: b",
+			},
+		},
+		{
+			"let [x, y = x] = [1]\nforeach {n} in [] { print(n, y) }",
+			nil,
+		},
+		{
+			"let f = fn () {\n    let n = 0\n    n++\n}",
+			[]string{
+				"f.keai:3:5: cannot assign to constant n (declared on line 2)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		problems, err := c.Source("f.keai", []byte(tt.input))
+		if err != nil {
+			t.Fatalf("error checking %q: %s", tt.input, err)
+		}
+		got := []string{}
+		for _, p := range problems {
+			got = append(got, p.String())
+		}
+		if len(got) != len(tt.expected) {
+			t.Errorf("wrong problems for %q. expected=%q, got=%q",
+				tt.input, tt.expected, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.expected[i] {
+				t.Errorf("wrong problems for %q. expected=%q, got=%q",
+					tt.input, tt.expected, got)
+				break
+			}
+		}
+	}
+
+	if _, err := c.Source("f.keai", []byte("let = 1")); err == nil {
+		t.Errorf("expected an error for a program that doesn't parse")
+	}
+}
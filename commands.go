@@ -0,0 +1,732 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zautumnz/keai/ast"
+	"github.com/zautumnz/keai/bundle"
+	"github.com/zautumnz/keai/check"
+	"github.com/zautumnz/keai/debugger"
+	"github.com/zautumnz/keai/doc"
+	"github.com/zautumnz/keai/evaluator"
+	"github.com/zautumnz/keai/format"
+	"github.com/zautumnz/keai/lexer"
+	"github.com/zautumnz/keai/lsp"
+	"github.com/zautumnz/keai/parser"
+	"github.com/zautumnz/keai/pkgmgr"
+	"github.com/zautumnz/keai/repl"
+	"github.com/zautumnz/keai/testrunner"
+	"github.com/zautumnz/keai/utils"
+)
+
+// command is a keai subcommand, like `keai run`.
+type command struct {
+	name  string
+	usage string
+	desc  string
+	run   func(args []string) int
+}
+
+// commands is set in init, since help refers to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{
+			"run",
+			"[-e code] [--trace-imports] [--coverage] [--profile out.pprof] " +
+				"[--timings] [program.keai | -] [--] [args...]",
+			"Run a program, code given with -e, or a program read from stdin",
+			runCmd,
+		},
+		{"repl", "", "Start the REPL", replCmd},
+		{
+			"test",
+			"[--format human|tap|junit] [--timeout duration] [--coverage] " +
+				"[paths...]",
+			"Run the tests in *_test.keai files",
+			testCmd,
+		},
+		{
+			"build",
+			"program.keai [-o output] [-a asset]...",
+			"Build a standalone executable",
+			buildCmd,
+		},
+		{
+			"fmt",
+			"[--check] [--diff] [paths...]",
+			"Format programs, or the program on stdin if no paths are given",
+			fmtCmd,
+		},
+		{
+			"check",
+			"[paths...]",
+			"Report likely mistakes in programs, without running them",
+			checkCmd,
+		},
+		{"lsp", "", "Start a language server on stdio", lspCmd},
+		{
+			"debug",
+			"[--dap] [program.keai [args...]]",
+			"Debug a program, or serve the Debug Adapter Protocol on stdio",
+			debugCmd,
+		},
+		{
+			"doc",
+			"[--format markdown|html] [module | file.keai | namespace]",
+			"Show the documentation for a module, a file, or the builtins " +
+				"and stdlib",
+			docCmd,
+		},
+		{"install", "", "Install the dependencies in keai.json", installCmd},
+		{"version", "", "Show our version", versionCmd},
+		{"help", "", "Show this help", helpCmd},
+	}
+}
+
+// findCommand returns the command with a name, or nil.
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// newFlagSet returns a flag set for a command that prints its usage.
+func newFlagSet(name string) *flag.FlagSet {
+	fl := flag.NewFlagSet(name, flag.ContinueOnError)
+	fl.Usage = func() {
+		cmd := findCommand(name)
+		fmt.Fprintf(fl.Output(), "Usage: keai %s %s\n\n%s\n",
+			cmd.name, cmd.usage, cmd.desc)
+		fl.PrintDefaults()
+	}
+	return fl
+}
+
+// parseFlags parses flags, returning the exit code to use if the command
+// shouldn't go on.
+func parseFlags(fl *flag.FlagSet, args []string) (int, bool) {
+	err := fl.Parse(args)
+	if err == flag.ErrHelp {
+		return 0, false
+	}
+	if err != nil {
+		return 2, false
+	}
+	return 0, true
+}
+
+// addCoverageFlags adds the coverage flags to a command.
+func addCoverageFlags(fl *flag.FlagSet) (*bool, *string) {
+	enabled := fl.Bool(
+		"coverage",
+		false,
+		"Record which statements and branches run, including the stdlib",
+	)
+	profile := fl.String(
+		"coverprofile",
+		"coverage.out",
+		"Where to write the coverage profile; the HTML report goes next to it",
+	)
+	return enabled, profile
+}
+
+// startCoverage starts recording coverage, returning a function that stops
+// and writes the profile and HTML report. Exiting early writes them too.
+func startCoverage(profile string) func() {
+	cov := evaluator.NewCoverage()
+	evaluator.SetCoverage(cov)
+
+	done := func() {
+		evaluator.SetCoverage(nil)
+		utils.ExitHandler = nil
+
+		report := strings.TrimSuffix(profile, filepath.Ext(profile)) + ".html"
+		for path, write := range map[string]func(io.Writer) error{
+			profile: cov.WriteProfile,
+			report:  cov.WriteHTML,
+		} {
+			f, err := os.Create(path)
+			if err == nil {
+				err = write(f)
+				f.Close()
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing coverage: %s\n", err)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%s (%s, %s)\n", cov, profile, report)
+	}
+
+	utils.ExitHandler = func(code int) {
+		done()
+		os.Exit(code)
+	}
+	return done
+}
+
+// startProfile starts profiling, returning a function that stops and writes
+// the profile and timings. Exiting early writes them too.
+func startProfile(path string, timings bool) func() {
+	p := evaluator.NewProfiler(10 * time.Millisecond)
+	evaluator.SetProfiler(p)
+	prev := utils.ExitHandler
+
+	done := func() {
+		evaluator.SetProfiler(nil)
+		utils.ExitHandler = prev
+
+		if path != "" {
+			f, err := os.Create(path)
+			if err == nil {
+				err = p.WriteProfile(f)
+				f.Close()
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing profile: %s\n", err)
+			}
+		}
+		if timings {
+			p.WriteTimings(os.Stderr)
+		}
+	}
+
+	utils.ExitHandler = func(code int) {
+		done()
+		if prev != nil {
+			prev(code)
+		}
+		os.Exit(code)
+	}
+	return done
+}
+
+// Run a program. The program and everything after it, or everything after
+// a --, belong to the program.
+func runCmd(args []string) int {
+	fl := newFlagSet("run")
+	evalDesc := "Code to execute"
+	eval := fl.String("eval", "", evalDesc)
+	fl.StringVar(eval, "e", "", evalDesc)
+	versDesc := "Show our version and exit"
+	vers := fl.Bool("version", false, versDesc)
+	fl.BoolVar(vers, "v", false, versDesc)
+	traceImports := fl.Bool(
+		"trace-imports",
+		false,
+		"Print the path each import resolves to",
+	)
+	coverage, profile := addCoverageFlags(fl)
+	pprof := fl.String(
+		"profile",
+		"",
+		"Write a pprof profile of the keai functions and lines that ran",
+	)
+	timings := fl.Bool(
+		"timings",
+		false,
+		"Print how many times each function was called and how long it took",
+	)
+	if code, ok := parseFlags(fl, args); !ok {
+		return code
+	}
+	evaluator.TraceImports = *traceImports
+
+	// Showing the version?
+	if *vers {
+		return versionCmd(nil)
+	}
+
+	rest := fl.Args()
+	var name string
+	var input []byte
+	var err error
+
+	switch {
+	case *eval != "":
+		// Executing code? Then everything else is an argument.
+		name = "-e"
+		input = []byte(*eval)
+	case len(rest) == 0:
+		fl.Usage()
+		return 2
+	default:
+		name, rest = rest[0], rest[1:]
+		if len(rest) > 0 && rest[0] == "--" {
+			rest = rest[1:]
+		}
+		if name == "-" {
+			input, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			input, err = ioutil.ReadFile(name)
+		}
+		if err != nil {
+			fmt.Printf("Error reading: %s\n", err.Error())
+			return 1
+		}
+	}
+
+	evaluator.SetArgs(append([]string{name}, rest...))
+	if *coverage {
+		defer startCoverage(*profile)()
+	}
+	if *pprof != "" || *timings {
+		defer startProfile(*pprof, *timings)()
+	}
+	return Execute(name, string(input))
+}
+
+// Start the REPL.
+func replCmd(args []string) int {
+	fl := newFlagSet("repl")
+	if code, ok := parseFlags(fl, args); !ok {
+		return code
+	}
+
+	evaluator.SetArgs(nil)
+	fmt.Printf("keai version %s\n", KEAI_VERSION)
+	fmt.Println("Use ctrl+d to quit")
+	repl.Start(os.Stdin, os.Stdout, getStdlibString())
+	return 0
+}
+
+// Run tests.
+func testCmd(args []string) int {
+	fl := newFlagSet("test")
+	format := fl.String(
+		"format",
+		"human",
+		"Report format: "+strings.Join(testrunner.Formats, ", "),
+	)
+	timeout := fl.Duration(
+		"timeout",
+		30*time.Second,
+		"Fail a test that runs longer (0 for no limit)",
+	)
+	coverage, profile := addCoverageFlags(fl)
+	if code, ok := parseFlags(fl, args); !ok {
+		return code
+	}
+
+	paths := fl.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := testrunner.Discover(paths)
+	if err != nil {
+		fmt.Printf("Error finding tests: %s\n", err.Error())
+		return 1
+	}
+	if len(files) == 0 {
+		fmt.Printf("No test files found (%s)\n", "*"+testrunner.Suffix)
+		return 1
+	}
+
+	// colors would end up in the TAP or XML
+	if *format != "human" {
+		evaluator.ColorDiffs = false
+	}
+
+	evaluator.SetArgs(append([]string{"test"}, paths...))
+	if *coverage {
+		defer startCoverage(*profile)()
+	}
+	report := testrunner.Run(files, testrunner.Options{Timeout: *timeout})
+	if err := testrunner.Write(os.Stdout, report, *format); err != nil {
+		fmt.Printf("Error reporting: %s\n", err.Error())
+		return 2
+	}
+	if report.Failed() {
+		return 1
+	}
+	return 0
+}
+
+// Show our version.
+func versionCmd(args []string) int {
+	fmt.Printf("keai %s\n", KEAI_VERSION)
+	return 0
+}
+
+// List the commands.
+func helpCmd(args []string) int {
+	fmt.Println("Usage: keai [command] [arguments]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	for _, cmd := range commands {
+		fmt.Printf("    %-10s %s\n", cmd.name, cmd.desc)
+	}
+	fmt.Println()
+	fmt.Println("keai program.keai [args...] is short for keai run program.keai.")
+	fmt.Println("Run keai [command] -h for a command's options.")
+	return 0
+}
+
+// Install the dependencies of the project we're in.
+func installCmd(args []string) int {
+	fl := newFlagSet("install")
+	if code, ok := parseFlags(fl, args); !ok {
+		return code
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("Error installing: %s\n", err.Error())
+		return 1
+	}
+
+	root := pkgmgr.FindProjectRoot(cwd)
+	if root == "" {
+		fmt.Printf("Error installing: no %s found\n", pkgmgr.ManifestFile)
+		return 1
+	}
+
+	if _, err := pkgmgr.Install(root, os.Stdout); err != nil {
+		fmt.Printf("Error installing: %s\n", err.Error())
+		return 1
+	}
+	return 0
+}
+
+// assetsFlag collects repeated -a flags.
+type assetsFlag []string
+
+func (a *assetsFlag) String() string {
+	return strings.Join(*a, ",")
+}
+
+func (a *assetsFlag) Set(s string) error {
+	*a = append(*a, s)
+	return nil
+}
+
+// Build a program into a standalone executable.
+func buildCmd(args []string) int {
+	opts := bundle.Options{Log: os.Stderr}
+	var assets assetsFlag
+	fl := newFlagSet("build")
+	fl.StringVar(&opts.Output, "o", "", "Executable to write")
+	fl.Var(&assets, "a", "File or directory to bundle (can be repeated)")
+
+	// allow flags after the program, like `keai build app.keai -o app`
+	programs := []string{}
+	for {
+		if code, ok := parseFlags(fl, args); !ok {
+			return code
+		}
+		if fl.NArg() == 0 {
+			break
+		}
+		programs = append(programs, fl.Arg(0))
+		args = fl.Args()[1:]
+	}
+	if len(programs) != 1 {
+		fl.Usage()
+		return 2
+	}
+
+	opts.Program = programs[0]
+	opts.Assets = assets
+	if opts.Output == "" {
+		opts.Output = strings.TrimSuffix(
+			filepath.Base(opts.Program),
+			filepath.Ext(opts.Program),
+		)
+	}
+
+	if err := bundle.Build(opts); err != nil {
+		fmt.Printf("Error building: %s\n", err.Error())
+		return 1
+	}
+	return 0
+}
+
+// Format programs.
+func fmtCmd(args []string) int {
+	fl := newFlagSet("fmt")
+	check := fl.Bool(
+		"check",
+		false,
+		"List files that aren't formatted and exit with 1, without writing",
+	)
+	diff := fl.Bool("diff", false, "Print diffs instead of writing files")
+	if code, ok := parseFlags(fl, args); !ok {
+		return code
+	}
+
+	// no paths: format stdin to stdout
+	if fl.NArg() == 0 {
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading: %s\n", err.Error())
+			return 1
+		}
+		out, err := format.Source(src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "<stdin>: %s\n", err.Error())
+			return 1
+		}
+		changed := string(out) != string(src)
+		switch {
+		case *diff:
+			fmt.Print(format.Diff("<stdin>", src, out))
+		case *check:
+			if changed {
+				fmt.Println("<stdin>")
+			}
+		default:
+			os.Stdout.Write(out)
+		}
+		if *check && changed {
+			return 1
+		}
+		return 0
+	}
+
+	files, err := utils.FindFiles(fl.Args(), ".keai")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error finding files: %s\n", err.Error())
+		return 1
+	}
+
+	code := 0
+	for _, path := range files {
+		src, err := ioutil.ReadFile(path)
+		if err == nil {
+			var out []byte
+			out, err = format.Source(src)
+			if err == nil && string(out) != string(src) {
+				switch {
+				case *diff:
+					fmt.Print(format.Diff(path, src, out))
+				case *check:
+					fmt.Println(path)
+				default:
+					err = writeFile(path, out)
+				}
+				if *check {
+					code = 1
+				}
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err.Error())
+			code = 1
+		}
+	}
+	return code
+}
+
+func checkCmd(args []string) int {
+	fl := newFlagSet("check")
+	if code, ok := parseFlags(fl, args); !ok {
+		return code
+	}
+	paths := fl.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	stdlib := []*ast.Program{}
+	for _, f := range getStdlibFiles() {
+		p := parser.New(lexer.New(f.Source))
+		stdlib = append(stdlib, p.ParseProgram())
+	}
+	checker := check.New(evaluator.BuiltinNames(), stdlib...)
+
+	files, err := utils.FindFiles(paths, ".keai")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error finding files: %s\n", err.Error())
+		return 1
+	}
+
+	code := 0
+	for _, path := range files {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err.Error())
+			code = 1
+			continue
+		}
+		problems, err := checker.Source(path, src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err.Error())
+			code = 1
+		}
+		for _, p := range problems {
+			fmt.Println(p)
+			code = 1
+		}
+	}
+	return code
+}
+
+func lspCmd(args []string) int {
+	fl := newFlagSet("lsp")
+	if code, ok := parseFlags(fl, args); !ok {
+		return code
+	}
+	if err := lsp.Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "lsp: %s\n", err.Error())
+		return 1
+	}
+	return 0
+}
+
+func debugCmd(args []string) int {
+	fl := newFlagSet("debug")
+	dap := fl.Bool(
+		"dap",
+		false,
+		"Speak the Debug Adapter Protocol on stdio, for editors",
+	)
+	if code, ok := parseFlags(fl, args); !ok {
+		return code
+	}
+
+	if *dap {
+		// the program's output would get mixed up with the protocol, so it
+		// goes to the editor as output events instead
+		stdout := os.Stdout
+		r, w, err := os.Pipe()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "debug: %s\n", err.Error())
+			return 1
+		}
+		os.Stdout = w
+		err = debugger.ServeDAP(os.Stdin, stdout, r, runDebugged)
+		os.Stdout = stdout
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "debug: %s\n", err.Error())
+			return 1
+		}
+		return 0
+	}
+
+	rest := fl.Args()
+	if len(rest) == 0 {
+		fl.Usage()
+		return 2
+	}
+	s := debugger.New(rest[0], true)
+	s.Run(func() int {
+		return runDebugged(rest[0], rest[1:])
+	})
+	code := debugger.Terminal(s, os.Stdin, os.Stdout)
+	utils.ExitHandler = nil
+	return code
+}
+
+// runDebugged runs a program for keai debug.
+func runDebugged(name string, args []string) int {
+	input, err := ioutil.ReadFile(name)
+	if err != nil {
+		fmt.Printf("Error reading: %s\n", err.Error())
+		return 1
+	}
+	evaluator.SetArgs(append([]string{name}, args...))
+	return Execute(name, string(input))
+}
+
+func docCmd(args []string) int {
+	fl := newFlagSet("doc")
+	format := fl.String("format", "markdown", "markdown or html")
+	if code, ok := parseFlags(fl, args); !ok {
+		return code
+	}
+	if *format != "markdown" && *format != "html" {
+		fmt.Fprintf(os.Stderr, "Unknown format %s\n", *format)
+		return 2
+	}
+	if fl.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "keai doc takes at most one module or file")
+		return 2
+	}
+
+	title := "keai"
+	var entries []doc.Entry
+	name := fl.Arg(0)
+	path := ""
+	if name != "" {
+		if info, err := os.Stat(name); err == nil && !info.IsDir() {
+			path = name
+		} else {
+			path = evaluator.FindModule(name)
+		}
+	}
+
+	if path != "" {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %s\n", path, err.Error())
+			return 1
+		}
+		p := parser.New(lexer.New(string(src)))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			for _, msg := range p.Errors() {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", path, msg)
+			}
+			return 1
+		}
+		title = name
+		entries = doc.FromProgram(program)
+	} else {
+		for _, b := range evaluator.BuiltinNames() {
+			entries = append(entries, doc.Entry{
+				Name:    b,
+				Doc:     evaluator.BuiltinDoc(b),
+				Builtin: true,
+			})
+		}
+		for _, f := range getStdlibFiles() {
+			p := parser.New(lexer.New(f.Source))
+			entries = append(entries, doc.FromProgram(p.ParseProgram())...)
+		}
+
+		// anything else is a namespace, like `keai doc http`
+		if name != "" {
+			title = name
+			matching := []doc.Entry{}
+			for _, e := range entries {
+				if e.Namespace() == name {
+					matching = append(matching, e)
+				}
+			}
+			if len(matching) == 0 {
+				fmt.Fprintf(os.Stderr, "No module, file, or namespace %s\n",
+					name)
+				return 1
+			}
+			entries = matching
+		}
+	}
+
+	groups := doc.Groups(entries)
+	if *format == "html" {
+		fmt.Print(doc.HTML(title, groups))
+	} else {
+		fmt.Print(doc.Markdown(title, groups))
+	}
+	return 0
+}
+
+// writeFile replaces a file's contents, keeping its permissions.
+func writeFile(path string, b []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, info.Mode())
+}
@@ -0,0 +1,512 @@
+package debugger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/zautumnz/keai/evaluator"
+	"github.com/zautumnz/keai/object"
+)
+
+// The parts of the Debug Adapter Protocol we use. There's one thread, and
+// lines and columns are counted from 1.
+
+type dapRequest struct {
+	Seq       int             `json:"seq"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type dapResponse struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Command    string      `json:"command"`
+	Success    bool        `json:"success"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+type dapEvent struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+type launchArgs struct {
+	Program     string   `json:"program"`
+	Args        []string `json:"args"`
+	StopOnEntry bool     `json:"stopOnEntry"`
+}
+
+type source struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type setBreakpointsArgs struct {
+	Source      source `json:"source"`
+	Breakpoints []struct {
+		Line int `json:"line"`
+	} `json:"breakpoints"`
+}
+
+type dapBreakpoint struct {
+	Verified bool `json:"verified"`
+	Line     int  `json:"line"`
+}
+
+type stackFrame struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Source source `json:"source"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+type scope struct {
+	Name               string `json:"name"`
+	VariablesReference int    `json:"variablesReference"`
+	Expensive          bool   `json:"expensive"`
+}
+
+type variable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type,omitempty"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+// handle is something with variables a client can ask for: the scopes of
+// an environment, or the elements of an array or hash.
+type handle struct {
+	// scopes is the environments whose bindings are shown, innermost
+	// first, for a scope
+	scopes []*object.Environment
+	obj    object.Object
+}
+
+// dapServer drives a session for a client.
+type dapServer struct {
+	r *bufio.Reader
+
+	// wmu guards writing, since events are sent while requests are handled
+	wmu sync.Mutex
+	w   io.Writer
+	seq int
+
+	s   *Session
+	run func(program string, args []string) int
+
+	// the program starts once it's been launched and configured
+	launch     *launchArgs
+	configured bool
+
+	// mu guards frames and handles, which are set when the program pauses
+	// and only good until it resumes
+	mu      sync.Mutex
+	frames  []evaluator.Frame
+	handles []handle
+}
+
+// ServeDAP speaks the Debug Adapter Protocol over in and out, which a
+// client like VS Code or nvim-dap starts keai debug --dap to do. run is
+// called to run the program the client launches. What the program writes
+// should be sent to output, which is forwarded to the client.
+func ServeDAP(
+	in io.Reader,
+	out io.Writer,
+	output io.Reader,
+	run func(program string, args []string) int,
+) error {
+	d := &dapServer{
+		r:   bufio.NewReader(in),
+		w:   out,
+		s:   New("", false),
+		run: run,
+	}
+	if output != nil {
+		go d.forward(output)
+	}
+
+	for {
+		body, err := d.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req dapRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return err
+		}
+		if done := d.handle(req); done {
+			return nil
+		}
+	}
+}
+
+// read returns the body of the next message, which is framed the same way
+// as the language server's.
+func (d *dapServer) read() ([]byte, error) {
+	length := -1
+	for {
+		line, err := d.r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(name, "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("bad Content-Length: %s", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length")
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(d.r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// send writes a response or event, setting its sequence number.
+func (d *dapServer) send(msg interface{}) {
+	d.wmu.Lock()
+	defer d.wmu.Unlock()
+	d.seq++
+	switch m := msg.(type) {
+	case *dapResponse:
+		m.Seq = d.seq
+	case *dapEvent:
+		m.Seq = d.seq
+	}
+	body, _ := json.Marshal(msg)
+	fmt.Fprintf(d.w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (d *dapServer) reply(req dapRequest, body interface{}) {
+	d.send(&dapResponse{
+		Type:       "response",
+		RequestSeq: req.Seq,
+		Command:    req.Command,
+		Success:    true,
+		Body:       body,
+	})
+}
+
+func (d *dapServer) fail(req dapRequest, msg string) {
+	d.send(&dapResponse{
+		Type:       "response",
+		RequestSeq: req.Seq,
+		Command:    req.Command,
+		Message:    msg,
+	})
+}
+
+func (d *dapServer) event(name string, body interface{}) {
+	d.send(&dapEvent{Type: "event", Event: name, Body: body})
+}
+
+// forward sends what the program writes as output events.
+func (d *dapServer) forward(output io.Reader) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := output.Read(buf)
+		if n > 0 {
+			d.event("output", map[string]string{
+				"category": "stdout",
+				"output":   string(buf[:n]),
+			})
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handle handles a request, returning true if the client is done.
+func (d *dapServer) handle(req dapRequest) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch req.Command {
+	case "initialize":
+		d.reply(req, map[string]bool{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+		})
+		d.event("initialized", nil)
+
+	case "launch":
+		var args launchArgs
+		if err := json.Unmarshal(req.Arguments, &args); err != nil ||
+			args.Program == "" {
+			d.fail(req, "launch needs a program")
+			return false
+		}
+		d.launch = &args
+		d.s.main = args.Program
+		d.s.stopOnEntry = args.StopOnEntry
+		d.reply(req, nil)
+		d.start()
+
+	case "setBreakpoints":
+		var args setBreakpointsArgs
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			d.fail(req, err.Error())
+			return false
+		}
+		lines := []int{}
+		bps := []dapBreakpoint{}
+		for _, bp := range args.Breakpoints {
+			lines = append(lines, bp.Line)
+			bps = append(bps, dapBreakpoint{Verified: true, Line: bp.Line})
+		}
+		d.s.SetBreakpoints(args.Source.Path, lines)
+		d.reply(req, map[string]interface{}{"breakpoints": bps})
+
+	case "setExceptionBreakpoints":
+		d.reply(req, map[string]interface{}{"breakpoints": []dapBreakpoint{}})
+
+	case "configurationDone":
+		d.configured = true
+		d.reply(req, nil)
+		d.start()
+
+	case "threads":
+		d.reply(req, map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": 1, "name": "main"}},
+		})
+
+	case "stackTrace":
+		frames := []stackFrame{}
+		for i, f := range d.frames {
+			path, _ := filepath.Abs(f.File)
+			frames = append(frames, stackFrame{
+				ID:     i,
+				Name:   f.Name,
+				Source: source{Name: filepath.Base(f.File), Path: path},
+				Line:   f.Line,
+				Column: 1,
+			})
+		}
+		d.reply(req, map[string]interface{}{
+			"stackFrames": frames,
+			"totalFrames": len(frames),
+		})
+
+	case "scopes":
+		var args struct {
+			FrameID int `json:"frameId"`
+		}
+		json.Unmarshal(req.Arguments, &args)
+		if args.FrameID < 0 || args.FrameID >= len(d.frames) {
+			d.fail(req, "no such frame")
+			return false
+		}
+		locals, globals := []*object.Environment{}, []*object.Environment{}
+		for env := d.frames[args.FrameID].Env; env != nil; env = env.Outer() {
+			if env.IsTopLevel() {
+				globals = append(globals, env)
+			} else {
+				locals = append(locals, env)
+			}
+		}
+		scopes := []scope{}
+		if len(locals) > 0 {
+			scopes = append(scopes, scope{
+				Name:               "Locals",
+				VariablesReference: d.newHandle(handle{scopes: locals}),
+			})
+		}
+		scopes = append(scopes, scope{
+			Name:               "Globals",
+			VariablesReference: d.newHandle(handle{scopes: globals}),
+			Expensive:          true,
+		})
+		d.reply(req, map[string]interface{}{"scopes": scopes})
+
+	case "variables":
+		var args struct {
+			Ref int `json:"variablesReference"`
+		}
+		json.Unmarshal(req.Arguments, &args)
+		if args.Ref < 1 || args.Ref > len(d.handles) {
+			d.fail(req, "no such variables")
+			return false
+		}
+		d.reply(req, map[string]interface{}{
+			"variables": d.variables(d.handles[args.Ref-1]),
+		})
+
+	case "evaluate":
+		var args struct {
+			Expression string `json:"expression"`
+			FrameID    *int   `json:"frameId"`
+		}
+		json.Unmarshal(req.Arguments, &args)
+		if len(d.frames) == 0 {
+			d.fail(req, "the program isn't paused")
+			return false
+		}
+		frame := 0
+		if args.FrameID != nil && *args.FrameID < len(d.frames) {
+			frame = *args.FrameID
+		}
+		res, err := d.s.Eval(args.Expression, d.frames[frame].Env)
+		if err != nil {
+			d.fail(req, err.Error())
+			return false
+		}
+		v := d.variable("", res)
+		d.reply(req, map[string]interface{}{
+			"result":             v.Value,
+			"type":               v.Type,
+			"variablesReference": v.VariablesReference,
+		})
+
+	case "continue":
+		d.resume(req, d.s.Continue)
+	case "next":
+		d.resume(req, d.s.StepOver)
+	case "stepIn":
+		d.resume(req, d.s.StepIn)
+	case "stepOut":
+		d.resume(req, d.s.StepOut)
+
+	case "pause":
+		d.s.Pause()
+		d.reply(req, nil)
+
+	case "disconnect", "terminate":
+		d.reply(req, nil)
+		return true
+
+	default:
+		d.fail(req, "unsupported request "+req.Command)
+	}
+	return false
+}
+
+// start runs the program once it's been launched and configured.
+func (d *dapServer) start() {
+	if d.launch == nil || !d.configured {
+		return
+	}
+	program, args := d.launch.Program, d.launch.Args
+	d.s.Run(func() int {
+		return d.run(program, args)
+	})
+	go d.watch()
+}
+
+// watch tells the client when the program pauses or exits.
+func (d *dapServer) watch() {
+	for ev := range d.s.Events() {
+		if ev.Reason == "exited" {
+			d.event("exited", map[string]int{"exitCode": ev.Code})
+			d.event("terminated", nil)
+			continue
+		}
+		d.mu.Lock()
+		d.frames = evaluator.Stack()
+		d.handles = nil
+		d.mu.Unlock()
+		d.event("stopped", map[string]interface{}{
+			"reason":            ev.Reason,
+			"threadId":          1,
+			"allThreadsStopped": true,
+		})
+	}
+}
+
+// resume answers a request that resumes the program, then resumes it.
+func (d *dapServer) resume(req dapRequest, resume func()) {
+	if len(d.frames) == 0 {
+		d.fail(req, "the program isn't paused")
+		return
+	}
+	d.frames = nil
+	d.handles = nil
+	d.reply(req, map[string]bool{"allThreadsContinued": true})
+	resume()
+}
+
+// newHandle returns the reference a client can ask for a handle's
+// variables by.
+func (d *dapServer) newHandle(h handle) int {
+	d.handles = append(d.handles, h)
+	return len(d.handles)
+}
+
+// variables returns the variables of a handle.
+func (d *dapServer) variables(h handle) []variable {
+	vars := []variable{}
+	if h.scopes != nil {
+		seen := map[string]bool{}
+		for _, env := range h.scopes {
+			bindings := env.Vars()
+			names := []string{}
+			for name := range bindings {
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				vars = append(vars, d.variable(name, bindings[name]))
+			}
+		}
+		return vars
+	}
+
+	switch obj := h.obj.(type) {
+	case *object.Array:
+		for i, e := range obj.Elements {
+			vars = append(vars, d.variable(strconv.Itoa(i), e))
+		}
+	case *object.Hash:
+		for _, pair := range obj.Pairs {
+			vars = append(vars, d.variable(pair.Key.Inspect(), pair.Value))
+		}
+		sort.Slice(vars, func(i, j int) bool {
+			return vars[i].Name < vars[j].Name
+		})
+	}
+	return vars
+}
+
+// variable describes a value, with a reference to its elements if it's an
+// array or hash.
+func (d *dapServer) variable(name string, obj object.Object) variable {
+	v := variable{Name: name, Value: obj.Inspect(), Type: string(obj.Type())}
+	switch obj := obj.(type) {
+	case *object.Array:
+		if len(obj.Elements) > 0 {
+			v.VariablesReference = d.newHandle(handle{obj: obj})
+		}
+	case *object.Hash:
+		if len(obj.Pairs) > 0 {
+			v.VariablesReference = d.newHandle(handle{obj: obj})
+		}
+	}
+	return v
+}
@@ -0,0 +1,317 @@
+// Package debugger implements keai debug: breakpoints, stepping, and
+// inspecting a paused program, from the terminal or through the Debug
+// Adapter Protocol.
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/zautumnz/keai/ast"
+	"github.com/zautumnz/keai/evaluator"
+	"github.com/zautumnz/keai/lexer"
+	"github.com/zautumnz/keai/object"
+	"github.com/zautumnz/keai/parser"
+	"github.com/zautumnz/keai/utils"
+)
+
+// Event is something that happened to the program: it paused, or it
+// finished.
+type Event struct {
+	// Reason is "entry", "breakpoint", "step", or "pause" for a pause, or
+	// "exited" when the program finishes
+	Reason string
+
+	File string
+	Line int
+
+	// Code is the exit code, when the program finishes
+	Code int
+}
+
+// Breakpoint is a line of a file to pause at.
+type Breakpoint struct {
+	File string
+	Line int
+}
+
+// mode is what the program is doing until it next pauses.
+type mode int
+
+const (
+	running mode = iota
+	stepIn
+	stepOver
+	stepOut
+	pausing
+)
+
+// location is a statement the program got to.
+type location struct {
+	file  string
+	line  int
+	depth int
+}
+
+// Session is a program being debugged. It's the evaluator's debugger: the
+// program runs in its own goroutine and waits in Statement while it's
+// paused, so everything that looks at the program has to happen then.
+type Session struct {
+	// main is the program's file; stopOnEntry pauses at its first
+	// statement
+	main        string
+	stopOnEntry bool
+
+	mu          sync.Mutex
+	breakpoints map[string]map[int]bool
+	mode        mode
+
+	// stopped is where the program last paused, and last is the last
+	// statement it got to
+	stopped location
+	last    location
+
+	// paths caches the absolute paths of files, which breakpoints are
+	// keyed by
+	paths map[string]string
+
+	// evaluating is set while the program is paused and we're evaluating
+	// an expression in it, which shouldn't pause it again
+	evaluating bool
+
+	events chan Event
+	resume chan struct{}
+}
+
+// New returns a session for debugging a program.
+func New(main string, stopOnEntry bool) *Session {
+	return &Session{
+		main:        main,
+		stopOnEntry: stopOnEntry,
+		breakpoints: map[string]map[int]bool{},
+		paths:       map[string]string{},
+		events:      make(chan Event),
+		resume:      make(chan struct{}),
+	}
+}
+
+// Events returns the channel the program's pauses and exit are sent on.
+// Once the program pauses, it waits until it's continued or stepped. The
+// channel is closed after the exit.
+func (s *Session) Events() <-chan Event {
+	return s.events
+}
+
+// exit is what ExitConditionally panics with while debugging.
+type exit struct {
+	code int
+}
+
+// Run starts running a program in its own goroutine, with the session as
+// the evaluator's debugger. run should register the files it evaluates with
+// evaluator.Register.
+func (s *Session) Run(run func() int) {
+	evaluator.SetDebugger(s)
+	go func() {
+		code := 0
+		defer func() {
+			evaluator.SetDebugger(nil)
+			utils.ExitHandler = nil
+			if r := recover(); r != nil {
+				e, ok := r.(exit)
+				if !ok {
+					panic(r)
+				}
+				code = e.code
+			}
+			s.events <- Event{Reason: "exited", Code: code}
+			close(s.events)
+		}()
+		utils.ExitHandler = func(code int) {
+			panic(exit{code})
+		}
+		code = run()
+	}()
+}
+
+// path returns a file's absolute path.
+func (s *Session) path(file string) string {
+	if p, ok := s.paths[file]; ok {
+		return p
+	}
+	p, err := filepath.Abs(file)
+	if err != nil {
+		p = file
+	}
+	s.paths[file] = p
+	return p
+}
+
+// SetBreakpoints replaces the breakpoints in a file.
+func (s *Session) SetBreakpoints(file string, lines []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set := map[int]bool{}
+	for _, l := range lines {
+		set[l] = true
+	}
+	s.breakpoints[s.path(file)] = set
+}
+
+// Breakpoints returns every breakpoint, sorted.
+func (s *Session) Breakpoints() []Breakpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bps := []Breakpoint{}
+	for file, lines := range s.breakpoints {
+		for line := range lines {
+			bps = append(bps, Breakpoint{file, line})
+		}
+	}
+	sort.Slice(bps, func(i, j int) bool {
+		if bps[i].File != bps[j].File {
+			return bps[i].File < bps[j].File
+		}
+		return bps[i].Line < bps[j].Line
+	})
+	return bps
+}
+
+// Statement pauses the program if it's at a breakpoint or done stepping.
+func (s *Session) Statement(file string, line int, env *object.Environment) {
+	s.mu.Lock()
+	if s.evaluating {
+		s.mu.Unlock()
+		return
+	}
+
+	here := location{file, line, evaluator.Depth()}
+	reason := ""
+	switch {
+	case s.stopOnEntry && file == s.main:
+		s.stopOnEntry = false
+		reason = "entry"
+	case s.breakpoints[s.path(file)][line] &&
+		(file != s.last.file || line != s.last.line):
+		reason = "breakpoint"
+	case s.mode == pausing:
+		reason = "pause"
+	case file == s.stopped.file && line == s.stopped.line &&
+		here.depth == s.stopped.depth:
+		// stepping goes to the next line
+	case s.mode == stepIn,
+		s.mode == stepOver && here.depth <= s.stopped.depth,
+		s.mode == stepOut && here.depth < s.stopped.depth:
+		reason = "step"
+	}
+	s.last = here
+	if reason == "" {
+		s.mu.Unlock()
+		return
+	}
+	s.stopped = here
+	s.mode = running
+	s.mu.Unlock()
+
+	s.events <- Event{Reason: reason, File: file, Line: line}
+	<-s.resume
+}
+
+// proceed resumes the paused program.
+func (s *Session) proceed(m mode) {
+	s.mu.Lock()
+	s.mode = m
+	s.mu.Unlock()
+	s.resume <- struct{}{}
+}
+
+// Continue runs the paused program until a breakpoint.
+func (s *Session) Continue() {
+	s.proceed(running)
+}
+
+// StepIn runs the paused program to the next statement.
+func (s *Session) StepIn() {
+	s.proceed(stepIn)
+}
+
+// StepOver runs the paused program to the next statement that isn't in a
+// function it calls.
+func (s *Session) StepOver() {
+	s.proceed(stepOver)
+}
+
+// StepOut runs the paused program until the function it's in returns.
+func (s *Session) StepOut() {
+	s.proceed(stepOut)
+}
+
+// Pause pauses the running program at its next statement.
+func (s *Session) Pause() {
+	s.mu.Lock()
+	s.mode = pausing
+	s.mu.Unlock()
+}
+
+// Eval evaluates an expression in the paused program.
+func (s *Session) Eval(
+	expr string,
+	env *object.Environment,
+) (res object.Object, err error) {
+	p := parser.New(lexer.New(expr))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, errors.New(p.Errors()[0])
+	}
+
+	// an unknown name would print an error and exit
+	if len(program.Statements) == 1 {
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if ok {
+			if id, ok := stmt.Expression.(*ast.Identifier); ok {
+				if _, found := env.Get(id.Value); !found &&
+					!contains(evaluator.BuiltinNames(), id.Value) &&
+					!evaluator.IsNamespace(id.Value, env) {
+					return nil, fmt.Errorf("undefined: %s", id.Value)
+				}
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.evaluating = true
+	s.mu.Unlock()
+	prev := utils.ExitHandler
+	utils.ExitHandler = func(code int) {
+		panic(exit{code})
+	}
+	defer func() {
+		utils.ExitHandler = prev
+		s.mu.Lock()
+		s.evaluating = false
+		s.mu.Unlock()
+		if r := recover(); r != nil {
+			if _, ok := r.(exit); !ok {
+				panic(r)
+			}
+			res, err = nil, fmt.Errorf("evaluating %s failed", expr)
+		}
+	}()
+
+	res = evaluator.Eval(program, env)
+	if res == nil {
+		res = evaluator.NULL
+	}
+	if e, ok := res.(*object.Error); ok {
+		return nil, errors.New(e.Message)
+	}
+	return res, nil
+}
+
+func contains(names []string, name string) bool {
+	i := sort.SearchStrings(names, name)
+	return i < len(names) && names[i] == name
+}
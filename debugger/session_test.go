@@ -0,0 +1,103 @@
+package debugger
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zautumnz/keai/evaluator"
+	"github.com/zautumnz/keai/lexer"
+	"github.com/zautumnz/keai/object"
+	"github.com/zautumnz/keai/parser"
+)
+
+const program = `let double = fn (x) {
+    let y = x * 2
+    return y
+}
+let total = fn (xs) {
+    mutable sum = 0
+    foreach x in xs {
+        sum += double(x)
+    }
+    return sum
+}
+let t = total([1, 2])
+`
+
+// start runs the program in a session.
+func start(s *Session) {
+	s.Run(func() int {
+		p := parser.New(lexer.New(program))
+		prog := p.ParseProgram()
+		evaluator.Register("t.keai", program, prog)
+		evaluator.Eval(prog, object.NewEnvironment())
+		return 0
+	})
+}
+
+func TestSession(t *testing.T) {
+	s := New("t.keai", true)
+	s.SetBreakpoints("t.keai", []int{8})
+	start(s)
+
+	expect := func(reason string, line int) {
+		t.Helper()
+		ev := <-s.Events()
+		if ev.Reason != reason || ev.Line != line {
+			t.Fatalf("expected %s at %d, got %s at %d",
+				reason, line, ev.Reason, ev.Line)
+		}
+	}
+
+	expect("entry", 1)
+	s.Continue()
+	expect("breakpoint", 8)
+
+	stack := evaluator.Stack()
+	if len(stack) != 2 || stack[0].Name != "total" || stack[1].Name != "main" {
+		t.Fatalf("wrong stack: %v", stack)
+	}
+	res, err := s.Eval("sum + x", stack[0].Env)
+	if err != nil || res.Inspect() != "1" {
+		t.Errorf("expected sum + x to be 1, got %v, %v", res, err)
+	}
+	if _, err := s.Eval("nope", stack[0].Env); err == nil {
+		t.Errorf("expected an error evaluating an undefined name")
+	}
+
+	s.StepIn()
+	expect("step", 2)
+	if evaluator.Stack()[0].Name != "double" {
+		t.Errorf("expected to step into double")
+	}
+	s.StepOut()
+	expect("breakpoint", 8)
+	s.SetBreakpoints("t.keai", nil)
+	s.StepOver()
+	expect("step", 10)
+	s.Continue()
+	expect("exited", 0)
+}
+
+func TestTerminal(t *testing.T) {
+	s := New("t.keai", true)
+	start(s)
+
+	in := strings.NewReader("b 3\nc\nbt\nw y\np x + 1\nc\nclear\nc\n")
+	var out strings.Builder
+	if code := Terminal(s, in, &out); code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+	for _, s := range []string{
+		"Paused at t.keai:1 (entry)",
+		"Paused at t.keai:3 (breakpoint)\n",
+		"> 0 double at t.keai:3\n  1 total at t.keai:8\n  2 main at t.keai:12\n",
+		"(keai) 2\n(keai) 2\n",
+		"1: y = 4\n",
+		"Program exited with code 0",
+	} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("output doesn't contain %q:\n%s", s, out.String())
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zautumnz/keai/evaluator"
+	"github.com/zautumnz/keai/object"
+)
+
+const terminalHelp = `Commands:
+  break [file:]line   pause at a line (b)
+  clear [[file:]line] remove a breakpoint, or all of them
+  breakpoints         list the breakpoints
+  continue            run until a breakpoint (c)
+  next                step over function calls (n)
+  step                step into function calls (s)
+  out                 step out of the current function (o)
+  backtrace           show the call stack (bt)
+  frame n             select a frame of the call stack (f)
+  env                 show the variables in the selected frame
+  print expr          evaluate an expression in the selected frame (p)
+  watch expr          evaluate an expression every time we pause (w)
+  unwatch n           remove a watch expression
+  list                show the code around the selected frame (l)
+  quit                stop debugging (q)
+An empty line repeats the last command.
+`
+
+// terminal is a session being driven from a terminal.
+type terminal struct {
+	s   *Session
+	in  *bufio.Scanner
+	out io.Writer
+
+	// frames is the call stack where the program paused, and frame is the
+	// one selected
+	frames []evaluator.Frame
+	frame  int
+
+	watches []string
+	sources map[string][]string
+	last    string
+}
+
+// Terminal runs a session from a terminal, reading commands whenever the
+// program pauses. It returns the program's exit code, or 1 if we quit
+// before it finished.
+func Terminal(s *Session, in io.Reader, out io.Writer) int {
+	t := &terminal{
+		s:       s,
+		in:      bufio.NewScanner(in),
+		out:     out,
+		sources: map[string][]string{},
+	}
+	for ev := range s.Events() {
+		if ev.Reason == "exited" {
+			fmt.Fprintf(out, "Program exited with code %d\n", ev.Code)
+			return ev.Code
+		}
+		t.frames = evaluator.Stack()
+		t.frame = 0
+		fmt.Fprintf(out, "Paused at %s:%d (%s)\n", ev.File, ev.Line, ev.Reason)
+		t.showLine(ev.File, ev.Line)
+		t.showWatches()
+		if !t.prompt() {
+			return 1
+		}
+	}
+	return 1
+}
+
+// prompt reads commands until one resumes the program, returning false if
+// we're quitting.
+func (t *terminal) prompt() bool {
+	for {
+		fmt.Fprint(t.out, "(keai) ")
+		if !t.in.Scan() {
+			fmt.Fprintln(t.out)
+			return false
+		}
+		line := strings.TrimSpace(t.in.Text())
+		if line == "" {
+			line = t.last
+		}
+		t.last = line
+
+		cmd, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+		switch cmd {
+		case "":
+		case "c", "continue":
+			t.s.Continue()
+			return true
+		case "n", "next":
+			t.s.StepOver()
+			return true
+		case "s", "step":
+			t.s.StepIn()
+			return true
+		case "o", "out":
+			t.s.StepOut()
+			return true
+		case "q", "quit":
+			return false
+		case "b", "break":
+			t.setBreakpoint(arg, true)
+		case "clear":
+			if arg == "" {
+				for _, bp := range t.s.Breakpoints() {
+					t.s.SetBreakpoints(bp.File, nil)
+				}
+			} else {
+				t.setBreakpoint(arg, false)
+			}
+		case "breakpoints":
+			for _, bp := range t.s.Breakpoints() {
+				fmt.Fprintf(t.out, "%s:%d\n", bp.File, bp.Line)
+			}
+		case "bt", "backtrace":
+			for i, f := range t.frames {
+				mark := " "
+				if i == t.frame {
+					mark = ">"
+				}
+				fmt.Fprintf(t.out, "%s %d %s at %s:%d\n",
+					mark, i, f.Name, f.File, f.Line)
+			}
+		case "f", "frame":
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 || n >= len(t.frames) {
+				fmt.Fprintf(t.out, "No frame %s\n", arg)
+				continue
+			}
+			t.frame = n
+			f := t.frames[n]
+			fmt.Fprintf(t.out, "%s at %s:%d\n", f.Name, f.File, f.Line)
+			t.showLine(f.File, f.Line)
+		case "env":
+			t.showEnv()
+		case "p", "print":
+			t.print(arg)
+		case "w", "watch":
+			t.watches = append(t.watches, arg)
+			t.print(arg)
+		case "unwatch":
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 || n > len(t.watches) {
+				fmt.Fprintf(t.out, "No watch expression %s\n", arg)
+				continue
+			}
+			t.watches = append(t.watches[:n-1], t.watches[n:]...)
+		case "l", "list":
+			f := t.frames[t.frame]
+			lines := t.source(f.File)
+			for n := f.Line - 5; n <= f.Line+5; n++ {
+				if n < 1 || n > len(lines) {
+					continue
+				}
+				mark := "  "
+				if n == f.Line {
+					mark = "=>"
+				}
+				fmt.Fprintf(t.out, "%s %4d | %s\n", mark, n, lines[n-1])
+			}
+		case "h", "help":
+			fmt.Fprint(t.out, terminalHelp)
+		default:
+			fmt.Fprintf(t.out, "Unknown command %s; try help\n", cmd)
+		}
+	}
+}
+
+// env returns the environment of the selected frame.
+func (t *terminal) env() *object.Environment {
+	return t.frames[t.frame].Env
+}
+
+// setBreakpoint adds or removes a breakpoint given as [file:]line, in the
+// selected frame's file if there's no file.
+func (t *terminal) setBreakpoint(arg string, on bool) {
+	file := t.frames[t.frame].File
+	if i := strings.LastIndex(arg, ":"); i >= 0 {
+		file, arg = arg[:i], arg[i+1:]
+	}
+	line, err := strconv.Atoi(arg)
+	if err != nil {
+		fmt.Fprintln(t.out, "Expected [file:]line")
+		return
+	}
+
+	path := t.s.path(file)
+	lines := []int{}
+	for _, bp := range t.s.Breakpoints() {
+		if bp.File == path && bp.Line != line {
+			lines = append(lines, bp.Line)
+		}
+	}
+	if on {
+		lines = append(lines, line)
+	}
+	t.s.SetBreakpoints(file, lines)
+}
+
+// print evaluates and shows an expression.
+func (t *terminal) print(expr string) {
+	if expr == "" {
+		fmt.Fprintln(t.out, "Expected an expression")
+		return
+	}
+	res, err := t.s.Eval(expr, t.env())
+	if err != nil {
+		fmt.Fprintf(t.out, "Error: %s\n", err)
+		return
+	}
+	fmt.Fprintln(t.out, res.Inspect())
+}
+
+// showWatches shows the watch expressions.
+func (t *terminal) showWatches() {
+	for i, w := range t.watches {
+		val := ""
+		if res, err := t.s.Eval(w, t.env()); err != nil {
+			val = "error: " + err.Error()
+		} else {
+			val = res.Inspect()
+		}
+		fmt.Fprintf(t.out, "%d: %s = %s\n", i+1, w, val)
+	}
+}
+
+// showEnv shows the variables in each scope of the selected frame, except
+// for the functions and namespaced names at the top level, which are mostly
+// the stdlib.
+func (t *terminal) showEnv() {
+	for env := t.env(); env != nil; env = env.Outer() {
+		vars := env.Vars()
+		names := []string{}
+		for name, v := range vars {
+			_, fn := v.(*object.Function)
+			if env.IsTopLevel() && (fn || strings.Contains(name, ".")) {
+				continue
+			}
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		if env.IsTopLevel() {
+			fmt.Fprintln(t.out, "top level:")
+		} else {
+			fmt.Fprintln(t.out, "scope:")
+		}
+		for _, name := range names {
+			fmt.Fprintf(t.out, "  %s = %s\n", name, vars[name].Inspect())
+		}
+	}
+}
+
+// showLine shows a line of a file.
+func (t *terminal) showLine(file string, line int) {
+	lines := t.source(file)
+	if line >= 1 && line <= len(lines) {
+		fmt.Fprintf(t.out, "%4d | %s\n", line, lines[line-1])
+	}
+}
+
+// source returns the lines of a file, which may be part of the stdlib.
+func (t *terminal) source(file string) []string {
+	if lines, ok := t.sources[file]; ok {
+		return lines
+	}
+	src := ""
+	for _, f := range evaluator.StdlibFiles() {
+		if f.Name == file {
+			src = f.Source
+		}
+	}
+	if src == "" {
+		if b, err := ioutil.ReadFile(file); err == nil {
+			src = string(b)
+		}
+	}
+	var lines []string
+	if src != "" {
+		lines = strings.Split(src, "\n")
+	}
+	t.sources[file] = lines
+	return lines
+}
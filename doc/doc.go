@@ -0,0 +1,243 @@
+// Package doc implements keai doc. It collects the docstrings of the
+// functions a program defines with let, and of the Go builtins, and renders
+// them as Markdown or HTML, grouped by namespace (array.*, string.*, and so
+// on).
+package doc
+
+import (
+	"bytes"
+	"html/template"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zautumnz/keai/ast"
+)
+
+// Param is a function parameter.
+type Param struct {
+	Name string
+
+	// Default is the source of its default value, if it has one
+	Default string
+}
+
+// Entry is a documented function.
+type Entry struct {
+	Name string
+
+	// Params is nil for builtins, whose docstrings say how they're called
+	Params []Param
+
+	Doc     string
+	Builtin bool
+}
+
+// Namespace returns the part of the entry's name before the first period,
+// or an empty string if it doesn't have one.
+func (e Entry) Namespace() string {
+	if i := strings.Index(e.Name, "."); i > 0 {
+		return e.Name[:i]
+	}
+	return ""
+}
+
+// Signature returns how the function is called, like `array.map(fnc)`.
+// For a builtin that's the start of its docstring, if it starts with its
+// name and parameters.
+func (e Entry) Signature() string {
+	if e.Builtin {
+		if strings.HasPrefix(e.Doc, e.Name+"(") {
+			if i := strings.Index(e.Doc, ")"); i > 0 {
+				return e.Doc[:i+1]
+			}
+		}
+		return e.Name
+	}
+
+	params := []string{}
+	for _, p := range e.Params {
+		if p.Default != "" {
+			params = append(params, p.Name+" = "+p.Default)
+		} else {
+			params = append(params, p.Name)
+		}
+	}
+	return e.Name + "(" + strings.Join(params, ", ") + ")"
+}
+
+// FromProgram returns the documented functions a program defines with a
+// top-level let.
+func FromProgram(program *ast.Program) []Entry {
+	entries := []Entry{}
+	for _, stmt := range program.Statements {
+		if e, ok := stmt.(*ast.ExportStatement); ok {
+			stmt = e.Statement
+		}
+		l, ok := stmt.(*ast.LetStatement)
+		if !ok || l.Name == nil {
+			continue
+		}
+		fn, ok := l.Value.(*ast.FunctionLiteral)
+		if !ok || fn.DocString == nil {
+			continue
+		}
+
+		entries = append(entries, Function(
+			l.Name.Value,
+			fn.Parameters,
+			fn.Defaults,
+			fn.DocString.Value,
+		))
+	}
+	return entries
+}
+
+// Function returns the entry for a function, given its parameters, their
+// defaults, and its docstring.
+func Function(
+	name string,
+	params []*ast.Identifier,
+	defaults map[string]ast.Expression,
+	docstring string,
+) Entry {
+	e := Entry{Name: name, Doc: dedent(docstring)}
+	for _, p := range params {
+		param := Param{Name: p.Value}
+		if d, ok := defaults[p.Value]; ok {
+			param.Default = d.String()
+			if str, ok := d.(*ast.StringLiteral); ok {
+				param.Default = strconv.Quote(str.Value)
+			}
+		}
+		e.Params = append(e.Params, param)
+	}
+	return e
+}
+
+// dedent removes the indentation docstrings' later lines get from the
+// function they're in.
+func dedent(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i := 1; i < len(lines); i++ {
+		if len(lines[i]) >= indent && indent > 0 {
+			lines[i] = lines[i][indent:]
+		} else {
+			lines[i] = strings.TrimSpace(lines[i])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Group is the entries in a namespace.
+type Group struct {
+	// Namespace is empty for names without one
+	Namespace string
+	Entries   []Entry
+}
+
+// Title returns the heading for the group.
+func (g Group) Title() string {
+	if g.Namespace == "" {
+		return "Globals"
+	}
+	return g.Namespace
+}
+
+// Groups sorts entries into namespaces, with the globals first. Later
+// entries replace earlier ones with the same name.
+func Groups(entries []Entry) []Group {
+	byName := map[string]Entry{}
+	for _, e := range entries {
+		byName[e.Name] = e
+	}
+	names := []string{}
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	groups := []Group{}
+	index := map[string]int{}
+	for _, name := range names {
+		e := byName[name]
+		i, ok := index[e.Namespace()]
+		if !ok {
+			i = len(groups)
+			index[e.Namespace()] = i
+			groups = append(groups, Group{Namespace: e.Namespace()})
+		}
+		groups[i].Entries = append(groups[i].Entries, e)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Namespace < groups[j].Namespace
+	})
+	return groups
+}
+
+// Markdown renders groups as Markdown.
+func Markdown(title string, groups []Group) string {
+	var out strings.Builder
+	out.WriteString("# " + title + "\n\n")
+	for _, g := range groups {
+		out.WriteString("* [" + g.Title() + "](#" + strings.ToLower(g.Title()) +
+			")\n")
+	}
+	for _, g := range groups {
+		out.WriteString("\n## " + g.Title() + "\n")
+		for _, e := range g.Entries {
+			out.WriteString("\n### `" + e.Signature() + "`\n\n")
+			out.WriteString(e.Doc + "\n")
+		}
+	}
+	return out.String()
+}
+
+var page = template.Must(template.New("doc").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: auto; }
+code { font-size: 1.1em; }
+p { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<ul>
+{{- range .Groups}}
+<li><a href="#{{.Title}}">{{.Title}}</a></li>
+{{- end}}
+</ul>
+{{- range .Groups}}
+<h2 id="{{.Title}}">{{.Title}}</h2>
+{{- range .Entries}}
+<h3 id="{{.Name}}"><code>{{.Signature}}</code></h3>
+<p>{{.Doc}}</p>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+// HTML renders groups as an HTML page.
+func HTML(title string, groups []Group) string {
+	var out bytes.Buffer
+	page.Execute(&out, struct {
+		Title  string
+		Groups []Group
+	}{title, groups})
+	return out.String()
+}
@@ -0,0 +1,88 @@
+package doc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zautumnz/keai/lexer"
+	"github.com/zautumnz/keai/parser"
+)
+
+func TestFromProgram(t *testing.T) {
+	input := `let string.shout = fn (s, bang = "!") {
+    'string.shout upper-cases a string.
+        It keeps relative
+            indentation.'
+
+    s
+}
+export let greet = fn (name) {
+    'greet says hello.'
+    name
+}
+let undocumented = fn () { 1 }
+let x = 1`
+	p := parser.New(lexer.New(input))
+	entries := FromProgram(p.ParseProgram())
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d: %v", len(entries), entries)
+	}
+
+	if entries[0].Signature() != `string.shout(s, bang = "!")` {
+		t.Errorf("wrong signature: %s", entries[0].Signature())
+	}
+	expected := "string.shout upper-cases a string.\n" +
+		"It keeps relative\n    indentation."
+	if entries[0].Doc != expected {
+		t.Errorf("expected %q, got %q", expected, entries[0].Doc)
+	}
+	if entries[1].Name != "greet" || entries[1].Doc != "greet says hello." {
+		t.Errorf("wrong exported entry: %v", entries[1])
+	}
+}
+
+func TestRender(t *testing.T) {
+	entries := []Entry{
+		{Name: "string.shout", Params: []Param{{Name: "s"}}, Doc: "shouts"},
+		{
+			Name:    "print",
+			Doc:     "print(...) writes <values>.",
+			Builtin: true,
+		},
+		{Name: "array.sum", Doc: "sums"},
+	}
+	groups := Groups(entries)
+	titles := []string{}
+	for _, g := range groups {
+		titles = append(titles, g.Title())
+	}
+	if strings.Join(titles, " ") != "Globals array string" {
+		t.Errorf("wrong groups: %v", titles)
+	}
+
+	md := Markdown("lib", groups)
+	for _, s := range []string{
+		"# lib\n",
+		"* [array](#array)\n",
+		"## Globals\n\n### `print(...)`\n\nprint(...) writes <values>.\n",
+		"### `string.shout(s)`\n\nshouts\n",
+	} {
+		if !strings.Contains(md, s) {
+			t.Errorf("markdown doesn't contain %q:\n%s", s, md)
+		}
+	}
+
+	html := HTML("lib", groups)
+	for _, s := range []string{
+		"<title>lib</title>",
+		`<h2 id="array">array</h2>`,
+		"<p>print(...) writes &lt;values&gt;.</p>",
+	} {
+		if !strings.Contains(html, s) {
+			t.Errorf("html doesn't contain %q:\n%s", s, html)
+		}
+	}
+}
@@ -10,13 +10,15 @@ syn cluster     keaiEverything      contains=keaiMutable,keaiLet,keaiDeclaration
 syn keyword     keaiImport          import  contained
 syn keyword     keaiMutable         mutable contained
 syn keyword     keaiLet             let     contained
+syn keyword     keaiExport          export
 hi def link     keaiImport          Statement
+hi def link     keaiExport          Statement
 hi def link     keaiMutable         Keyword
 hi def link     keaiLet             Keyword
 hi def link     keaiDeclaration     Keyword
 
 " Keywords within functions
-syn keyword     keaiStatement         return null
+syn keyword     keaiStatement         return null yield
 syn keyword     keaiConditional       if else
 syn keyword     keaiRepeat            for foreach in
 hi def link     keaiStatement         Statement
@@ -26,6 +28,7 @@ hi def link     keaiRepeat            Repeat
 " Predefined functions and values
 syn keyword     keaiBuiltins
             \ array
+            \ assert
             \ core
             \ error
             \ float
@@ -34,6 +37,7 @@ syn keyword     keaiBuiltins
             \ http
             \ import
             \ integer
+            \ iter
             \ json
             \ math
             \ net
@@ -115,7 +119,7 @@ syn match keaiOperator /\/\%(=\|\ze[^/*]\)/
 " and corresponding three-char operators: <<= >>= &^=
 syn match keaiOperator /\%(<<\|>>\|&^\)=\?/
 " match remaining two-char operators: := && || <- ++ --
-syn match keaiOperator /:=\|||\|<-\|++\|--/
+syn match keaiOperator /:=\|||\|<-\|++\|--\|??\|?\./
 " match ...
 hi def link     keaiMutableArgs       keaiOperator
 hi def link     keaiOperator          Operator
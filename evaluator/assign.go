@@ -0,0 +1,183 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/zautumnz/keai/ast"
+	"github.com/zautumnz/keai/object"
+	"github.com/zautumnz/keai/utils"
+)
+
+// evalElementAssignment assigns to an element of an array or hash, like
+// `xs[0] = 1` or `h.count += 1`. The variable gets a copy with the element
+// replaced, which only copies the arrays and hashes on the way to the
+// element and shares everything else with the original. The variable owns
+// that copy until it's read, so filling an array in a loop changes the
+// copy in place instead of copying it again every time.
+func evalElementAssignment(a *ast.AssignStatement, val OBJ, env *ENV) OBJ {
+	res := assignElement(a, val, env)
+	if isError(res) {
+		fmt.Printf("Error: %s\n", res.Inspect())
+		utils.ExitConditionally(1)
+	}
+	return res
+}
+
+func assignElement(a *ast.AssignStatement, val OBJ, env *ENV) OBJ {
+	name := a.Name.Value
+	if env.IsConstant(name) {
+		return NewError(
+			"cannot assign to an element of %s; it was defined with let",
+			name,
+		)
+	}
+
+	// the indexes from the variable to the element, outermost first
+	indexes := []OBJ{}
+	for target := a.Target; ; {
+		index := Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+		indexes = append([]OBJ{index}, indexes...)
+		left, ok := target.Left.(*ast.IndexExpression)
+		if !ok {
+			break
+		}
+		target = left
+	}
+
+	// the indexes are evaluated first, since they could read the variable
+	root, owned := env.Owned(name)
+	if !owned {
+		var ok bool
+		if root, ok = env.Get(name); !ok {
+			return NewError("%s is unknown", name)
+		}
+	}
+
+	res := val
+	updated := setElement(root, owned, indexes, func(current OBJ) OBJ {
+		if a.Operator != "=" {
+			res = evalInfixExpression(a.Operator, current, val, env)
+		}
+		return res
+	})
+	if isError(updated) {
+		return updated
+	}
+	env.Set(name, updated)
+	env.Own(name)
+	return res
+}
+
+// setElement returns a copy of an array or hash with the element at the
+// end of indexes replaced by what update returns for its current value.
+// If the container is owned, it's changed in place instead; the arrays and
+// hashes inside it are still copied, since they may be shared.
+func setElement(
+	container OBJ,
+	owned bool,
+	indexes []OBJ,
+	update func(OBJ) OBJ,
+) OBJ {
+	index := indexes[0]
+	elementOf := func(current OBJ) OBJ {
+		if len(indexes) > 1 {
+			return setElement(current, false, indexes[1:], update)
+		}
+		return update(current)
+	}
+
+	switch container := container.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return NewError("array index must be an integer, got %s",
+				index.Type())
+		}
+		idx, ok := elementIndex(i.Value, len(container.Elements))
+		if !ok {
+			return NewError("index %d out of range for array of length %d",
+				i.Value, len(container.Elements))
+		}
+		element := elementOf(container.Elements[idx])
+		if isError(element) {
+			return element
+		}
+		if owned {
+			container.Elements[idx] = element
+			return container
+		}
+		elements := make([]OBJ, len(container.Elements))
+		copy(elements, container.Elements)
+		elements[idx] = element
+		return &object.Array{Elements: elements}
+
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return NewError("unusable as hash key: %s", index.Type())
+		}
+		var current OBJ = NULL
+		if pair, ok := container.Pairs[key.HashKey()]; ok {
+			current = pair.Value
+		}
+		value := elementOf(current)
+		if isError(value) {
+			return value
+		}
+		pair := object.HashPair{Key: index, Value: value}
+		if owned {
+			container.Pairs[key.HashKey()] = pair
+			return container
+		}
+		pairs := make(map[object.HashKey]object.HashPair, len(container.Pairs)+1)
+		for k, v := range container.Pairs {
+			pairs[k] = v
+		}
+		pairs[key.HashKey()] = pair
+		return &object.Hash{Pairs: pairs}
+	}
+
+	return NewError("can't assign to an element of %s", container.Type())
+}
+
+// evalOwnedElement reads an element of an array its variable owns, like
+// `xs[i - 1]`, without reading the variable, so that it keeps owning it.
+// That's only done for integer indexes made of names, numbers, and
+// operators; anything else might get hold of the array.
+func evalOwnedElement(node *ast.IndexExpression, env *ENV) (OBJ, bool) {
+	id, ok := node.Left.(*ast.Identifier)
+	if !ok || node.Member() || !plainIndex(node.Index) {
+		return nil, false
+	}
+	array, owned := env.Owned(id.Value)
+	if _, ok := array.(*object.Array); !ok || !owned {
+		return nil, false
+	}
+	index := Eval(node.Index, env)
+	if returnsEarly(index) {
+		return index, true
+	}
+	if _, ok := index.(*object.Integer); !ok {
+		// a method, like xs["len"], holds on to the array
+		env.Get(id.Value)
+	}
+	return evalIndexExpression(array, index, env), true
+}
+
+func plainIndex(exp ast.Expression) bool {
+	switch exp := exp.(type) {
+	case *ast.Identifier, *ast.IntegerLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return plainIndex(exp.Right)
+	case *ast.InfixExpression:
+		return plainIndex(exp.Left) && plainIndex(exp.Right)
+	case *ast.IndexExpression:
+		return !exp.Member() && plainIndex(exp.Left) &&
+			plainIndex(exp.Index)
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package evaluator
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// bundleFs holds the files of a program built with `keai build`, if that's
+// what we're running. Imports, templates, and static files are looked up in
+// it before the real filesystem, the same way the stdlib is read from the
+// embedded filesystem in main.
+var bundleFs fs.FS
+
+// bundlePrefix marks module paths that live in the bundle.
+const bundlePrefix = "bundle:"
+
+// SetBundle registers the files of a bundled program.
+func SetBundle(fsys fs.FS) {
+	bundleFs = fsys
+}
+
+// BundleModulePath returns where the module imported as name is stored in
+// a bundle.
+func BundleModulePath(name string) string {
+	return path.Join("modules", filepath.ToSlash(name)+".keai")
+}
+
+// BundleAssetPath returns where the file or directory read from p is
+// stored in a bundle.
+func BundleAssetPath(p string) string {
+	p = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(p)), "/")
+	return path.Join("assets", p)
+}
+
+// findBundledModule returns the path of a module in the bundle, or an empty
+// string if it isn't there.
+func findBundledModule(name string) string {
+	if bundleFs == nil {
+		return ""
+	}
+	p := BundleModulePath(name)
+	if _, err := fs.Stat(bundleFs, p); err != nil {
+		return ""
+	}
+	return bundlePrefix + p
+}
+
+// readModuleFile reads a module found by FindModule.
+func readModuleFile(filename string) ([]byte, error) {
+	if strings.HasPrefix(filename, bundlePrefix) {
+		return fs.ReadFile(bundleFs, strings.TrimPrefix(filename, bundlePrefix))
+	}
+	return ioutil.ReadFile(filename)
+}
+
+// readAsset reads a file, from the bundle if it was bundled.
+func readAsset(p string) ([]byte, error) {
+	if bundleFs != nil {
+		if b, err := fs.ReadFile(bundleFs, BundleAssetPath(p)); err == nil {
+			return b, nil
+		}
+	}
+	return ioutil.ReadFile(p)
+}
+
+// assetDir returns a http.FileSystem for a directory, from the bundle if it
+// was bundled.
+func assetDir(p string) http.FileSystem {
+	if bundleFs != nil {
+		sub, err := fs.Sub(bundleFs, BundleAssetPath(p))
+		if err == nil {
+			if _, err := fs.Stat(sub, "."); err == nil {
+				return http.FS(sub)
+			}
+		}
+	}
+	return http.Dir(p)
+}
@@ -0,0 +1,33 @@
+package evaluator
+
+import (
+	"github.com/zautumnz/keai/ast"
+	"github.com/zautumnz/keai/object"
+)
+
+// skipped is what a link in a chain like a?.b.c() gives when a ?. before
+// it found null. Eval turns it into null once the chain ends, so it's
+// never seen outside of one.
+var skipped = &object.Null{}
+
+// link evaluates what a member access, index, slice, or call is used on.
+// Unlike Eval, it gives skipped as it is, so the rest of the chain can be
+// skipped too.
+func link(node ast.Expression, env *ENV) OBJ {
+	return evalContext(CTX, node, env)
+}
+
+// skips returns true if a member access, index, slice, or call should be
+// skipped, because what it's used on is null and it was written with ?.,
+// or because a link before it was skipped.
+func skips(node ast.Node, left OBJ) bool {
+	return left == skipped || (left == NULL && ast.Optional(node))
+}
+
+// unskip turns skipped into null.
+func unskip(obj OBJ) OBJ {
+	if obj == skipped {
+		return NULL
+	}
+	return obj
+}
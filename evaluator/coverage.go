@@ -0,0 +1,414 @@
+package evaluator
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/zautumnz/keai/ast"
+)
+
+// coverBlock is a statement being covered. It spans the statement without
+// any blocks nested in it, which are covered separately.
+type coverBlock struct {
+	startLine, startCol int
+	endLine, endCol     int
+	count               int
+}
+
+// coverBranch counts which way an if went.
+type coverBranch struct {
+	coverBlock
+	then, els int
+}
+
+// coverFile is a file being covered.
+type coverFile struct {
+	name     string
+	src      string
+	blocks   []*coverBlock
+	branches []*coverBranch
+
+	// the same file can be parsed more than once (the stdlib is evaluated
+	// into every new environment), so blocks and branches are found by
+	// where they start
+	blockAt  map[[2]int]*coverBlock
+	branchAt map[[2]int]*coverBranch
+}
+
+// Coverage records which statements and branches of a program ran. Set
+// one with SetCoverage before evaluating anything.
+type Coverage struct {
+	files    []*coverFile
+	byName   map[string]*coverFile
+	blocks   map[ast.Node]*coverBlock
+	branches map[*ast.IfExpression]*coverBranch
+}
+
+// coverage is the recorder in use, if any.
+var coverage *Coverage
+
+// NewCoverage returns an empty coverage recorder.
+func NewCoverage() *Coverage {
+	return &Coverage{
+		byName:   map[string]*coverFile{},
+		blocks:   map[ast.Node]*coverBlock{},
+		branches: map[*ast.IfExpression]*coverBranch{},
+	}
+}
+
+// SetCoverage sets the recorder programs are covered by; nil turns
+// coverage off.
+func SetCoverage(c *Coverage) {
+	coverage = c
+}
+
+// Cover registers a parsed file, so its statements are covered when it's
+// evaluated. It does nothing if coverage is off.
+func Cover(name, src string, program *ast.Program) {
+	if coverage != nil {
+		coverage.add(name, src, program)
+	}
+}
+
+// extent finds where a statement starts and ends, leaving out blocks
+// nested in it. ok is false if none of it came from source.
+func extent(stmt ast.Node) (b coverBlock, ok bool) {
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		if _, isBlock := n.(*ast.BlockStatement); isBlock && n != stmt {
+			return false
+		}
+		tok := ast.TokenOf(n)
+		if tok.Line == 0 {
+			return true
+		}
+		if !ok || tok.Line < b.startLine ||
+			(tok.Line == b.startLine && tok.Column < b.startCol) {
+			b.startLine, b.startCol = tok.Line, tok.Column
+		}
+		if !ok || tok.EndLine > b.endLine ||
+			(tok.EndLine == b.endLine && tok.EndColumn > b.endCol) {
+			b.endLine, b.endCol = tok.EndLine, tok.EndColumn
+		}
+		ok = true
+		return true
+	})
+	return b, ok
+}
+
+func (c *Coverage) add(name, src string, program *ast.Program) {
+	f, ok := c.byName[name]
+	if !ok {
+		f = &coverFile{
+			name:     name,
+			src:      src,
+			blockAt:  map[[2]int]*coverBlock{},
+			branchAt: map[[2]int]*coverBranch{},
+		}
+		c.byName[name] = f
+		c.files = append(c.files, f)
+	}
+
+	var statements func(stmts []ast.Statement)
+	statements = func(stmts []ast.Statement) {
+		for _, s := range stmts {
+			b, ok := extent(s)
+			if !ok {
+				continue
+			}
+			pos := [2]int{b.startLine, b.startCol}
+			block, seen := f.blockAt[pos]
+			if !seen {
+				block = &b
+				f.blockAt[pos] = block
+				f.blocks = append(f.blocks, block)
+			}
+			c.blocks[s] = block
+		}
+	}
+
+	statements(program.Statements)
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStatement:
+			statements(n.Statements)
+		case *ast.IfExpression:
+			b, ok := extent(n)
+			if !ok {
+				return true
+			}
+			pos := [2]int{b.startLine, b.startCol}
+			br, seen := f.branchAt[pos]
+			if !seen {
+				br = &coverBranch{coverBlock: b}
+				f.branchAt[pos] = br
+				f.branches = append(f.branches, br)
+			}
+			c.branches[n] = br
+		}
+		return true
+	})
+}
+
+// hit counts a statement running.
+func (c *Coverage) hit(node ast.Node) {
+	if b, ok := c.blocks[node]; ok {
+		b.count++
+	}
+}
+
+// branch counts which way an if went.
+func (c *Coverage) branch(ie *ast.IfExpression, taken bool) {
+	if br, ok := c.branches[ie]; ok {
+		if taken {
+			br.then++
+		} else {
+			br.els++
+		}
+	}
+}
+
+// Summary counts the statements and branches (each if has two) that were
+// covered, out of how many there are.
+func (c *Coverage) Summary() (stmts, stmtsRun, branches, branchesRun int) {
+	for _, f := range c.files {
+		s, sr, b, br := f.summary()
+		stmts += s
+		stmtsRun += sr
+		branches += b
+		branchesRun += br
+	}
+	return
+}
+
+func (f *coverFile) summary() (stmts, stmtsRun, branches, branchesRun int) {
+	stmts = len(f.blocks)
+	for _, b := range f.blocks {
+		if b.count > 0 {
+			stmtsRun++
+		}
+	}
+	branches = 2 * len(f.branches)
+	for _, br := range f.branches {
+		if br.then > 0 {
+			branchesRun++
+		}
+		if br.els > 0 {
+			branchesRun++
+		}
+	}
+	return
+}
+
+// percent formats part of a whole.
+func percent(part, whole int) string {
+	if whole == 0 {
+		return "100.0%"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(part)/float64(whole))
+}
+
+// String summarizes the coverage on one line.
+func (c *Coverage) String() string {
+	s, sr, b, br := c.Summary()
+	return fmt.Sprintf(
+		"coverage: %s of statements, %s of branches",
+		percent(sr, s),
+		percent(br, b),
+	)
+}
+
+// sortedFiles returns the files sorted by name, and their blocks by
+// position.
+func (c *Coverage) sortedFiles() []*coverFile {
+	files := append([]*coverFile{}, c.files...)
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].name < files[j].name
+	})
+	for _, f := range files {
+		sort.Slice(f.blocks, func(i, j int) bool {
+			a, b := f.blocks[i], f.blocks[j]
+			if a.startLine != b.startLine {
+				return a.startLine < b.startLine
+			}
+			return a.startCol < b.startCol
+		})
+	}
+	return files
+}
+
+// WriteProfile writes the coverage in the format of Go's coverage.out, with
+// a block per statement.
+func (c *Coverage) WriteProfile(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "mode: count"); err != nil {
+		return err
+	}
+	for _, f := range c.sortedFiles() {
+		for _, b := range f.blocks {
+			_, err := fmt.Fprintf(
+				w,
+				"%s:%d.%d,%d.%d 1 %d\n",
+				f.name,
+				b.startLine,
+				b.startCol,
+				b.endLine,
+				b.endCol,
+				b.count,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// classes for each part of the HTML report
+const (
+	coverNone = iota
+	coverRun
+	coverMissed
+	coverPartial
+)
+
+// render returns the source of a file as HTML, with each statement marked
+// by whether it ran, and ifs that only went one way marked as partial.
+func (f *coverFile) render() string {
+	lines := strings.Split(f.src, "\n")
+	marks := make([][]int, len(lines))
+	for i, l := range lines {
+		marks[i] = make([]int, len([]rune(l))+1)
+	}
+
+	mark := func(b *coverBlock, class int) {
+		for line := b.startLine; line <= b.endLine; line++ {
+			if line < 1 || line > len(marks) {
+				continue
+			}
+			m := marks[line-1]
+			from, to := 0, len(m)
+			if line == b.startLine {
+				from = b.startCol - 1
+			}
+			if line == b.endLine && b.endCol-1 < to {
+				to = b.endCol - 1
+			}
+			for i := from; i >= 0 && i < to; i++ {
+				m[i] = class
+			}
+		}
+	}
+	for _, b := range f.blocks {
+		if b.count > 0 {
+			mark(b, coverRun)
+		} else {
+			mark(b, coverMissed)
+		}
+	}
+	for _, br := range f.branches {
+		if (br.then > 0) != (br.els > 0) {
+			mark(&br.coverBlock, coverPartial)
+		}
+	}
+
+	classes := []string{"", "run", "missed", "partial"}
+	var out strings.Builder
+	for i, l := range lines {
+		fmt.Fprintf(&out, "<span class=\"ln\">%5d</span> ", i+1)
+		class := coverNone
+		for j, r := range []rune(l) {
+			if marks[i][j] != class {
+				if class != coverNone {
+					out.WriteString("</span>")
+				}
+				class = marks[i][j]
+				if class != coverNone {
+					fmt.Fprintf(&out, "<span class=\"%s\">", classes[class])
+				}
+			}
+			out.WriteString(html.EscapeString(string(r)))
+		}
+		if class != coverNone {
+			out.WriteString("</span>")
+		}
+		out.WriteString("\n")
+	}
+	return out.String()
+}
+
+// WriteHTML writes a report showing the source of every covered file, with
+// a menu to switch between them.
+func (c *Coverage) WriteHTML(w io.Writer) error {
+	var out strings.Builder
+	out.WriteString(coverHTMLHead)
+	fmt.Fprintf(&out, "<p>%s</p>\n", html.EscapeString(c.String()))
+
+	files := c.sortedFiles()
+	out.WriteString("<select onchange=\"show(this.value)\">\n")
+	for i, f := range files {
+		s, sr, b, br := f.summary()
+		fmt.Fprintf(
+			&out,
+			"<option value=\"file%d\">%s (%s, %s of branches)</option>\n",
+			i,
+			html.EscapeString(f.name),
+			percent(sr, s),
+			percent(br, b),
+		)
+	}
+	out.WriteString("</select>\n")
+
+	for i, f := range files {
+		display := "none"
+		if i == 0 {
+			display = "block"
+		}
+		fmt.Fprintf(
+			&out,
+			"<pre class=\"file\" id=\"file%d\" style=\"display: %s\">%s</pre>\n",
+			i,
+			display,
+			f.render(),
+		)
+	}
+	out.WriteString(coverHTMLFoot)
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+const coverHTMLHead = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>keai coverage</title>
+<style>
+body { background: #fff; color: #222; font-family: sans-serif; }
+pre { font-family: monospace; line-height: 1.3; }
+.ln { color: #999; }
+.run { background: #c8f0c8; }
+.missed { background: #f5c0c0; }
+.partial { background: #f5e6a8; }
+</style>
+</head>
+<body>
+<p>
+<span class="run">ran</span>
+<span class="missed">didn't run</span>
+<span class="partial">only went one way</span>
+</p>
+`
+
+const coverHTMLFoot = `<script>
+function show(id) {
+    var files = document.getElementsByClassName("file");
+    for (var i = 0; i < files.length; i++) {
+        files[i].style.display = files[i].id === id ? "block" : "none";
+    }
+}
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,70 @@
+package evaluator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zautumnz/keai/lexer"
+	"github.com/zautumnz/keai/object"
+	"github.com/zautumnz/keai/parser"
+)
+
+func TestCoverage(t *testing.T) {
+	input := `let f = fn (x) {
+    if x > 1 {
+        "big"
+    } else {
+        "small"
+    }
+}
+f(2)
+f(3)
+`
+	cov := NewCoverage()
+	SetCoverage(cov)
+	defer SetCoverage(nil)
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+	Cover("f.keai", input, program)
+	Eval(program, object.NewEnvironment())
+
+	var out strings.Builder
+	if err := cov.WriteProfile(&out); err != nil {
+		t.Fatal(err)
+	}
+	expected := `mode: count
+f.keai:1.1,1.14 1 1
+f.keai:2.5,2.13 1 2
+f.keai:3.9,3.14 1 2
+f.keai:5.9,5.16 1 0
+f.keai:8.1,8.4 1 1
+f.keai:9.1,9.4 1 1
+`
+	if out.String() != expected {
+		t.Errorf("wrong profile. expected=\n%s\ngot=\n%s", expected, out.String())
+	}
+
+	stmts, stmtsRun, branches, branchesRun := cov.Summary()
+	if stmts != 6 || stmtsRun != 5 || branches != 2 || branchesRun != 1 {
+		t.Errorf(
+			"wrong summary. got=%d/%d statements, %d/%d branches",
+			stmtsRun,
+			stmts,
+			branchesRun,
+			branches,
+		)
+	}
+
+	out.Reset()
+	if err := cov.WriteHTML(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		`<span class="missed">&#34;small&#34;</span>`,
+		`<span class="partial">if x &gt; 1</span>`,
+	} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("expected HTML report to contain %q", s)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package evaluator
+
+// Debugger is told about each statement of a registered file before it
+// runs, and the program waits for it to return, so it can pause there. Set
+// one with SetDebugger.
+type Debugger interface {
+	Statement(file string, line int, env *ENV)
+}
+
+// debugger is the debugger in use, if any.
+var debugger Debugger
+
+// SetDebugger sets the debugger programs are paused by; nil turns it off.
+// Files have to be registered after it's set for it to see them.
+func SetDebugger(d Debugger) {
+	debugger = d
+	setTracing()
+}
@@ -0,0 +1,114 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/zautumnz/keai/ast"
+	"github.com/zautumnz/keai/object"
+	"github.com/zautumnz/keai/utils"
+)
+
+// bind destructures a value with a pattern, calling set (env.Set or
+// env.SetLet) for each name it binds. Defaults are evaluated in env. It
+// returns an error if the value doesn't have the pattern's shape, and nil
+// otherwise.
+func bind(
+	pattern ast.Expression,
+	val OBJ,
+	env *ENV,
+	set func(string, OBJ) OBJ,
+) OBJ {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		set(pattern.Value, val)
+	case *ast.ArrayPattern:
+		elements, _, ok := patternElements(pattern, val)
+		if !ok {
+			return destructureError(pattern, val)
+		}
+		for i, e := range pattern.Elements {
+			var elem OBJ
+			if i < len(elements) {
+				elem = elements[i]
+			}
+			if err := bindElement(e, elem, env, set); err != nil {
+				return err
+			}
+		}
+		if pattern.Rest != nil {
+			rest := []OBJ{}
+			if len(pattern.Elements) < len(elements) {
+				rest = append(rest, elements[len(pattern.Elements):]...)
+			}
+			set(pattern.Rest.Value, &object.Array{Elements: rest})
+		}
+	case *ast.HashPattern:
+		hash, ok := val.(*object.Hash)
+		if !ok {
+			return destructureError(pattern, val)
+		}
+		for _, e := range pattern.Pairs {
+			var value OBJ
+			key := &object.String{Value: e.Key}
+			if pair, ok := hash.Pairs[key.HashKey()]; ok {
+				value = pair.Value
+			}
+			if err := bindElement(e, value, env, set); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// patternElements returns the elements of an array or range for an array
+// pattern, and how many there are in all, or false for anything else. For
+// a range, it only works out as many as the pattern needs.
+func patternElements(pattern *ast.ArrayPattern, val OBJ) ([]OBJ, int, bool) {
+	switch val := val.(type) {
+	case *object.Array:
+		return val.Elements, len(val.Elements), true
+	case *object.Range:
+		n := int(val.Len())
+		if pattern.Rest != nil {
+			return val.Array().Elements, n, true
+		}
+		elements := []OBJ{}
+		for i := 0; i < n && i < len(pattern.Elements); i++ {
+			e, _ := val.At(int64(i))
+			elements = append(elements, &object.Integer{Value: e})
+		}
+		return elements, n, true
+	}
+	return nil, 0, false
+}
+
+// bindElement binds one part of a pattern, using its default if val is
+// missing (nil).
+func bindElement(
+	e *ast.PatternElement,
+	val OBJ,
+	env *ENV,
+	set func(string, OBJ) OBJ,
+) OBJ {
+	if val == nil {
+		if e.Default == nil {
+			val = NULL
+		} else {
+			val = Eval(e.Default, env)
+			if isError(val) {
+				return val
+			}
+		}
+	}
+	return bind(e.Target, val, env, set)
+}
+
+// destructureError reports a value that doesn't fit a pattern.
+func destructureError(pattern ast.Expression, val OBJ) OBJ {
+	err := NewError("can't destructure %s with %s",
+		val.Type(), pattern.String())
+	fmt.Printf("Error: %s\n", err.Inspect())
+	utils.ExitConditionally(1)
+	return err
+}
@@ -5,23 +5,21 @@ package evaluator
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
 	"math"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/zautumnz/keai/ast"
-	"github.com/zautumnz/keai/lexer"
 	"github.com/zautumnz/keai/object"
-	"github.com/zautumnz/keai/parser"
 	"github.com/zautumnz/keai/utils"
 )
 
 // pre-defined objects
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL  = object.NULL
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
 	CTX   = context.Background()
 )
 
@@ -36,7 +34,13 @@ var builtins = map[string]*object.Builtin{}
 
 // Eval is our core function for evaluating nodes.
 func Eval(node ast.Node, env *ENV) OBJ {
-	return evalContext(context.Background(), node, env)
+	return unskip(evalContext(CTX, node, env))
+}
+
+// SetContext sets the context Eval checks before evaluating each node, so
+// a running program can be cancelled (keai test uses it for timeouts).
+func SetContext(ctx context.Context) {
+	CTX = ctx
 }
 
 // evalContext is our core function for evaluating nodes.
@@ -50,6 +54,13 @@ func evalContext(ctx context.Context, node ast.Node, env *ENV) OBJ {
 		// noop
 	}
 
+	if coverage != nil {
+		coverage.hit(node)
+	}
+	if tracing {
+		traceStatement(node, env)
+	}
+
 	switch node := node.(type) {
 	//Statements
 	case *ast.Program:
@@ -68,7 +79,7 @@ func evalContext(ctx context.Context, node ast.Node, env *ENV) OBJ {
 		return NULL
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
-		if isError(right) {
+		if returnsEarly(right) {
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right)
@@ -76,11 +87,18 @@ func evalContext(ctx context.Context, node ast.Node, env *ENV) OBJ {
 		return evalPostfixExpression(env, node.Operator, node)
 	case *ast.InfixExpression:
 		left := Eval(node.Left, env)
-		if isError(left) {
+		if returnsEarly(left) {
 			return left
 		}
+		if node.Operator == "??" {
+			// the right side is only worked out if it's needed
+			if left != NULL {
+				return left
+			}
+			return Eval(node.Right, env)
+		}
 		right := Eval(node.Right, env)
-		if isError(right) {
+		if returnsEarly(right) {
 			return right
 		}
 		res := evalInfixExpression(node.Operator, left, right, env)
@@ -100,38 +118,90 @@ func evalContext(ctx context.Context, node ast.Node, env *ENV) OBJ {
 		return evalForLoopExpression(node, env)
 	case *ast.ForeachStatement:
 		return evalForeachExpression(node, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnValue, env)
+		if isReturn(val) {
+			return val
+		}
 		return &object.ReturnValue{Value: val}
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+	case *ast.YieldExpression:
+		return evalYieldExpression(node, env)
 	case *ast.MutableStatement:
 		val := Eval(node.Value, env)
+		if isReturn(val) {
+			return val
+		}
+		if node.Pattern != nil {
+			if err := bind(node.Pattern, val, env, env.Set); err != nil {
+				return err
+			}
+			return val
+		}
 		env.Set(node.Name.Value, val)
 		return val
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
+		if isReturn(val) {
+			return val
+		}
+		if node.Pattern != nil {
+			if err := bind(node.Pattern, val, env, env.SetLet); err != nil {
+				return err
+			}
+			return val
+		}
+		if err := checkNamespaceLet(node.Name.Value, env); err != nil {
+			return err
+		}
 		env.SetLet(node.Name.Value, val)
 		return val
+	case *ast.ExportStatement:
+		if !env.IsTopLevel() {
+			msg := "export is only allowed at the top level: " +
+				node.Statement.String()
+			fmt.Println(msg)
+			utils.ExitConditionally(1)
+			return NewError(msg)
+		}
+		val := Eval(node.Statement, env)
+		for _, name := range node.Statement.Names() {
+			env.Export(name.Value)
+		}
+		return val
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
 		defaults := node.Defaults
+		patterns := node.Patterns
 		docstring := node.DocString
 		return &object.Function{
 			Parameters: params,
 			Env:        env,
 			Body:       body,
 			Defaults:   defaults,
+			Patterns:   patterns,
 			DocString:  docstring,
+			Generator:  node.Generator,
 		}
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
+		function := link(node.Function, env)
+		if skips(node, function) {
+			return skipped
+		}
+		if returnsEarly(function) {
 			return function
 		}
 
 		args := evalExpression(node.Arguments, env)
+		if len(args) == 1 && isReturn(args[0]) {
+			return args[0]
+		}
 
 		// check for current args (...)
 		if len(args) > 0 {
@@ -151,10 +221,14 @@ func evalContext(ctx context.Context, node ast.Node, env *ENV) OBJ {
 				c = int(*t.Code)
 			}
 			if !t.BuiltinCall {
+				name, ok := ast.DottedName(node.Function)
+				if !ok {
+					name = node.Function.String()
+				}
 				fmt.Fprintf(
 					os.Stderr,
 					"Error calling `%s` : %s\n",
-					node.Function,
+					name,
 					res.Inspect(),
 				)
 				utils.ExitConditionally(c)
@@ -165,7 +239,7 @@ func evalContext(ctx context.Context, node ast.Node, env *ENV) OBJ {
 
 	case *ast.ArrayLiteral:
 		elements := evalExpression(node.Elements, env)
-		if len(elements) == 1 && isError(elements[0]) {
+		if len(elements) == 1 && returnsEarly(elements[0]) {
 			return elements[0]
 		}
 		return &object.Array{Elements: elements}
@@ -180,15 +254,26 @@ func evalContext(ctx context.Context, node ast.Node, env *ENV) OBJ {
 			IsCurrentArgs: true,
 		}
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
+		if val, ok := evalOwnedElement(node, env); ok {
+			return val
+		}
+		if val, ok := evalNamespaceMember(node, env); ok {
+			return val
+		}
+		left := link(node.Left, env)
+		if skips(node, left) {
+			return skipped
+		}
+		if isReturn(left) || (isError(left) && !node.Member()) {
 			return left
 		}
 		index := Eval(node.Index, env)
-		if isError(index) {
+		if returnsEarly(index) {
 			return index
 		}
 		return evalIndexExpression(left, index, env)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
 	case *ast.AssignStatement:
 		return evalAssignStatement(node, env)
 	case *ast.HashLiteral:
@@ -212,69 +297,6 @@ func evalBlockStatement(block *ast.BlockStatement, env *ENV) OBJ {
 	return result
 }
 
-// EvalModule evaluates the named module and returns a *object.Module object
-// This creates a whole new keai instance (lexer, parser, env, and evaluator),
-// which isn't ideal, but we also do this when working with string
-// interpolation.
-func EvalModule(name string) OBJ {
-	filename := FindModule(name)
-	if filename == "" {
-		return NewError("ImportError: no module named '%s'", name)
-	}
-
-	b, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return NewError("IOError: error reading module '%s': %s", name, err)
-	}
-
-	l := lexer.New(string(b))
-	p := parser.New(l)
-
-	module := p.ParseProgram()
-	if len(p.Errors()) != 0 {
-		return NewError("ParseError: %s", p.Errors())
-	}
-
-	env := object.NewEnvironment()
-	Eval(module, env)
-
-	return env.ExportedHash()
-}
-
-var importCache map[string]OBJ
-
-func init() {
-	importCache = make(map[string]OBJ)
-}
-
-func evalImportExpression(ie *ast.ImportExpression, env *ENV) OBJ {
-	// treat modules as singletons;
-	// we don't allow modifying anythig exported by modules, but this
-	// means we can skip re-evaling modules on subsequent imports
-	ev, ok := importCache[ie.Name.String()]
-	if ok {
-		return ev
-	}
-
-	name := Eval(ie.Name, env)
-	if isError(name) {
-		return name
-	}
-
-	if s, ok := name.(*object.String); ok {
-		attrs := EvalModule(s.Value)
-		if isError(attrs) {
-			return attrs
-		}
-
-		m := &object.Module{Name: s.Value, Attrs: attrs}
-		importCache[ie.Name.String()] = m
-		return m
-	}
-
-	return NewError("ImportError: invalid import path '%s'", name)
-}
-
 // for performance, using single instance of boolean
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
@@ -370,6 +392,17 @@ func evalNotPrefixOperatorExpression(right OBJ) OBJ {
 }
 
 func evalInfixExpression(operator string, left, right OBJ, env *ENV) OBJ {
+	// ranges are lazy arrays, so they work like arrays, but == still
+	// compares the range itself
+	if operator != "==" && operator != "!=" {
+		if r, ok := left.(*object.Range); ok {
+			left = r.Array()
+		}
+		if r, ok := right.(*object.Range); ok {
+			right = r.Array()
+		}
+	}
+
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
@@ -475,15 +508,7 @@ func evalIntegerInfixExpression(operator string, left, right OBJ) OBJ {
 		return &object.Integer{Value: leftVal >> uint64(rightVal)}
 
 	case "..":
-		len := int(rightVal-leftVal) + 1
-		array := make([]OBJ, len)
-		i := 0
-		for i < len {
-			array[i] = &object.Integer{Value: leftVal}
-			leftVal++
-			i++
-		}
-		return &object.Array{Elements: array}
+		return object.NewRange(leftVal, rightVal)
 	default:
 		return NewError("unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
@@ -642,10 +667,14 @@ func evalStringInfixExpression(operator string, left, right OBJ) OBJ {
 // otherwise.
 func evalIfExpression(ie *ast.IfExpression, env *ENV) OBJ {
 	condition := Eval(ie.Condition, env)
-	if isError(condition) {
+	if returnsEarly(condition) {
 		return condition
 	}
-	if isTruthy(condition) {
+	truthy := isTruthy(condition)
+	if coverage != nil {
+		coverage.branch(ie, truthy)
+	}
+	if truthy {
 		return Eval(ie.Consequence, env)
 	}
 	if ie.Alternative != nil {
@@ -654,12 +683,34 @@ func evalIfExpression(ie *ast.IfExpression, env *ENV) OBJ {
 	return NULL
 }
 
+// evalTryExpression handles `value?`, which returns an error from the
+// function it's in, adding where it was returned from to the error's stack.
+// Anything else is just the value.
+func evalTryExpression(te *ast.TryExpression, env *ENV) OBJ {
+	val := Eval(te.Value, env)
+	err, ok := val.(*object.Error)
+	if !ok {
+		return val
+	}
+
+	propagated := *err
+	propagated.Stack = append(
+		append([]string{}, err.Stack...),
+		fmt.Sprintf("line %d: %s", te.Token.Line, te.Value.String()),
+	)
+	return &object.ReturnValue{Value: &propagated, Try: true}
+}
+
 func evalAssignStatement(a *ast.AssignStatement, env *ENV) (val OBJ) {
 	evaluated := Eval(a.Value, env)
-	if isError(evaluated) {
+	if returnsEarly(evaluated) {
 		return evaluated
 	}
 
+	if a.Target != nil {
+		return evalElementAssignment(a, evaluated, env)
+	}
+
 	// An assignment is generally:
 	//    variable = value
 	// But we cheat and reuse the implementation for:
@@ -747,7 +798,7 @@ func evalForLoopExpression(fle *ast.ForLoopExpression, env *ENV) OBJ {
 	rt := TRUE
 	for {
 		condition := Eval(fle.Condition, env)
-		if isError(condition) {
+		if returnsEarly(condition) {
 			return condition
 		}
 		if isTruthy(condition) {
@@ -767,6 +818,9 @@ func evalForLoopExpression(fle *ast.ForLoopExpression, env *ENV) OBJ {
 func evalForeachExpression(fle *ast.ForeachStatement, env *ENV) OBJ {
 	// expression
 	val := Eval(fle.Value, env)
+	if isReturn(val) {
+		return val
+	}
 
 	helper, ok := val.(object.Iterable)
 	if !ok {
@@ -776,9 +830,16 @@ func evalForeachExpression(fle *ast.ForeachStatement, env *ENV) OBJ {
 		)
 	}
 
-	// The one/two values we're going to permit
+	// The one/two values we're going to permit, or the names in the
+	// pattern
 	var permit []string
-	permit = append(permit, fle.Ident)
+	if fle.Pattern != nil {
+		for _, name := range ast.PatternNames(fle.Pattern) {
+			permit = append(permit, name.Value)
+		}
+	} else {
+		permit = append(permit, fle.Ident)
+	}
 	if fle.Index != "" {
 		permit = append(permit, fle.Index)
 	}
@@ -794,9 +855,26 @@ func evalForeachExpression(fle *ast.ForeachStatement, env *ENV) OBJ {
 	// Get the initial values.
 	ret, idx, ok := helper.Next()
 
+	// Tell the iterable if we stop before it's done, so a generator's
+	// function doesn't wait forever to be resumed.
+	finished := false
+	if stopper, ok := helper.(object.Stopper); ok {
+		defer func() {
+			if !finished {
+				stopper.Stop()
+			}
+		}()
+	}
+
 	for ok {
 		// Set the index + name
-		child.Set(fle.Ident, ret)
+		if fle.Pattern != nil {
+			if err := bind(fle.Pattern, ret, child, child.Set); err != nil {
+				return err
+			}
+		} else {
+			child.Set(fle.Ident, ret)
+		}
 
 		idxName := fle.Index
 		if idxName != "" {
@@ -806,8 +884,9 @@ func evalForeachExpression(fle *ast.ForeachStatement, env *ENV) OBJ {
 		// Eval the block
 		rt := Eval(fle.Body, child)
 
-		// If we got an error/return then we handle it.
-		if !isError(rt) &&
+		// If we got an error/return then we handle it. An empty body
+		// gives nil.
+		if rt != nil && !isError(rt) &&
 			(rt.Type() == object.RETURN_VALUE_OBJ ||
 				rt.Type() == object.ERROR_OBJ) {
 			return rt
@@ -816,7 +895,13 @@ func evalForeachExpression(fle *ast.ForeachStatement, env *ENV) OBJ {
 		// Loop again
 		ret, idx, ok = helper.Next()
 	}
+	finished = true
 
+	if it, ok := helper.(*object.Iterator); ok && it.Err != nil {
+		fmt.Printf("Error: %s\n", it.Err.Inspect())
+		utils.ExitConditionally(1)
+		return it.Err
+	}
 	return NULL
 }
 
@@ -839,13 +924,41 @@ func evalProgram(program *ast.Program, env *ENV) OBJ {
 		result = Eval(statement, env)
 		switch result := result.(type) {
 		case *object.ReturnValue:
+			if result.Try {
+				reportTry(result.Value.(*object.Error))
+			}
 			return result.Value
+		case *object.Error:
+			// a failed import stops the program or module it's in
+			if result.Import {
+				return result
+			}
+		}
+		if err := failedImport(); err != nil {
+			return err
 		}
 	}
 
 	return result
 }
 
+// reportTry reports an error that `?` returned from the top level of a
+// program or module, where there's no function to return it from, the
+// same way as an error from a call.
+func reportTry(err *object.Error) {
+	c := 1
+	if err.Code != nil {
+		c = int(*err.Code)
+	}
+	fmt.Fprintf(
+		os.Stderr,
+		"Error at %s : %s\n",
+		err.Stack[len(err.Stack)-1],
+		err.Inspect(),
+	)
+	utils.ExitConditionally(c)
+}
+
 func isError(obj OBJ) bool {
 	if obj != nil {
 		return obj.Type() == object.ERROR_OBJ
@@ -853,6 +966,18 @@ func isError(obj OBJ) bool {
 	return false
 }
 
+// isReturn returns true if obj is on its way out of a function, from a
+// return statement or a `?`.
+func isReturn(obj OBJ) bool {
+	return obj != nil && obj.Type() == object.RETURN_VALUE_OBJ
+}
+
+// returnsEarly returns true if the rest of what obj is part of shouldn't
+// be evaluated, because it's an error or it's being returned.
+func returnsEarly(obj OBJ) bool {
+	return isError(obj) || isReturn(obj)
+}
+
 func evalIdentifier(node *ast.Identifier, env *ENV) OBJ {
 	if val, ok := env.Get(node.Value); ok {
 		return val
@@ -860,6 +985,9 @@ func evalIdentifier(node *ast.Identifier, env *ENV) OBJ {
 	if builtin, ok := builtins[node.Value]; ok {
 		return builtin
 	}
+	if IsNamespace(node.Value, env) {
+		return evalNamespace(node.Value, env)
+	}
 	fmt.Println("identifier not found: " + node.Value)
 	utils.ExitConditionally(1)
 	return NewError("identifier not found: " + node.Value)
@@ -869,7 +997,7 @@ func evalExpression(exps []ast.Expression, env *ENV) []OBJ {
 	var result []OBJ
 	for _, e := range exps {
 		evaluated := Eval(e, env)
-		if isError(evaluated) {
+		if returnsEarly(evaluated) {
 			return []OBJ{evaluated}
 		}
 		result = append(result, evaluated)
@@ -898,6 +1026,15 @@ func evalIndexExpression(left, index OBJ, env *ENV) OBJ {
 		return evalStringIndexExpression(left, index, env)
 	case left.Type() == object.MODULE_OBJ:
 		return evalModuleIndexExpression(left, index, env)
+	case left.Type() == object.RANGE_OBJ:
+		return evalRangeIndexExpression(left, index, env)
+	case left.Type() == object.ERROR_OBJ:
+		// errors have methods, like err.message(), but otherwise an
+		// error in an expression is what the whole thing evaluates to
+		if fn, ok := objectGetMethod(left, index, env); ok {
+			return fn
+		}
+		return left
 	default:
 		if fn, ok := objectGetMethod(left, index, env); ok {
 			return fn
@@ -912,13 +1049,27 @@ func evalModuleIndexExpression(module, index OBJ, env *ENV) OBJ {
 	return evalHashIndexExpression(moduleObject.Attrs, index, env)
 }
 
+func evalRangeIndexExpression(rng, index OBJ, env *ENV) OBJ {
+	if i, ok := index.(*object.Integer); ok {
+		r := rng.(*object.Range)
+		if idx, ok := elementIndex(i.Value, int(r.Len())); ok {
+			n, _ := r.At(idx)
+			return &object.Integer{Value: n}
+		}
+		return NULL
+	}
+	if fn, ok := objectGetMethod(rng, index, env); ok {
+		return fn
+	}
+	return NULL
+}
+
 func evalArrayIndexExpression(array, index OBJ, env *ENV) OBJ {
 	arrayObject := array.(*object.Array)
 	switch t := index.(type) {
 	case *object.Integer:
-		idx := t.Value
-		max := int64(len(arrayObject.Elements) - 1)
-		if idx < 0 || idx > max {
+		idx, ok := elementIndex(t.Value, len(arrayObject.Elements))
+		if !ok {
 			return NULL
 		}
 		return arrayObject.Elements[idx]
@@ -951,20 +1102,16 @@ func evalStringIndexExpression(input, index OBJ, env *ENV) OBJ {
 	str := input.(*object.String).Value
 	switch t := index.(type) {
 	case *object.Integer:
-		idx := t.Value
-		max := int64(len(str))
-		if idx < 0 || idx > max {
-			return NULL
-		}
-
 		// Get the characters as an array of runes
 		chars := []rune(str)
 
-		// Now index
-		ret := chars[idx]
+		idx, ok := elementIndex(t.Value, len(chars))
+		if !ok {
+			return NULL
+		}
 
 		// And return as a string.
-		return &object.String{Value: string(ret)}
+		return &object.String{Value: string(chars[idx])}
 	default:
 		if fn, ok := objectGetMethod(input, index, env); ok {
 			return fn
@@ -978,15 +1125,16 @@ func evalHashLiteral(node *ast.HashLiteral, env *ENV) OBJ {
 	pairs := make(map[object.HashKey]object.HashPair)
 	for keyNode, valueNode := range node.Pairs {
 		key := Eval(keyNode, env)
-		if isError(key) {
+		if returnsEarly(key) {
 			return key
 		}
 		hashKey, ok := key.(object.Hashable)
 		if !ok {
 			return NewError("unusable as hash key: %s", key.Type())
 		}
+		// errors are values here, so they can be the cause of others
 		value := Eval(valueNode, env)
-		if isError(value) {
+		if isReturn(value) {
 			return value
 		}
 		hashed := hashKey.HashKey()
@@ -1001,29 +1149,59 @@ func evalHashLiteral(node *ast.HashLiteral, env *ENV) OBJ {
 func ApplyFunction(env *ENV, fn OBJ, args []OBJ) OBJ {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendEnv := extendFunctionEnv(fn, args)
+		extendEnv, err := extendFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
+		if fn.Generator {
+			return newGenerator(fn, extendEnv)
+		}
+		if tracing {
+			defer traceCall(fn, extendEnv)()
+		}
 		evaluated := Eval(fn.Body, extendEnv)
 		return upwrapReturnValue(evaluated)
 	case *object.Builtin:
-		return fn.Fn(env, args...)
+		res := fn.Fn(env, args...)
+		if profiler != nil {
+			profiler.maybeSample()
+		}
+		return res
 	default:
 		return NewError("not a function: %s", fn.Type())
 	}
 }
 
-func extendFunctionEnv(fn *object.Function, args []OBJ) *ENV {
+func extendFunctionEnv(fn *object.Function, args []OBJ) (*ENV, OBJ) {
 	env := object.NewEnclosedEnvironment(fn.Env, args)
 
 	// Set the defaults
 	for key, val := range fn.Defaults {
-		env.Set(key, Eval(val, env))
+		if _, ok := fn.Patterns[key]; !ok {
+			env.Set(key, Eval(val, env))
+		}
 	}
 	for paramIdx, param := range fn.Parameters {
+		pattern, ok := fn.Patterns[param.Value]
+		if !ok {
+			if paramIdx < len(args) {
+				env.Set(param.Value, args[paramIdx])
+			}
+			continue
+		}
+
+		// Destructure the argument, or the default if it wasn't passed
+		var arg OBJ = NULL
 		if paramIdx < len(args) {
-			env.Set(param.Value, args[paramIdx])
+			arg = args[paramIdx]
+		} else if d, ok := fn.Defaults[param.Value]; ok {
+			arg = Eval(d, env)
+		}
+		if err := bind(pattern, arg, env, env.Set); err != nil {
+			return nil, err
 		}
 	}
-	return env
+	return env, nil
 }
 
 func upwrapReturnValue(obj OBJ) OBJ {
@@ -1034,12 +1212,40 @@ func upwrapReturnValue(obj OBJ) OBJ {
 }
 
 // RegisterBuiltin registers a built-in function. This is used to register
-// our "standard library" functions.
-func RegisterBuiltin(name string, fn object.BuiltinFunction) {
-	builtins[name] = &object.Builtin{Fn: fn}
+// our "standard library" functions. The docstring is what .doc() and keai
+// doc show for it, and should start with how it's called, like
+// `fs.open(path, [mode]) opens a file`.
+func RegisterBuiltin(name string, doc string, fn object.BuiltinFunction) {
+	builtins[name] = &object.Builtin{Fn: fn, Name: name, Doc: doc}
+}
+
+// BuiltinDoc returns the docstring of a builtin.
+func BuiltinDoc(name string) string {
+	if b, ok := builtins[name]; ok {
+		return b.Doc
+	}
+	return ""
+}
+
+// BuiltinNames returns the names of the registered builtins, sorted.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 func objectGetMethod(o, key OBJ, env *ENV) (ret OBJ, ok bool) {
+	// ranges are lazy arrays, and have all the methods arrays do
+	if r, isRange := o.(*object.Range); isRange {
+		if name, ok := key.(*object.String); ok &&
+			r.GetMethod(name.Value) == nil {
+			return objectGetMethod(r.Array(), key, env)
+		}
+	}
+
 	switch k := key.(type) {
 	case *object.String:
 		var fn object.BuiltinFunction
@@ -1122,6 +1328,8 @@ func objectToNativeBoolean(o OBJ) bool {
 			return false
 		}
 		return true
+	case *object.Range:
+		return obj.Len() != 0
 	case *object.Hash:
 		if len(obj.Pairs) == 0 {
 			return false
@@ -1144,6 +1352,9 @@ func evalSpread(node ast.Node, env *ENV) OBJ {
 		switch ao := val.(type) {
 		case *object.Array:
 			return &object.Array{Elements: ao.Elements, IsCurrentArgs: true}
+		case *object.Range:
+			elements := ao.Array().Elements
+			return &object.Array{Elements: elements, IsCurrentArgs: true}
 		default:
 			return NewError("spread expected an array, got %s", ao.Type())
 		}
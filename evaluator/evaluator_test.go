@@ -2,6 +2,10 @@ package evaluator
 
 import (
 	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/zautumnz/keai/lexer"
@@ -273,6 +277,225 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestTryExpression(t *testing.T) {
+	prelude := `
+let parse = fn (s) {
+    if s == "" {
+        return error({"message": "empty", "kind": "invalid", "code": 2})
+    }
+    s.to_i()
+}
+let double = fn (s) {
+    let n = parse(s)?
+    n * 2
+}
+let outer = fn (s) { [double(s)? + 1] }
+`
+	utils.SetReplOrRun(true)
+	evaluated := testEval(prelude + `outer("4")`)
+	if evaluated.Inspect() != "[9]" {
+		t.Errorf("expected [9], got %s", evaluated.Inspect())
+	}
+
+	evaluated = testEval(prelude + `outer("")`)
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("expected an error, got %s", evaluated.Inspect())
+	}
+	if err.Message != "empty" || err.Kind != "invalid" || *err.Code != 2 {
+		t.Errorf("wrong error: %s", err.Inspect())
+	}
+	stack := []string{"line 9: parse(s)", "line 12: double(s)"}
+	if !reflect.DeepEqual(err.Stack, stack) {
+		t.Errorf("expected stack %v, got %v", stack, err.Stack)
+	}
+
+	// values that aren't errors pass through, and so does the top level
+	testIntegerObject(t, testEval(`5?`), 5)
+	evaluated = testEval(`error("top")?; 1`)
+	if evaluated.Inspect() != "ERROR: top" {
+		t.Errorf("expected the error, got %s", evaluated.Inspect())
+	}
+
+	// outside of a REPL, that's reported and exits like a failed call
+	code := 0
+	utils.SetReplOrRun(false)
+	utils.ExitHandler = func(c int) { code = c }
+	defer func() {
+		utils.ExitHandler = nil
+		utils.SetReplOrRun(true)
+	}()
+	testEval(prelude + `foreach s in [""] { let x = parse(s)? }; 1`)
+	if code != 2 {
+		t.Errorf("expected an exit with code 2, got %d", code)
+	}
+}
+
+func TestErrorValues(t *testing.T) {
+	prelude := `let cause = error({"message": "empty", "data": [1, 2]});
+let e = error({"message": "bad", "kind": "invalid", "cause": cause});
+`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"e.message()", "bad"},
+		{"e.kind()", "invalid"},
+		{"e.code()", "null"},
+		{"e.cause().message()", "empty"},
+		{"e.cause().data()", "[1, 2]"},
+		{"e.data()", "null"},
+		{"e.stack()", "[]"},
+		{"e", "ERROR: bad; KIND: invalid; CAUSE: ERROR: empty; DATA: [1, 2]"},
+		{`error({"cause": 1})`, "ERROR: error.cause should be an error!"},
+		{`error({"kind": 1})`, "ERROR: error.kind should be string!"},
+	}
+	utils.SetReplOrRun(true)
+	for _, tt := range tests {
+		evaluated := testEval(prelude + tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected,
+				evaluated.Inspect())
+		}
+	}
+}
+
+func TestSliceExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[0, 1, 2, 3, 4][1:3]", "[1, 2]"},
+		{"[0, 1, 2, 3, 4][:-1]", "[0, 1, 2, 3]"},
+		{"[0, 1, 2, 3, 4][-2:]", "[3, 4]"},
+		{"[0, 1, 2, 3, 4][::2]", "[0, 2, 4]"},
+		{"[0, 1, 2, 3, 4][::-1]", "[4, 3, 2, 1, 0]"},
+		{"[0, 1, 2, 3, 4][3:0:-1]", "[3, 2, 1]"},
+		{"[0, 1, 2, 3, 4][-100:2]", "[0, 1]"},
+		{"[0, 1, 2, 3, 4][3:1]", "[]"},
+		{`"hello"[-3:]`, "llo"},
+		{`"hello"[::-1]`, "olleh"},
+		{`"天研究"[1:]`, "研究"},
+		{"[1, 2][::0]", "ERROR: slice step can't be zero"},
+		{`[1, 2]["a":]`, "ERROR: slice indexes must be integers, got STRING"},
+		{"5[1:]", "ERROR: can't slice INTEGER"},
+		{"(1..10)[-1]", "10"},
+		{"(1..10)[-11]", "null"},
+		{"(1..10)[2:4]", "[3, 4]"},
+		{"(1..10)[-3:]", "[8, 9, 10]"},
+		{"(1..10)[::-3]", "[10, 7, 4, 1]"},
+		{"(10..1)[1:3].to_array()", "[9, 8]"},
+		// slicing a range gives a range, which isn't built
+		{"util.len((0..1000000000000)[1:])", "1000000000000"},
+		{"(1..10)[5:2]", "[]"},
+	}
+	utils.SetReplOrRun(true)
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected,
+				evaluated.Inspect())
+		}
+	}
+}
+
+func TestOptionalChaining(t *testing.T) {
+	prelude := `let res = {"body": {"items": [1, 2, 3]}}; let n = null;
+let called = fn () { error("shouldn't be called") };
+`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"res?.body?.items", "[1, 2, 3]"},
+		{`res?.["body"]?.items?.[1]`, "2"},
+		{"res.missing?.items", "null"},
+		{"res?.missing?.items.more[0]", "null"},
+		{"n?.a.b(called())", "null"},
+		{"n?.[0]", "null"},
+		{"n?.[1:]", "null"},
+		{"n?.(called())", "null"},
+		{"(x => x * 2)?.(4)", "8"},
+		{"res.body.items?.[1:]", "[2, 3]"},
+		{"[n?.a, 1]", "[null, 1]"},
+		{"n ?? 5", "5"},
+		{"res.missing ?? res.nope ?? 7", "7"},
+		{`[0 ?? 1, "" ?? 1, false ?? 1, [] ?? 1]`, `[0, , false, []]`},
+		{"1 ?? called()", "1"},
+		{"res?.missing ?? 2", "2"},
+	}
+	utils.SetReplOrRun(true)
+	for _, tt := range tests {
+		evaluated := testEval(prelude + tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected,
+				evaluated.Inspect())
+		}
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	RegisterBuiltin("outer.inner.f", "f() is for testing.",
+		func(env *object.Environment, args ...object.Object) object.Object {
+			return NULL
+		})
+	defer delete(builtins, "outer.inner.f")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let h = {"a": {"b": {"c": 3}}}; h.a.b.c`, "3"},
+		{"let app.x = 1; let app.y = 2; [app.x, app.y]", "[1, 2]"},
+		{"let app.x = 1; app", "<module:app>"},
+		{"let app.x = 1; let m = app; m.keys()", "[x]"},
+		{"util.type(core.test)", "builtin"},
+		{`let m = core; m["test.skip"]`, "null"},
+		{
+			"let m = outer; [m.inner, m.inner.f]",
+			"[<module:outer.inner>, <builtin>]",
+		},
+		{"let m = core; m.test == core.test", "true"},
+		{"let app.x = 1; app.keys", "ERROR: identifier not found: app.keys"},
+		{
+			"let h = {}; let h.k = 1",
+			"ERROR: can't add k to h, which isn't a namespace",
+		},
+		{"util.type(util)", "module"},
+		{"util.type(util.len)", "builtin"},
+		{"util.type(core.test.skip)", "builtin"},
+		{"let util = 1; util", "1"},
+		{"util.nope", "ERROR: identifier not found: util.nope"},
+	}
+	utils.SetReplOrRun(true)
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected,
+				evaluated.Inspect())
+		}
+	}
+}
+
+func TestPipesAndShortFunctions(t *testing.T) {
+	prelude := "let add = |a, b| a + b; let double = x => x * 2;"
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"double(4)", 8},
+		{"(|| 3)()", 3},
+		{"5 |> double", 10},
+		{"5 |> add(1) |> double", 12},
+		{"[1, 2, 3] |> util.len", 3},
+		{"1 + 2 |> x => x * 10", 30},
+		{"let k = 2; 1 |> (x => x + k)", 3},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(prelude+tt.input), tt.expected)
+	}
+}
+
 func TestMutableStatements(t *testing.T) {
 	tests := []struct {
 		input  string
@@ -290,6 +513,208 @@ func TestMutableStatements(t *testing.T) {
 	}
 }
 
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect interface{}
+	}{
+		{"let [a, b] = [1, 2]; a + b", int64(3)},
+		{"let [a, ...rest] = [1, 2, 3]; rest", "[2, 3]"},
+		{"let [a, b, ...rest] = [1]; [b, rest]", "[null, []]"},
+		{"let [a, b = a + 1] = [1]; b", int64(2)},
+		{"let [a, [b, c]] = [1, [2, 3]]; c", int64(3)},
+		{"let [a, b] = 0..1; [a, b]", "[0, 1]"},
+		{"let [a, ...rest] = 3..1; rest", "[2, 1]"},
+		{"let [a, b, c] = 0..1; c", "null"},
+		{"let [a] = 5..1000000000000; a", int64(5)},
+		{`let {name, age: years} = {"name": "k", "age": 3}; years`, int64(3)},
+		{`let {"content-type": t} = {"content-type": "x"}; t`, "x"},
+		{`let {missing = 4} = {}; missing`, int64(4)},
+		{`let {a: [b, c]} = {"a": [1, 2]}; c`, int64(2)},
+		{"fn () { mutable [a, b] = [1, 2]; a = a + b; a }()", int64(3)},
+		{"let f = fn ([a, b], c) { a + b + c }; f([1, 2], 3)", int64(6)},
+		{"let f = fn ({x}, [y] = [2]) { x + y }; f({\"x\": 1})", int64(3)},
+		{
+			"mutable s = 0; foreach i, [a, b] in [[1, 2], [3, 4]] " +
+				"{ s = s + i + a * b }; s",
+			int64(15),
+		},
+		{`foreach {n} in [{"n": 5}] { return n }`, int64(5)},
+	}
+	utils.SetReplOrRun(true)
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expect := tt.expect.(type) {
+		case int64:
+			testIntegerObject(t, evaluated, expect)
+		case string:
+			if evaluated.Inspect() != expect {
+				t.Errorf("%q: expected %s, got %s", tt.input, expect,
+					evaluated.Inspect())
+			}
+		}
+	}
+
+	for _, input := range []string{"let [a] = 1", "let {a} = [1]"} {
+		if _, ok := testEval(input).(*object.Error); !ok {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
+
+func TestElementAssignment(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"mutable xs = [1, 2]; xs[0] = 3; xs", "[3, 2]"},
+		{"mutable xs = [1, 2]; xs[1] += 3; xs", "[1, 5]"},
+		{`mutable h = {"a": 1}; h["a"] = 2; h.b = 3; [h.a, h.b]`, "[2, 3]"},
+		{`mutable h = {"n": {"c": 1}}; h.n.c *= 5; h.n.c`, "5"},
+		{`mutable h = {"xs": [[1]]}; h.xs[0][0] = 2; h.xs`, "[[2]]"},
+		// the old value isn't changed
+		{"mutable xs = [1]; let ys = xs; xs[0] = 2; [xs, ys]", "[[2], [1]]"},
+		{
+			"mutable xs = [1, 2]; xs[0] = 3; let ys = xs; xs[1] = 4; [xs, ys]",
+			"[[3, 4], [3, 2]]",
+		},
+		{
+			`mutable h = {}; h.a = 1; let g = h; h.b = 2; [g.b, h.b]`,
+			"[null, 2]",
+		},
+		{
+			"mutable m = [[0]]; m[0][0] = 1; let r = m[0]; m[0][0] = 2; [r, m]",
+			"[[1], [[2]]]",
+		},
+		{
+			"mutable xs = [0, 0, 0]; xs[1] = xs[0] + 1; xs[2] = xs[1] + 1; xs",
+			"[0, 1, 2]",
+		},
+		{"mutable xs = [1]; xs[0] = 5", "5"},
+		{"mutable xs = [1]; xs[0] += 5", "6"},
+	}
+	utils.SetReplOrRun(true)
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expect {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expect,
+				evaluated.Inspect())
+		}
+	}
+
+	errors := []struct {
+		input string
+		err   string
+	}{
+		{
+			"let xs = [1]; xs[0] = 2",
+			"cannot assign to an element of xs; it was defined with let",
+		},
+		{
+			"mutable xs = [1]; xs[1] = 2",
+			"index 1 out of range for array of length 1",
+		},
+		{
+			`mutable xs = [1]; xs["a"] = 2`,
+			"array index must be an integer, got STRING",
+		},
+		{
+			`mutable s = "abc"; s[0] = "x"`,
+			"can't assign to an element of STRING",
+		},
+		{"mutable h = {}; h.a.b = 1", "can't assign to an element of NULL"},
+	}
+	for _, tt := range errors {
+		evaluated := testEval(tt.input)
+		err, ok := evaluated.(*object.Error)
+		if !ok || err.Message != tt.err {
+			t.Errorf("%q: expected error %q, got %s", tt.input, tt.err,
+				evaluated.Inspect())
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	describe := `let describe = fn (v) {
+    match v {
+        0 => "zero",
+        1..9 => "digit",
+        -9..-1 => "negative digit",
+        "hi" => "greeting",
+        integer if v > 100 => "big",
+        integer => "integer",
+        string => "string " + v,
+        [] => "empty",
+        [x] => "one " + util.string(x),
+        [1, ...rest] => { "1 then " + util.string(rest) },
+        {status: 200, body} => "ok: " + body,
+        {status, retry = false} if status >= 500 => util.string(retry),
+        null => "nothing",
+        _ => "other",
+    }
+};`
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"0", "zero"},
+		{"5", "digit"},
+		{"2.5", "digit"},
+		{"-3", "negative digit"},
+		{`"hi"`, "greeting"},
+		{"1000", "big"},
+		{"50", "integer"},
+		{`"x"`, "string x"},
+		{"[]", "empty"},
+		{"[7]", "one 7"},
+		{"[1, 2, 3]", "1 then [2, 3]"},
+		{"[2, 3]", "other"},
+		{"7..7", "one 7"},
+		{"1..3", "1 then [2, 3]"},
+		{"2..3", "other"},
+		{`{"status": 200, "body": "b"}`, "ok: b"},
+		{`{"status": 503}`, "false"},
+		{`{"status": 503, "retry": true}`, "true"},
+		{`{"status": 404}`, "other"},
+		{"null", "nothing"},
+		{"true", "other"},
+	}
+	utils.SetReplOrRun(true)
+	for _, tt := range tests {
+		evaluated := testEval(describe + "describe(" + tt.input + ")")
+		if evaluated.Inspect() != tt.expect {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expect,
+				evaluated.Inspect())
+		}
+	}
+
+	// bindings don't leak out of their arm
+	evaluated := testEval(`let x = 1; match [2] { [x] => x }; x`)
+	testIntegerObject(t, evaluated, 1)
+
+	evaluated = testEval(`match 3 { 1 => "one", 2 => "two" }`)
+	err, ok := evaluated.(*object.Error)
+	if !ok || err.Message != "no match for 3" {
+		t.Errorf("expected a no match error, got %s", evaluated.Inspect())
+	}
+
+	// and they can shadow lets, outside of a REPL too
+	exited := false
+	utils.SetReplOrRun(false)
+	utils.ExitHandler = func(int) { exited = true }
+	defer func() {
+		utils.ExitHandler = nil
+		utils.SetReplOrRun(true)
+	}()
+	evaluated = testEval(`let x = 1
+let f = fn () { let y = 2; match 3 { y => y + 1 } };
+[match 5 { x => x * 2 }, f(), x]`)
+	if evaluated.Inspect() != "[10, 4, 1]" || exited {
+		t.Errorf("expected [10, 4, 1], got %s (exited: %t)",
+			evaluated.Inspect(), exited)
+	}
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := `fn (x) { x+2; };`
 	evaluated := testEval(input)
@@ -443,6 +868,10 @@ func TestArrayIndexExpression(t *testing.T) {
 		},
 		{
 			"[1,2,3][-1]",
+			3,
+		},
+		{
+			"[1,2,3][-4]",
 			nil,
 		},
 	}
@@ -476,7 +905,11 @@ func TestStringIndexExpression(t *testing.T) {
 		},
 		{
 			"\"Autumn\"[-1]",
-			nil,
+			"n",
+		},
+		{
+			"\"天研\"[-1]",
+			"研",
 		},
 		{
 			"\"天研\"[0]",
@@ -607,6 +1040,82 @@ fn () {
 	testDecimalObject(t, evaluated, 4950)
 }
 
+func TestRanges(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1..3", "[1, 2, 3]"},
+		{"3..1", "[3, 2, 1]"},
+		{"(1..3).to_array()", "[1, 2, 3]"},
+		{"(3..1).to_array()", "[3, 2, 1]"},
+		{"(0..9).step(4).to_array()", "[0, 4, 8]"},
+		{"(9..0).step(4).to_array()", "[9, 5, 1]"},
+		{"(0..9).step(4).reverse()", "[8, 4, 0]"},
+		{"(0..9).step(0)", "ERROR: step should be a positive integer, got 0"},
+		{"(5..7)[1]", "6"},
+		{"(5..7)[3]", "null"},
+		{"util.len(0..9)", "10"},
+		{"util.len((0..9).step(2))", "5"},
+		// ranges work like the arrays they used to be
+		{"util.type(0..9)", "array"},
+		{"util.string(0..2)", "[0, 1, 2]"},
+		{`"{{0..2}}"`, "[0, 1, 2]"},
+		{"match 0..2 { array => 1, _ => 2 }", "1"},
+		{"util.deep_equals(0..2, [0, 1, 2])", "true"},
+		{"(0..1) + 1", "ERROR: type mismatch: ARRAY + INTEGER"},
+		{"let r = 0..1; r == r", "true"},
+		{"if (0..1) { 1 } else { 2 }", "1"},
+		{"(1..2).append(3)", "[1, 2, 3]"},
+		// a range isn't built up front, so returning early from a huge one
+		// is quick
+		{"fn () { foreach i in 0..1000000000000 { if i > 2 { return i } } }()",
+			"3"},
+		{"fn () { mutable t = 0; foreach i in 3..1 { t = t * 10 + i }; t }()",
+			"321"},
+	}
+	utils.SetReplOrRun(true)
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected,
+				evaluated.Inspect())
+		}
+	}
+}
+
+func TestHashIterators(t *testing.T) {
+	input := `let counter = fn (n) {
+    mutable i = 0
+    {"next": fn () {
+        i = i + 1
+        if i > n { {"done": true} } else { {"value": i} }
+    }}
+}
+let sum = fn (it) {
+    mutable total = 0
+    foreach x in it { total = total + x }
+    total
+}
+`
+	utils.SetReplOrRun(true)
+	testIntegerObject(t, testEval(input+"sum(iter.from(counter(4)))"), 10)
+
+	evaluated := testEval(
+		`foreach x in iter.from({"next": fn () { error("bad") }}) {}`)
+	if evaluated.Inspect() != "ERROR: bad" {
+		t.Errorf("expected the error from next, got %s", evaluated.Inspect())
+	}
+
+	// without iter.from, a hash with a next key is just a hash
+	evaluated = testEval(`mutable ks = []
+foreach k in {"next": 1} { ks = ks.append(k) }
+ks`)
+	if evaluated.Inspect() != "[next]" {
+		t.Errorf("expected the hash's keys, got %s", evaluated.Inspect())
+	}
+}
+
 func TestTypeBuiltin(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -659,3 +1168,193 @@ func TestStringInterpolation(t *testing.T) {
 		testStringObject(t, evaluated, tt.expected)
 	}
 }
+
+func TestStdlibEvaluatedOnce(t *testing.T) {
+	SetStdlib(SourceFile{Name: "x.keai", Source: `let x = 1`})
+	defer SetStdlib()
+
+	// generators import modules from their own goroutines
+	envs := make([]*ENV, 8)
+	var wg sync.WaitGroup
+	for i := range envs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			envs[i] = getStdlibEnv()
+		}(i)
+	}
+	wg.Wait()
+	for _, env := range envs {
+		if env != envs[0] {
+			t.Fatalf("expected one stdlib environment")
+		}
+	}
+}
+
+func TestModuleStdlibAndExports(t *testing.T) {
+	dir := t.TempDir()
+	if err := addPath(dir); err != nil {
+		t.Fatal(err)
+	}
+	SetStdlib(SourceFile{
+		Name:   "shout.keai",
+		Source: `let string.shout = fn () { self + "!" }`,
+	})
+	defer SetStdlib()
+
+	files := map[string]string{
+		"exports_all.keai": `let a = "a"; let b = "b".shout()`,
+		"exports_some.keai": `
+let helper = fn (x) { x.shout() }
+export let pub = helper("pub")
+`,
+	}
+	for name, src := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`import("exports_all").a`, "a"},
+		{`import("exports_all").b`, "b!"},
+		{`import("exports_some").pub`, "pub!"},
+		{`import("exports_some")["helper"]`, nil},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		str, ok := tt.expected.(string)
+		if ok {
+			testStringObject(t, evaluated, str)
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestCircularImports(t *testing.T) {
+	dir := t.TempDir()
+	if err := addPath(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"cycle_a.keai":    `let b = import("cycle_b")`,
+		"cycle_b.keai":    `let c = import("cycle_c")`,
+		"cycle_c.keai":    `let a = import("cycle_a")`,
+		"cycle_ok.keai":   `let c = import("cycle_leaf"); let d = import("cycle_leaf")`,
+		"cycle_leaf.keai": `let leaf = true`,
+	}
+	for name, src := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	evaluated := testEval(`import("cycle_a")`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	expected := "ImportError: circular import: " +
+		"cycle_a -> cycle_b -> cycle_c -> cycle_a"
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q",
+			expected, errObj.Message)
+	}
+	if len(importStack) != 0 {
+		t.Errorf("import stack not unwound, got %d frames", len(importStack))
+	}
+
+	// importing the same module twice isn't a cycle
+	testBooleanObject(t, testEval(`import("cycle_ok").d.leaf`), true)
+
+	mods := testEval(`sys.modules()`).(*object.Hash)
+	leaf := &object.String{Value: "cycle_leaf"}
+	info, ok := mods.Pairs[leaf.HashKey()]
+	if !ok {
+		t.Fatalf("sys.modules() is missing cycle_leaf")
+	}
+	path := &object.String{Value: "path"}
+	testStringObject(
+		t,
+		info.Value.(*object.Hash).Pairs[path.HashKey()].Value,
+		filepath.Join(dir, "cycle_leaf.keai"),
+	)
+}
+
+func TestFailedImports(t *testing.T) {
+	dir := t.TempDir()
+	if err := addPath(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"failing_mid.keai":  `let x = import("nope"); import("failing_leaf")`,
+		"failing_leaf.keai": `let leaf = true`,
+		"failing_call.keai": `fn () { import("nope") }(); let after = 1`,
+	}
+	for name, src := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the error comes back to the caller, which stops the program
+	utils.SetReplOrRun(true)
+	for _, input := range []string{
+		`import("nope"); 5`,
+		`let m = import("failing_mid"); 5`,
+		`import("failing_call"); 5`,
+	} {
+		evaluated := testEval(input)
+		if !ImportFailed(evaluated) {
+			t.Errorf("%s: expected a failed import, got %s", input,
+				evaluated.Inspect())
+		}
+	}
+
+	// and stops the module it's in
+	mods := testEval(`sys.modules()`).(*object.Hash)
+	leaf := &object.String{Value: "failing_leaf"}
+	if _, ok := mods.Pairs[leaf.HashKey()]; ok {
+		t.Errorf("failing_mid kept going after a failed import")
+	}
+	if len(importStack) != 0 {
+		t.Errorf("import stack not unwound, got %d frames", len(importStack))
+	}
+}
+
+func TestScriptArgs(t *testing.T) {
+	SetArgs([]string{"-e", "--name=keai", "-x", "--out", "a.txt", "rest"})
+	defer SetArgs(nil)
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`sys.args()[0]`, "-e"},
+		{`sys.args()[5]`, "rest"},
+		{`sys.flag("name")`, "keai"},
+		{`sys.flag("out")`, "a.txt"},
+		{`sys.flag("x")`, true},
+		{`sys.flag("e")`, false},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case string:
+			testStringObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+}
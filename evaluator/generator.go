@@ -0,0 +1,149 @@
+package evaluator
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/zautumnz/keai/ast"
+	"github.com/zautumnz/keai/object"
+	"github.com/zautumnz/keai/utils"
+)
+
+// A generator runs its function's body in a goroutine, which takes turns
+// with whatever's iterating over it: resuming sends on resumes and waits
+// for the next value on yields, and yielding sends on yields and waits to
+// be resumed. Only one of them runs at a time.
+//
+// A generator that's dropped before it's done keeps its goroutine waiting
+// to be resumed, so anything that stops early should call its stop. That's
+// done for foreach and iter.take, but not for calls to next().
+type generator struct {
+	fn      *object.Function
+	env     *ENV
+	yields  chan yielded
+	resumes chan bool
+	started bool
+	done    bool
+}
+
+// yielded is what a generator gives when it's resumed: a value, or what
+// its goroutine panicked with, like a fatal error's exit in keai test,
+// which is raised again on the goroutine that resumed it.
+type yielded struct {
+	val   OBJ
+	panic interface{}
+}
+
+// stopGenerator is what a yield panics with when its generator is stopped,
+// to unwind the function's goroutine.
+type stopGenerator struct{}
+
+// generators holds the running generators, by the environment of their
+// function's body, so yield can find the one it's in.
+var (
+	generators   = map[*ENV]*generator{}
+	generatorsMu sync.Mutex
+)
+
+// newGenerator returns a generator for a call to fn, which has been given
+// its arguments in env. Nothing runs until the first value is asked for.
+func newGenerator(fn *object.Function, env *ENV) *object.Generator {
+	g := &generator{
+		fn:      fn,
+		env:     env,
+		yields:  make(chan yielded),
+		resumes: make(chan bool),
+	}
+	return object.NewGenerator(g.resume, g.stop)
+}
+
+// run runs the function, in the generator's goroutine.
+func (g *generator) run() {
+	generatorsMu.Lock()
+	generators[g.env] = g
+	generatorsMu.Unlock()
+
+	defer func() {
+		generatorsMu.Lock()
+		delete(generators, g.env)
+		generatorsMu.Unlock()
+		if r := recover(); r != nil {
+			if _, ok := r.(stopGenerator); !ok {
+				g.yields <- yielded{panic: r}
+			}
+		}
+		close(g.yields)
+	}()
+
+	// an error ends the generator, and is the last thing it gives
+	if res := upwrapReturnValue(Eval(g.fn.Body, g.env)); isError(res) {
+		g.yields <- yielded{val: res}
+	}
+}
+
+// resume runs the generator up to its next yield.
+func (g *generator) resume() (OBJ, bool) {
+	if g.done {
+		return nil, false
+	}
+	if g.started {
+		g.resumes <- true
+	} else {
+		g.started = true
+		go g.run()
+	}
+	y, ok := <-g.yields
+	if !ok {
+		g.done = true
+	}
+	if y.panic != nil {
+		g.done = true
+		for range g.yields {
+		}
+		panic(y.panic)
+	}
+	return y.val, ok
+}
+
+// stop makes a paused generator's yield unwind its goroutine, and waits
+// for it to finish.
+func (g *generator) stop() {
+	if g.done {
+		return
+	}
+	g.done = true
+	if !g.started {
+		return
+	}
+	g.resumes <- false
+	for range g.yields {
+	}
+}
+
+// evalYieldExpression gives a value to whatever resumed the generator
+// it's in, and waits to be resumed.
+func evalYieldExpression(ye *ast.YieldExpression, env *ENV) OBJ {
+	val := Eval(ye.Value, env)
+	if returnsEarly(val) {
+		return val
+	}
+
+	var g *generator
+	generatorsMu.Lock()
+	for e := env; e != nil && g == nil; e = e.Outer() {
+		g = generators[e]
+	}
+	generatorsMu.Unlock()
+	if g == nil {
+		err := NewError("yield outside of a function")
+		fmt.Printf("Error: %s\n", err.Inspect())
+		utils.ExitConditionally(1)
+		return err
+	}
+
+	g.yields <- yielded{val: val}
+	if !<-g.resumes {
+		panic(stopGenerator{})
+	}
+	return NULL
+}
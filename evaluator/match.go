@@ -0,0 +1,183 @@
+package evaluator
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/zautumnz/keai/ast"
+	"github.com/zautumnz/keai/object"
+	"github.com/zautumnz/keai/utils"
+)
+
+// evalMatchExpression evaluates the first arm whose pattern matches the
+// value and whose guard, if it has one, is true. Names bound by the pattern
+// are only visible in that arm.
+func evalMatchExpression(me *ast.MatchExpression, env *ENV) OBJ {
+	val := Eval(me.Value, env)
+	if returnsEarly(val) {
+		return val
+	}
+
+	for _, arm := range me.Arms {
+		bindings := map[string]OBJ{}
+		matched, err := match(arm.Pattern, val, env, bindings)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+
+		scope := env
+		if len(bindings) > 0 {
+			names := []string{}
+			for name := range bindings {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			// SetLet, since Set won't shadow a let outside of the match
+			scope = object.NewTemporaryScope(env, names)
+			for _, name := range names {
+				scope.SetLet(name, bindings[name])
+			}
+		}
+
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, scope)
+			if isError(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+		return Eval(arm.Body, scope)
+	}
+
+	err := NewError("no match for %s", val.Inspect())
+	fmt.Printf("Error: %s\n", err.Inspect())
+	utils.ExitConditionally(1)
+	return err
+}
+
+// match returns true if a value matches a pattern, adding the names the
+// pattern binds to bindings.
+func match(
+	pattern ast.Expression,
+	val OBJ,
+	env *ENV,
+	bindings map[string]OBJ,
+) (bool, OBJ) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		switch {
+		case pattern.Value == "_":
+		case ast.TypePatterns[pattern.Value]:
+			return typeName(val) == pattern.Value, nil
+		default:
+			bindings[pattern.Value] = val
+		}
+		return true, nil
+
+	case *ast.ArrayPattern:
+		elements, n, ok := patternElements(pattern, val)
+		if !ok {
+			return false, nil
+		}
+		required := 0
+		for _, e := range pattern.Elements {
+			if e.Default == nil {
+				required++
+			}
+		}
+		if n < required || (pattern.Rest == nil && n > len(pattern.Elements)) {
+			return false, nil
+		}
+		for i, e := range pattern.Elements {
+			var elem OBJ
+			if i < len(elements) {
+				elem = elements[i]
+			}
+			if ok, err := matchElement(e, elem, env, bindings); !ok {
+				return false, err
+			}
+		}
+		if pattern.Rest != nil {
+			rest := []OBJ{}
+			if len(pattern.Elements) < len(elements) {
+				rest = append(rest, elements[len(pattern.Elements):]...)
+			}
+			bindings[pattern.Rest.Value] = &object.Array{Elements: rest}
+		}
+		return true, nil
+
+	case *ast.HashPattern:
+		hash, ok := val.(*object.Hash)
+		if !ok {
+			return false, nil
+		}
+		for _, e := range pattern.Pairs {
+			var value OBJ
+			key := &object.String{Value: e.Key}
+			if pair, ok := hash.Pairs[key.HashKey()]; ok {
+				value = pair.Value
+			}
+			if ok, err := matchElement(e, value, env, bindings); !ok {
+				return false, err
+			}
+		}
+		return true, nil
+
+	case *ast.InfixExpression:
+		// a range, like 1..9
+		lo, hi := Eval(pattern.Left, env), Eval(pattern.Right, env)
+		return inRange(val, lo, hi), nil
+	}
+
+	literal := Eval(pattern, env)
+	if isError(literal) {
+		return false, literal
+	}
+	return object.Equal(val, literal), nil
+}
+
+// matchElement matches part of an array or hash pattern, using its default
+// if val is missing (nil). Parts without defaults have to be there.
+func matchElement(
+	e *ast.PatternElement,
+	val OBJ,
+	env *ENV,
+	bindings map[string]OBJ,
+) (bool, OBJ) {
+	if val == nil {
+		if e.Default == nil {
+			return false, nil
+		}
+		val = Eval(e.Default, env)
+		if isError(val) {
+			return false, val
+		}
+	}
+	return match(e.Target, val, env, bindings)
+}
+
+// inRange returns true if val is a number between lo and hi, inclusive.
+func inRange(val, lo, hi OBJ) bool {
+	v, ok := number(val)
+	if !ok {
+		return false
+	}
+	l, lok := number(lo)
+	h, hok := number(hi)
+	return lok && hok && l <= v && v <= h
+}
+
+func number(obj OBJ) (float64, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value), true
+	case *object.Float:
+		return obj.Value, true
+	}
+	return 0, false
+}
@@ -0,0 +1,257 @@
+package evaluator
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zautumnz/keai/ast"
+	"github.com/zautumnz/keai/lexer"
+	"github.com/zautumnz/keai/object"
+	"github.com/zautumnz/keai/parser"
+)
+
+// TraceImports makes every import print the path it resolved to (on
+// STDERR), indented by how deeply nested the import is.
+var TraceImports = false
+
+// SourceFile is a named piece of keai source.
+type SourceFile struct {
+	Name   string
+	Source string
+}
+
+// stdlibFiles holds the keai-implemented standard library, as registered
+// by SetStdlib.
+var stdlibFiles []SourceFile
+
+// stdlibEnv is the environment the standard library is evaluated into the
+// first time a module is imported. It's shared by every module, and
+// stdlibOnce makes sure it's only evaluated once, even when generators
+// running on other goroutines import modules at the same time.
+var (
+	stdlibEnv  *ENV
+	stdlibOnce sync.Once
+)
+
+// SetStdlib registers the files of the keai-implemented standard library,
+// so imported modules can use it the same way the main program does.
+func SetStdlib(files ...SourceFile) {
+	stdlibFiles = files
+	stdlibEnv = nil
+	stdlibOnce = sync.Once{}
+}
+
+// StdlibFiles returns the files registered with SetStdlib.
+func StdlibFiles() []SourceFile {
+	return stdlibFiles
+}
+
+// getStdlibEnv returns the shared standard library environment, evaluating
+// the standard library if this is the first time it's needed.
+func getStdlibEnv() *ENV {
+	stdlibOnce.Do(func() {
+		stdlibEnv = NewStdlibEnvironment()
+	})
+	return stdlibEnv
+}
+
+// NewStdlibEnvironment returns a new top-level environment with the
+// standard library evaluated into it, like the one the main program runs in.
+func NewStdlibEnvironment() *ENV {
+	env := object.NewEnvironment()
+	for _, f := range stdlibFiles {
+		p := parser.New(lexer.New(f.Source))
+		program := p.ParseProgram()
+		Register(f.Name, f.Source, program)
+		Eval(program, env)
+	}
+	return env
+}
+
+// loadedModule is an entry in the module table.
+type loadedModule struct {
+	// Name is the name the module was first imported by
+	Name string
+
+	// Path is the file the module was loaded from
+	Path string
+
+	// LoadedAt is when the module finished loading
+	LoadedAt time.Time
+
+	// Duration is how long evaluating the module took, including its
+	// own imports
+	Duration time.Duration
+
+	// Module is the module itself
+	Module *object.Module
+}
+
+// importFrame is a module which is currently being evaluated.
+type importFrame struct {
+	name string
+	path string
+
+	// err holds the first import error raised while evaluating the module
+	err OBJ
+}
+
+var (
+	// importCache holds every loaded module, keyed by path. Modules are
+	// singletons; we don't allow modifying anything they export, so we
+	// can skip re-evaluating them on subsequent imports.
+	importCache map[string]*loadedModule
+
+	// importOrder holds the paths in importCache, in the order they
+	// finished loading.
+	importOrder []string
+
+	// importStack holds the modules currently being evaluated, outermost
+	// first. It's used to detect circular imports.
+	importStack []*importFrame
+)
+
+func init() {
+	importCache = make(map[string]*loadedModule)
+}
+
+// ResetModules forgets every loaded module, so the next import of each one
+// evaluates it again. keai test uses it to keep test files isolated.
+func ResetModules() {
+	importCache = make(map[string]*loadedModule)
+	importOrder = nil
+	importStack = nil
+}
+
+// traceImport prints a line for --trace-imports
+func traceImport(format string, a ...interface{}) {
+	if TraceImports {
+		indent := strings.Repeat("  ", len(importStack))
+		fmt.Fprintf(os.Stderr, "import: "+indent+format+"\n", a...)
+	}
+}
+
+// importChain formats the names on the import stack, followed by name.
+func importChain(name string) string {
+	names := []string{}
+	for _, f := range importStack {
+		names = append(names, f.name)
+	}
+	names = append(names, name)
+	return strings.Join(names, " -> ")
+}
+
+// EvalModule evaluates the named module and returns a *object.Module object
+// This creates a whole new keai instance (lexer, parser, env, and evaluator),
+// which isn't ideal, but we also do this when working with string
+// interpolation. The module's environment encloses the standard library, so
+// modules can use everything a regular program can without exporting it.
+func EvalModule(name string) OBJ {
+	filename := FindModule(name)
+	if filename == "" {
+		return NewError("ImportError: no module named '%s'", name)
+	}
+
+	if m, ok := importCache[filename]; ok {
+		traceImport("%s => %s (cached)", name, filename)
+		return m.Module
+	}
+
+	for _, f := range importStack {
+		if f.path == filename {
+			return NewError(
+				"ImportError: circular import: %s",
+				importChain(name),
+			)
+		}
+	}
+
+	traceImport("%s => %s", name, filename)
+
+	b, err := readModuleFile(filename)
+	if err != nil {
+		return NewError("IOError: error reading module '%s': %s", name, err)
+	}
+
+	l := lexer.New(string(b))
+	p := parser.New(l)
+
+	module := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return NewError("ParseError: %s", p.Errors())
+	}
+	Register(filename, string(b), module)
+
+	env := object.NewModuleEnvironment(getStdlibEnv())
+	start := time.Now()
+	frame := &importFrame{name: name, path: filename}
+	importStack = append(importStack, frame)
+	Eval(module, env)
+	importStack = importStack[:len(importStack)-1]
+
+	// A failed import inside the module fails the module too, so the
+	// error makes its way back to whoever started the chain.
+	if frame.err != nil {
+		return frame.err
+	}
+
+	m := &object.Module{Name: name, Attrs: env.ExportedHash()}
+	importCache[filename] = &loadedModule{
+		Name:     name,
+		Path:     filename,
+		LoadedAt: time.Now(),
+		Duration: time.Since(start),
+		Module:   m,
+	}
+	importOrder = append(importOrder, filename)
+
+	return m
+}
+
+func evalImportExpression(ie *ast.ImportExpression, env *ENV) OBJ {
+	name := Eval(ie.Name, env)
+	if isError(name) {
+		return name
+	}
+
+	var res OBJ
+	if s, ok := name.(*object.String); ok {
+		res = EvalModule(s.Value)
+	} else {
+		res = NewError("ImportError: invalid import path '%s'", name.Inspect())
+	}
+
+	if err, ok := res.(*object.Error); ok {
+		err.Import = true
+		if len(importStack) > 0 {
+			frame := importStack[len(importStack)-1]
+			if frame.err == nil {
+				frame.err = err
+			}
+		}
+	}
+
+	return res
+}
+
+// failedImport returns the error from an import that failed in the module
+// being evaluated, if there's one, even if the module didn't return it.
+func failedImport() OBJ {
+	if len(importStack) > 0 {
+		if err := importStack[len(importStack)-1].err; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportFailed returns true if res, returned by Eval for a program, is an
+// error from an import that stopped the program. It's up to whoever's
+// running the program to report it.
+func ImportFailed(res OBJ) bool {
+	err, ok := res.(*object.Error)
+	return ok && err.Import
+}
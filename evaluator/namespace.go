@@ -0,0 +1,118 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zautumnz/keai/ast"
+	"github.com/zautumnz/keai/object"
+	"github.com/zautumnz/keai/utils"
+)
+
+// Namespaces like fs and sys aren't bound to anything themselves. They're
+// made of the builtins named like fs.open and the lets like
+// `let fs.ls = ...`, which are bound by their whole name, so type methods
+// like string.substr keep working when a program has its own `string`
+// variable. A namespace is only put together into a module when it's used
+// as a value, like `print(fs)`.
+
+// namespace returns the members of a namespace like fs or sys: the builtins
+// named fs.x and the stdlib (or user) functions bound with `let fs.x = ...`.
+func namespace(name string, env *ENV) map[string]OBJ {
+	members := map[string]OBJ{}
+	prefix := name + "."
+	for key, fn := range builtins {
+		if strings.HasPrefix(key, prefix) {
+			members[strings.TrimPrefix(key, prefix)] = fn
+		}
+	}
+	for key, val := range env.Members(name) {
+		members[key] = val
+	}
+	return members
+}
+
+// IsNamespace returns true if name isn't bound, but things are bound in it,
+// like fs is when fs.open is.
+func IsNamespace(name string, env *ENV) bool {
+	if _, ok := env.Get(name); ok {
+		return false
+	}
+	if _, ok := builtins[name]; ok {
+		return false
+	}
+	return len(namespace(name, env)) > 0
+}
+
+// evalNamespace gives a namespace as a module, so it can be printed and
+// listed on its own. Namespaces in it, like core.test's, are modules too,
+// unless they're also something else; core.test is a function, so
+// core.test.skip is only there written out in full.
+func evalNamespace(name string, env *ENV) OBJ {
+	members := namespace(name, env)
+	attrs := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+	for key, val := range members {
+		if i := strings.Index(key, "."); i > 0 {
+			key = key[:i]
+			if _, ok := members[key]; ok {
+				continue
+			}
+			val = evalNamespace(name+"."+key, env)
+		}
+		s := &object.String{Value: key}
+		attrs.Pairs[s.HashKey()] = object.HashPair{Key: s, Value: val}
+	}
+	return &object.Module{Name: name, Attrs: attrs}
+}
+
+// checkNamespaceLet returns an error for `let x.y = ...` when x is bound,
+// since x.y would be the y of what x is bound to, and the let would never
+// be seen. Only names that aren't bound are namespaces.
+func checkNamespaceLet(name string, env *ENV) OBJ {
+	i := strings.Index(name, ".")
+	if i < 0 {
+		return nil
+	}
+	root := name[:i]
+	_, bound := env.Get(root)
+	if _, ok := builtins[root]; !bound && !ok {
+		return nil
+	}
+	err := NewError(
+		"can't add %s to %s, which isn't a namespace",
+		name[i+1:],
+		root,
+	)
+	fmt.Printf("Error: %s\n", err.Inspect())
+	utils.ExitConditionally(1)
+	return err
+}
+
+// evalNamespaceMember looks up a member like fs.open, sys.STDOUT, or
+// core.test.skip directly, without putting the whole namespace together.
+// It only applies when the chain starts with a name that isn't bound.
+func evalNamespaceMember(node *ast.IndexExpression, env *ENV) (OBJ, bool) {
+	id, ok := ast.Root(node).(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+	if _, ok := env.Get(id.Value); ok {
+		return nil, false
+	}
+	name, ok := ast.DottedName(node)
+	if !ok {
+		return nil, false
+	}
+	if val, ok := env.Get(name); ok {
+		return val, true
+	}
+	if builtin, ok := builtins[name]; ok {
+		return builtin, true
+	}
+	if !IsNamespace(name[:strings.LastIndex(name, ".")], env) {
+		return nil, false
+	}
+	fmt.Println("identifier not found: " + name)
+	utils.ExitConditionally(1)
+	return NewError("identifier not found: " + name), true
+}
@@ -0,0 +1,181 @@
+package evaluator
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+)
+
+// protobuf encodes the parts of protocol buffers the pprof format needs,
+// which is all we use them for.
+type protobuf struct {
+	buf []byte
+}
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		b.buf = append(b.buf, byte(x)|0x80)
+		x >>= 7
+	}
+	b.buf = append(b.buf, byte(x))
+}
+
+// key writes a field's number and wire type.
+func (b *protobuf) key(field int, wire uint64) {
+	b.varint(uint64(field)<<3 | wire)
+}
+
+func (b *protobuf) int(field int, x int64) {
+	if x != 0 {
+		b.key(field, 0)
+		b.varint(uint64(x))
+	}
+}
+
+func (b *protobuf) bytes(field int, x []byte) {
+	b.key(field, 2)
+	b.varint(uint64(len(x)))
+	b.buf = append(b.buf, x...)
+}
+
+func (b *protobuf) ints(field int, xs []int64) {
+	var packed protobuf
+	for _, x := range xs {
+		packed.varint(uint64(x))
+	}
+	b.bytes(field, packed.buf)
+}
+
+func (b *protobuf) message(field int, f func(m *protobuf)) {
+	var m protobuf
+	f(&m)
+	b.bytes(field, m.buf)
+}
+
+// the fields of profile.proto we use
+const (
+	profileSampleType        = 1
+	profileSample            = 2
+	profileLocation          = 4
+	profileFunction          = 5
+	profileStringTable       = 6
+	profileTimeNanos         = 9
+	profileDurationNanos     = 10
+	profilePeriodType        = 11
+	profilePeriod            = 12
+	profileDefaultSampleType = 14
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID        = 1
+	functionName      = 2
+	functionFilename  = 4
+	functionStartLine = 5
+)
+
+// WriteProfile writes the samples in pprof's format, for go tool pprof and
+// the tools that read it, which show where the time and allocations went by
+// keai function and line. Stop the profiler first.
+func (p *Profiler) WriteProfile(w io.Writer) error {
+	var b protobuf
+	index := map[string]int64{"": 0}
+	table := []string{""}
+	str := func(s string) int64 {
+		if i, ok := index[s]; ok {
+			return i
+		}
+		index[s] = int64(len(table))
+		table = append(table, s)
+		return index[s]
+	}
+	valueType := func(field int, typ, unit string) {
+		b.message(field, func(m *protobuf) {
+			m.int(valueTypeType, str(typ))
+			m.int(valueTypeUnit, str(unit))
+		})
+	}
+
+	valueType(profileSampleType, "samples", "count")
+	valueType(profileSampleType, "time", "nanoseconds")
+	valueType(profileSampleType, "alloc_space", "bytes")
+
+	// sort the samples, so the same profile is written the same way
+	keys := []string{}
+	for k := range p.samples {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	funcs := map[profileFunc]int64{}
+	locs := map[profileLoc]int64{}
+	funcOrder := []profileFunc{}
+	locOrder := []profileLoc{}
+	for _, k := range keys {
+		s := p.samples[k]
+		ids := []int64{}
+		for _, loc := range s.stack {
+			if _, ok := funcs[loc.fn]; !ok {
+				funcs[loc.fn] = int64(len(funcs) + 1)
+				funcOrder = append(funcOrder, loc.fn)
+			}
+			if _, ok := locs[loc]; !ok {
+				locs[loc] = int64(len(locs) + 1)
+				locOrder = append(locOrder, loc)
+			}
+			ids = append(ids, locs[loc])
+		}
+		b.message(profileSample, func(m *protobuf) {
+			m.ints(sampleLocationID, ids)
+			m.ints(sampleValue, []int64{
+				s.count,
+				int64(s.time),
+				s.alloc,
+			})
+		})
+	}
+
+	for _, loc := range locOrder {
+		b.message(profileLocation, func(m *protobuf) {
+			m.int(locationID, locs[loc])
+			m.message(locationLine, func(l *protobuf) {
+				l.int(lineFunctionID, funcs[loc.fn])
+				l.int(lineLine, int64(loc.line))
+			})
+		})
+	}
+	for _, fn := range funcOrder {
+		b.message(profileFunction, func(m *protobuf) {
+			m.int(functionID, funcs[fn])
+			m.int(functionName, str(fn.name))
+			m.int(functionFilename, str(fn.file))
+			m.int(functionStartLine, int64(fn.line))
+		})
+	}
+
+	b.int(profileTimeNanos, p.start.UnixNano())
+	b.int(profileDurationNanos, int64(p.end.Sub(p.start)))
+	valueType(profilePeriodType, "time", "nanoseconds")
+	b.int(profilePeriod, int64(p.period))
+	b.int(profileDefaultSampleType, str("time"))
+
+	// the string table has to come last, once everything's been added
+	for _, s := range table {
+		b.bytes(profileStringTable, []byte(s))
+	}
+
+	z := gzip.NewWriter(w)
+	if _, err := z.Write(b.buf); err != nil {
+		return err
+	}
+	return z.Close()
+}
@@ -0,0 +1,203 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"runtime/metrics"
+	"sort"
+	"strings"
+	"time"
+)
+
+// profileFunc is a keai function, as the profiler sees it. Functions with
+// the same name are told apart by where they're defined.
+type profileFunc struct {
+	name string
+	file string
+	line int
+}
+
+// profileLoc is a line of a function.
+type profileLoc struct {
+	fn   profileFunc
+	line int
+}
+
+// stackSample is a call stack the profiler saw, innermost first, how often,
+// and the time and allocations since the samples before.
+type stackSample struct {
+	stack []profileLoc
+	count int64
+	time  time.Duration
+	alloc int64
+}
+
+// timing is how often a function was called and how long it took.
+type timing struct {
+	calls int
+	total time.Duration
+	self  time.Duration
+
+	// active counts the calls in progress, so recursive calls aren't
+	// counted twice in the total
+	active int
+}
+
+// Profiler samples which keai functions and lines a program is running,
+// and times every call. Set one with SetProfiler before evaluating
+// anything.
+//
+// Samples are taken by the program itself, at the first statement it runs
+// or builtin that returns after one is due.
+type Profiler struct {
+	period time.Duration
+	start  time.Time
+	end    time.Time
+
+	samples map[string]*stackSample
+	timings map[profileFunc]*timing
+
+	// allocs reads how many bytes have been allocated. Each sample gets
+	// what was allocated and how long it's been since the last one, which
+	// is more than the period if a statement or builtin took a while.
+	allocs     []metrics.Sample
+	lastAlloc  uint64
+	lastSample time.Time
+}
+
+// profiler is the profiler in use, if any.
+var profiler *Profiler
+
+// NewProfiler returns a profiler that samples every period.
+func NewProfiler(period time.Duration) *Profiler {
+	return &Profiler{
+		period:  period,
+		samples: map[string]*stackSample{},
+		timings: map[profileFunc]*timing{},
+		allocs: []metrics.Sample{
+			{Name: "/gc/heap/allocs:bytes"},
+		},
+	}
+}
+
+// SetProfiler starts a profiler, or stops the one in use if p is nil.
+// Files have to be registered after it's set for it to see them.
+func SetProfiler(p *Profiler) {
+	if profiler != nil {
+		profiler.end = time.Now()
+	}
+	profiler = p
+	setTracing()
+	if p != nil {
+		p.start = time.Now()
+		p.lastSample = p.start
+		p.lastAlloc = p.readAllocs()
+	}
+}
+
+func (p *Profiler) readAllocs() uint64 {
+	metrics.Read(p.allocs)
+	if p.allocs[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return p.allocs[0].Value.Uint64()
+}
+
+// maybeSample records the call stack, if a sample is due.
+func (p *Profiler) maybeSample() {
+	now := time.Now()
+	if now.Sub(p.lastSample) < p.period {
+		return
+	}
+	alloc := p.readAllocs()
+
+	stack := make([]profileLoc, 0, len(callStack))
+	key := strings.Builder{}
+	for i := len(callStack) - 1; i >= 0; i-- {
+		f := callStack[i]
+		loc := profileLoc{fn: frameFunc(f), line: f.Line}
+		stack = append(stack, loc)
+		fmt.Fprintf(&key, "%s:%s:%d:%d;", loc.fn.name, loc.fn.file,
+			loc.fn.line, loc.line)
+	}
+
+	s, ok := p.samples[key.String()]
+	if !ok {
+		s = &stackSample{stack: stack}
+		p.samples[key.String()] = s
+	}
+	s.count++
+	s.time += now.Sub(p.lastSample)
+	p.lastSample = now
+	if alloc > p.lastAlloc {
+		s.alloc += int64(alloc - p.lastAlloc)
+	}
+	p.lastAlloc = alloc
+}
+
+// frameFunc returns the function a frame is running. The outermost frame
+// is the program itself.
+func frameFunc(f *Frame) profileFunc {
+	if f.def.file == "" {
+		return profileFunc{name: f.Name, file: f.File}
+	}
+	return profileFunc{name: f.Name, file: f.def.file, line: f.def.line}
+}
+
+// enter counts a call starting.
+func (p *Profiler) enter(f *Frame) {
+	t, ok := p.timings[frameFunc(f)]
+	if !ok {
+		t = &timing{}
+		p.timings[frameFunc(f)] = t
+	}
+	t.calls++
+	t.active++
+}
+
+// leave counts a call returning to its caller.
+func (p *Profiler) leave(f, caller *Frame) {
+	elapsed := time.Since(f.start)
+	t := p.timings[frameFunc(f)]
+	t.active--
+	if t.active == 0 {
+		t.total += elapsed
+	}
+	t.self += elapsed - f.callees
+	caller.callees += elapsed
+}
+
+// WriteTimings writes a table of the functions that were called, with how
+// many times and how long they took, slowest first. The total includes the
+// functions they called, and self doesn't.
+func (p *Profiler) WriteTimings(w io.Writer) error {
+	funcs := []profileFunc{}
+	for fn := range p.timings {
+		funcs = append(funcs, fn)
+	}
+	sort.Slice(funcs, func(i, j int) bool {
+		a, b := p.timings[funcs[i]], p.timings[funcs[j]]
+		if a.total != b.total {
+			return a.total > b.total
+		}
+		return funcs[i].name < funcs[j].name
+	})
+
+	_, err := fmt.Fprintf(w, "%10s %12s %12s  %s\n",
+		"calls", "total", "self", "function")
+	for _, fn := range funcs {
+		if err != nil {
+			return err
+		}
+		t := p.timings[fn]
+		_, err = fmt.Fprintf(w, "%10d %12s %12s  %s (%s:%d)\n",
+			t.calls,
+			t.total.Round(time.Microsecond),
+			t.self.Round(time.Microsecond),
+			fn.name,
+			fn.file,
+			fn.line,
+		)
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+package evaluator
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zautumnz/keai/lexer"
+	"github.com/zautumnz/keai/object"
+	"github.com/zautumnz/keai/parser"
+)
+
+func TestProfiler(t *testing.T) {
+	input := `let fib = fn (n) {
+    if n < 2 {
+        return n
+    }
+    return fib(n - 1) + fib(n - 2)
+}
+let slow = fn () {
+    fib(18)
+}
+slow()
+`
+	p := NewProfiler(time.Millisecond)
+	SetProfiler(p)
+	program := parser.New(lexer.New(input)).ParseProgram()
+	Register("f.keai", input, program)
+	Eval(program, object.NewEnvironment())
+	SetProfiler(nil)
+
+	var out strings.Builder
+	if err := p.WriteTimings(&out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(out.String(), "\n")
+	if !strings.Contains(lines[1], " slow (f.keai:7)") ||
+		!strings.HasPrefix(strings.TrimSpace(lines[1]), "1 ") {
+		t.Errorf("expected slow to be slowest, called once:\n%s", out.String())
+	}
+	if !strings.Contains(lines[2], " fib (f.keai:1)") ||
+		!strings.HasPrefix(strings.TrimSpace(lines[2]), "8361 ") {
+		t.Errorf("expected fib to be called 8361 times:\n%s", out.String())
+	}
+
+	var buf bytes.Buffer
+	if err := p.WriteProfile(&buf); err != nil {
+		t.Fatal(err)
+	}
+	z, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"alloc_space", "nanoseconds", "f.keai", "main"} {
+		if !bytes.Contains(b, []byte(s)) {
+			t.Errorf("expected the profile to contain %q", s)
+		}
+	}
+}
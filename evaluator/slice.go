@@ -0,0 +1,178 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/zautumnz/keai/ast"
+	"github.com/zautumnz/keai/object"
+	"github.com/zautumnz/keai/utils"
+)
+
+// elementIndex turns an index into an array, string, or range of length n into
+// a position in it, counting from the end if it's negative, so -1 is the
+// last element. It returns false if the index is out of range.
+func elementIndex(i int64, n int) (int64, bool) {
+	if i < 0 {
+		i += int64(n)
+	}
+	return i, i >= 0 && i < int64(n)
+}
+
+// evalSliceExpression handles xs[start:end:step], for arrays, strings, and
+// ranges.
+func evalSliceExpression(se *ast.SliceExpression, env *ENV) OBJ {
+	left := link(se.Left, env)
+	if skips(se, left) {
+		return skipped
+	}
+	if returnsEarly(left) {
+		return left
+	}
+	parts := make([]OBJ, 3)
+	for i, e := range []ast.Expression{se.Start, se.End, se.Step} {
+		if e == nil {
+			continue
+		}
+		parts[i] = Eval(e, env)
+		if returnsEarly(parts[i]) {
+			return parts[i]
+		}
+	}
+
+	res := slice(left, parts[0], parts[1], parts[2])
+	if isError(res) {
+		fmt.Printf("Error: %s\n", res.Inspect())
+		utils.ExitConditionally(1)
+	}
+	return res
+}
+
+// slice returns the elements of an array or range, or characters of a
+// string, from start up to but not including end, step apart. Any of them
+// can be nil or null to leave them out, and negative indexes count from
+// the end. Slicing a range gives another range, unless nothing's in the
+// slice; a..b always has something in it, so that's an empty array.
+func slice(val, start, end, step OBJ) OBJ {
+	switch val := val.(type) {
+	case *object.Range:
+		from, to, by, err := sliceBounds(int(val.Len()), start, end, step)
+		if err != nil {
+			return err
+		}
+		count := 0
+		if by > 0 && to > from {
+			count = (to - from + by - 1) / by
+		} else if by < 0 && from > to {
+			count = (from - to - by - 1) / -by
+		}
+		if count == 0 {
+			return &object.Array{Elements: []OBJ{}}
+		}
+		first := val.Start + int64(from)*val.Step
+		sliced := &object.Range{Start: first, Step: val.Step * int64(by)}
+		sliced.End = first + int64(count-1)*sliced.Step
+		return sliced
+	case *object.Array:
+		indexes, err := sliceIndexes(len(val.Elements), start, end, step)
+		if err != nil {
+			return err
+		}
+		elements := make([]OBJ, len(indexes))
+		for i, idx := range indexes {
+			elements[i] = val.Elements[idx]
+		}
+		return &object.Array{Elements: elements}
+	case *object.String:
+		chars := []rune(val.Value)
+		indexes, err := sliceIndexes(len(chars), start, end, step)
+		if err != nil {
+			return err
+		}
+		sliced := make([]rune, len(indexes))
+		for i, idx := range indexes {
+			sliced[i] = chars[idx]
+		}
+		return &object.String{Value: string(sliced)}
+	}
+	return NewError("can't slice %s", val.Type())
+}
+
+// sliceIndexes returns the positions a slice of something of length n
+// takes, in order.
+func sliceIndexes(n int, start, end, step OBJ) ([]int, OBJ) {
+	from, to, by, err := sliceBounds(n, start, end, step)
+	if err != nil {
+		return nil, err
+	}
+	indexes := []int{}
+	for i := from; (by > 0 && i < to) || (by < 0 && i > to); i += by {
+		indexes = append(indexes, i)
+	}
+	return indexes, nil
+}
+
+// sliceBounds returns the position a slice of something of length n
+// starts at, the one it stops before, and its step. Like in Python, a
+// start or end that's out of range is moved to the nearest end.
+func sliceBounds(n int, start, end, step OBJ) (int, int, int, OBJ) {
+	integer := func(obj OBJ) (int, OBJ) {
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return 0, NewError("slice indexes must be integers, got %s",
+				obj.Type())
+		}
+		return int(i.Value), nil
+	}
+	missing := func(obj OBJ) bool {
+		return obj == nil || obj == NULL
+	}
+
+	by := 1
+	if !missing(step) {
+		var err OBJ
+		if by, err = integer(step); err != nil {
+			return 0, 0, 0, err
+		}
+		if by == 0 {
+			return 0, 0, 0, NewError("slice step can't be zero")
+		}
+	}
+
+	// where a slice starts and ends by default; going backwards, it's
+	// from the last element to just before the first
+	first, last := 0, n
+	if by < 0 {
+		first, last = n-1, -1
+	}
+	position := func(obj OBJ, otherwise int) (int, OBJ) {
+		if missing(obj) {
+			return otherwise, nil
+		}
+		i, err := integer(obj)
+		if err != nil {
+			return 0, err
+		}
+		if i < 0 {
+			i += n
+		}
+		if i < 0 {
+			i = 0
+			if by < 0 {
+				i = -1
+			}
+		}
+		if i > n {
+			i = n
+		}
+		if i == n && by < 0 {
+			i = n - 1
+		}
+		return i, nil
+	}
+	from, err := position(start, first)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	to, err := position(end, last)
+	return from, to, by, err
+}
@@ -0,0 +1,306 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/zautumnz/keai/object"
+	"github.com/zautumnz/keai/utils"
+)
+
+// ColorDiffs colors the diffs printed by failed assertions. It's on when
+// STDOUT is a terminal, unless NO_COLOR is set.
+var ColorDiffs = utils.IsTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""
+
+// assertResult finishes an assertion. The assertion is labelled with the
+// optional message argument at args[i], or its name. Inside a test, a
+// failure is recorded against the test. Anywhere else it's fatal, like
+// util.assert.
+func assertResult(
+	ok bool,
+	name string,
+	args []OBJ,
+	i int,
+	failure string,
+) OBJ {
+	label := name
+	if len(args) > i {
+		if s, isString := args[i].(*object.String); isString {
+			label = s.Value
+		} else {
+			label = args[i].Inspect()
+		}
+	}
+
+	if ok {
+		recordAssertion(true, label)
+		return TRUE
+	}
+
+	msg := label + ": " + failure
+	if running != nil {
+		recordAssertion(false, msg)
+		return FALSE
+	}
+
+	fmt.Println("Assertion failed: " + msg)
+	utils.ExitConditionally(1)
+	return NewError("Assertion failed: %s", msg)
+}
+
+// describe formats a value for an assertion message.
+func describe(o OBJ) string {
+	if s, ok := o.(*object.String); ok {
+		return fmt.Sprintf("%q", s.Value)
+	}
+	return o.Inspect()
+}
+
+// equal(actual, expected, [message]) compares with ==
+func assertEqualFn(env *ENV, args ...OBJ) OBJ {
+	if len(args) < 2 {
+		return NewError("assert.equal expects an actual and expected value")
+	}
+	res := evalInfixExpression("==", args[0], args[1], env)
+	return assertResult(res == TRUE, "assert.equal", args, 2, fmt.Sprintf(
+		"expected %s to equal %s", describe(args[0]), describe(args[1]),
+	))
+}
+
+// not_equal(actual, expected, [message]) compares with ==
+func assertNotEqualFn(env *ENV, args ...OBJ) OBJ {
+	if len(args) < 2 {
+		return NewError("assert.not_equal expects an actual and expected value")
+	}
+	res := evalInfixExpression("==", args[0], args[1], env)
+	return assertResult(res != TRUE, "assert.not_equal", args, 2, fmt.Sprintf(
+		"expected %s not to equal %s", describe(args[0]), describe(args[1]),
+	))
+}
+
+// deep_equal(actual, expected, [message]) compares structurally, showing
+// a diff on failure
+func assertDeepEqualFn(args ...OBJ) OBJ {
+	if len(args) < 2 {
+		return NewError("assert.deep_equal expects an actual and expected value")
+	}
+	ok := object.Equal(args[1], args[0])
+	failure := ""
+	if !ok {
+		failure = "values are not deeply equal\n" +
+			object.Diff(args[1], args[0], ColorDiffs)
+	}
+	return assertResult(ok, "assert.deep_equal", args, 2, failure)
+}
+
+// throws(fn, [expected]) passes if calling fn returns an error or fails
+// fatally; expected must be in the error's message if given
+func assertThrowsFn(env *ENV, args ...OBJ) OBJ {
+	if len(args) < 1 {
+		return NewError("assert.throws expects a function")
+	}
+
+	var ret OBJ
+	exited, code := catchExit(func() {
+		ret = ApplyFunction(env, args[0], []OBJ{})
+	})
+
+	var message string
+	switch {
+	case exited:
+		message = fmt.Sprintf("exited with code %d", code)
+	case isError(ret):
+		message = ret.(*object.Error).Message
+	default:
+		return assertResult(false, "assert.throws", args, 2,
+			"expected function to throw, got "+describe(ret))
+	}
+
+	if len(args) > 1 {
+		expected, ok := args[1].(*object.String)
+		if !ok {
+			return NewError("assert.throws expected a string, got %s",
+				args[1].Type())
+		}
+		return assertResult(
+			strings.Contains(message, expected.Value),
+			"assert.throws",
+			args,
+			2,
+			fmt.Sprintf("expected error containing %q, got %q",
+				expected.Value, message),
+		)
+	}
+	return assertResult(true, "assert.throws", args, 2, "")
+}
+
+// matches(string, pattern, [message]) checks a regular expression
+func assertMatchesFn(args ...OBJ) OBJ {
+	if len(args) < 2 {
+		return NewError("assert.matches expects a string and a pattern")
+	}
+	s, ok := args[0].(*object.String)
+	if !ok {
+		return NewError("assert.matches expected a string, got %s",
+			args[0].Type())
+	}
+	p, ok := args[1].(*object.String)
+	if !ok {
+		return NewError("assert.matches expected a string pattern, got %s",
+			args[1].Type())
+	}
+	re, err := regexp.Compile(p.Value)
+	if err != nil {
+		return NewError("assert.matches got an invalid pattern: %s", err)
+	}
+	return assertResult(re.MatchString(s.Value), "assert.matches", args, 2,
+		fmt.Sprintf("expected %q to match /%s/", s.Value, p.Value))
+}
+
+// contains(haystack, needle, [message]) checks for a substring, an array
+// element, or a hash key
+func assertContainsFn(args ...OBJ) OBJ {
+	if len(args) < 2 {
+		return NewError("assert.contains expects a collection and a value")
+	}
+
+	found := false
+	if r, ok := args[0].(*object.Range); ok {
+		args[0] = r.Array()
+	}
+	switch h := args[0].(type) {
+	case *object.String:
+		n, ok := args[1].(*object.String)
+		found = ok && strings.Contains(h.Value, n.Value)
+	case *object.Array:
+		for _, e := range h.Elements {
+			if object.Equal(e, args[1]) {
+				found = true
+				break
+			}
+		}
+	case *object.Hash:
+		if k, ok := args[1].(object.Hashable); ok {
+			_, found = h.Pairs[k.HashKey()]
+		}
+	default:
+		return NewError(
+			"assert.contains expected a string, array, or hash, got %s",
+			args[0].Type(),
+		)
+	}
+
+	return assertResult(found, "assert.contains", args, 2, fmt.Sprintf(
+		"expected %s to contain %s", describe(args[0]), describe(args[1]),
+	))
+}
+
+// toFloat converts a number to a float64.
+func toFloat(o OBJ) (float64, bool) {
+	switch n := o.(type) {
+	case *object.Integer:
+		return float64(n.Value), true
+	case *object.Float:
+		return n.Value, true
+	}
+	return 0, false
+}
+
+// approx(actual, expected, [epsilon], [message]) compares numbers within
+// epsilon, which defaults to 1e-9
+func assertApproxFn(args ...OBJ) OBJ {
+	if len(args) < 2 {
+		return NewError("assert.approx expects an actual and expected number")
+	}
+	a, ok1 := toFloat(args[0])
+	e, ok2 := toFloat(args[1])
+	if !ok1 || !ok2 {
+		return NewError("assert.approx expected numbers, got %s and %s",
+			args[0].Type(), args[1].Type())
+	}
+
+	epsilon := 1e-9
+	msgIndex := 2
+	if len(args) > 2 {
+		if eps, ok := toFloat(args[2]); ok {
+			epsilon = eps
+			msgIndex = 3
+		}
+	}
+
+	ok := math.Abs(a-e) <= epsilon
+	return assertResult(ok, "assert.approx", args, msgIndex, fmt.Sprintf(
+		"expected %v to be within %v of %v", a, epsilon, e,
+	))
+}
+
+// type_of(value, type, [message]) checks a type, as named by util.type
+func assertTypeOfFn(args ...OBJ) OBJ {
+	if len(args) < 2 {
+		return NewError("assert.type_of expects a value and a type name")
+	}
+	t, ok := args[1].(*object.String)
+	if !ok {
+		return NewError("assert.type_of expected a string type name, got %s",
+			args[1].Type())
+	}
+	actual := strings.ToLower(string(args[0].Type()))
+	return assertResult(actual == t.Value, "assert.type_of", args, 2,
+		fmt.Sprintf("expected %s to be a %s, got %s",
+			describe(args[0]), t.Value, actual))
+}
+
+func init() {
+	RegisterBuiltin("assert.equal",
+		"assert.equal(actual, expected, [message]) checks that actual == "+
+			"expected.",
+		func(env *ENV, args ...OBJ) OBJ {
+			return assertEqualFn(env, args...)
+		})
+	RegisterBuiltin("assert.not_equal",
+		"assert.not_equal(actual, expected, [message]) checks that actual "+
+			"!= expected.",
+		func(env *ENV, args ...OBJ) OBJ {
+			return assertNotEqualFn(env, args...)
+		})
+	RegisterBuiltin("assert.deep_equal",
+		"assert.deep_equal(actual, expected, [message]) compares arrays "+
+			"and hashes structurally, showing a diff if they differ.",
+		func(env *ENV, args ...OBJ) OBJ {
+			return assertDeepEqualFn(args...)
+		})
+	RegisterBuiltin("assert.throws",
+		"assert.throws(fn, [expected]) checks that calling fn returns an "+
+			"error or fails; if expected is given, it must be in the error's "+
+			"message.",
+		func(env *ENV, args ...OBJ) OBJ {
+			return assertThrowsFn(env, args...)
+		})
+	RegisterBuiltin("assert.matches",
+		"assert.matches(string, pattern, [message]) checks that string "+
+			"matches a regular expression.",
+		func(env *ENV, args ...OBJ) OBJ {
+			return assertMatchesFn(args...)
+		})
+	RegisterBuiltin("assert.contains",
+		"assert.contains(haystack, needle, [message]) checks for a "+
+			"substring, an array element, or a hash key.",
+		func(env *ENV, args ...OBJ) OBJ {
+			return assertContainsFn(args...)
+		})
+	RegisterBuiltin("assert.approx",
+		"assert.approx(actual, expected, [epsilon], [message]) checks that "+
+			"two numbers are within epsilon, which defaults to 1e-9.",
+		func(env *ENV, args ...OBJ) OBJ {
+			return assertApproxFn(args...)
+		})
+	RegisterBuiltin("assert.type_of",
+		"assert.type_of(value, type, [message]) checks a value's type, as "+
+			"named by util.type.",
+		func(env *ENV, args ...OBJ) OBJ {
+			return assertTypeOfFn(args...)
+		})
+}
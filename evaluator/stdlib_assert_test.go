@@ -0,0 +1,71 @@
+package evaluator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/zautumnz/keai/lexer"
+	"github.com/zautumnz/keai/object"
+	"github.com/zautumnz/keai/parser"
+)
+
+// runAssertion runs code in a collected test, returning its failures.
+func runAssertion(t *testing.T, code string) []string {
+	l := lexer.New(`core.test("assertion", fn (t) { ` + code + ` })`)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	file, err := CollectTests(program, object.NewEnvironment())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return RunTest(context.Background(), file, file.Tests[0]).Failures
+}
+
+func TestAssert(t *testing.T) {
+	tests := []struct {
+		code    string
+		failure string
+	}{
+		{`assert.equal(1 + 1, 2)`, ""},
+		{`assert.equal(1, 2)`, "expected 1 to equal 2"},
+		{`assert.equal("a", "b", "letters")`, `letters: expected "a" to equal "b"`},
+		{`assert.not_equal(1, 2)`, ""},
+		{`assert.not_equal(1, 1)`, "expected 1 not to equal 1"},
+		{`assert.deep_equal({"a": [1, {"b": 2}]}, {"a": [1, {"b": 2}]})`, ""},
+		{`assert.deep_equal([1, 2], [1, 3])`, "-     3,\n+     2,"},
+		{`assert.throws(fn () { 1 + "a" })`, ""},
+		{`assert.throws(fn () { error("boom") }, "boom")`, ""},
+		{`assert.throws(fn () { error("bang") }, "boom")`, `expected error containing "boom", got "bang"`},
+		{`assert.throws(fn () { 1 })`, "expected function to throw, got 1"},
+		{`assert.matches("keai", "^k.*i$")`, ""},
+		{`assert.matches("keai", "^x")`, `expected "keai" to match /^x/`},
+		{`assert.contains("keai", "ea")`, ""},
+		{`assert.contains([1, [2]], [2])`, ""},
+		{`assert.contains({"k": 1}, "k")`, ""},
+		{`assert.contains([1], 2)`, "expected [1] to contain 2"},
+		{`assert.approx(0.1 + 0.2, 0.3)`, ""},
+		{`assert.approx(1, 1.05, 0.1)`, ""},
+		{`assert.approx(1, 2, 0.5, "close")`, "close: expected 1 to be within 0.5 of 2"},
+		{`assert.type_of([], "array")`, ""},
+		{`assert.type_of(1, "string")`, "expected 1 to be a string, got integer"},
+	}
+
+	for _, tt := range tests {
+		failures := runAssertion(t, tt.code)
+		if tt.failure == "" {
+			if len(failures) != 0 {
+				t.Errorf("%s: expected to pass, got %q", tt.code, failures)
+			}
+			continue
+		}
+		if len(failures) != 1 || !strings.Contains(failures[0], tt.failure) {
+			t.Errorf("%s: expected failure %q, got %q",
+				tt.code, tt.failure, failures)
+		}
+	}
+}
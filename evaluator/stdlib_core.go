@@ -135,18 +135,26 @@ func matchFn(args ...OBJ) OBJ {
 
 func init() {
 	RegisterBuiltin("core.match",
+		"core.match(pattern, string) matches a regular expression, "+
+			"returning the match and its groups, or an empty array.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return matchFn(args...)
 		})
 	RegisterBuiltin("core.async",
+		"core.async(fn) calls fn in the background and returns an id to "+
+			"pass to core.await.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return asyncFn(env, args...)
 		})
 	RegisterBuiltin("core.await",
+		"core.await(id) waits for a function started with core.async and "+
+			"returns what it returned.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return awaitFn(env, args...)
 		})
 	RegisterBuiltin("core.background",
+		"core.background(fn) calls fn in the background, ignoring what it "+
+			"returns.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return backgroundFn(env, args...)
 		})
@@ -3,7 +3,6 @@ package evaluator
 import (
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -279,7 +278,7 @@ func cpFn(args ...OBJ) OBJ {
 func templateFn(env *ENV, args ...OBJ) OBJ {
 	switch a := args[0].(type) {
 	case *object.String:
-		b, err := ioutil.ReadFile(a.Value)
+		b, err := readAsset(a.Value)
 		if err != nil {
 			return NewError("Error reading template file: %s", err)
 		}
@@ -293,38 +292,53 @@ func templateFn(env *ENV, args ...OBJ) OBJ {
 
 func init() {
 	RegisterBuiltin("fs.glob",
+		"fs.glob(pattern) returns an array of the paths matching a glob "+
+			"pattern.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return fsGlob(args...)
 		})
 	RegisterBuiltin("fs.chmod",
+		"fs.chmod(path, mode) changes a file's mode, given as an octal "+
+			"string like \"755\".",
 		func(env *ENV, args ...OBJ) OBJ {
 			return chmodFn(args...)
 		})
 	RegisterBuiltin("fs.mkdir",
+		"fs.mkdir(path) makes a directory, and any missing parents.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return mkdirFn(args...)
 		})
 	RegisterBuiltin("fs.open",
+		"fs.open(path, [mode]) opens a file, for reading unless mode says "+
+			"otherwise.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return openFn(args...)
 		})
 	RegisterBuiltin("fs.stat",
+		"fs.stat(path) returns a hash of a file's size, mtime, perm, mode, "+
+			"and type, or an empty hash if it doesn't exist.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return statFn(args...)
 		})
 	RegisterBuiltin("fs.rm",
+		"fs.rm(path) removes a file or empty directory, returning whether "+
+			"it worked.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return rmFn(args...)
 		})
 	RegisterBuiltin("fs.mv",
+		"fs.mv(from, to) moves a file.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return mvFn(args...)
 		})
 	RegisterBuiltin("fs.cp",
+		"fs.cp(from, to) copies a regular file.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return cpFn(args...)
 		})
 	RegisterBuiltin("fs.tmpl",
+		"fs.tmpl(path) reads a file and fills in its {{...}} parts, like a "+
+			"string.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return templateFn(env, args...)
 		})
@@ -406,6 +406,9 @@ func httpClient(args ...OBJ) OBJ {
 
 func init() {
 	RegisterBuiltin("http.create_client",
+		"http.create_client(method, url, [headers], [body]) makes a "+
+			"request, returning a hash of its status_code, protocol, headers, "+
+			"and body.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return httpClient(args...)
 		})
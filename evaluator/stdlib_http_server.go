@@ -264,7 +264,7 @@ func (a *app) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	for _, h := range staticHandlers {
 		if strings.HasPrefix(ctx.URL.Path, h.Mount) {
-			http.FileServer(neuteredFileSystem{http.Dir(h.Path)}).ServeHTTP(w, r)
+			http.FileServer(neuteredFileSystem{assetDir(h.Path)}).ServeHTTP(w, r)
 			return
 		}
 	}
@@ -418,6 +418,8 @@ func init() {
 	staticHandlers = make([]staticHandlerMount, 0)
 
 	RegisterBuiltin("http.create_server",
+		"http.create_server() returns a server, a hash of its listen, "+
+			"route, and static functions.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return httpServer(env, args...)
 		})
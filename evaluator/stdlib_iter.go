@@ -0,0 +1,351 @@
+package evaluator
+
+import (
+	"github.com/zautumnz/keai/object"
+)
+
+// cursor returns a function that gets each value of an iterable in turn,
+// and false after the last one. Ranges, arrays, and strings get their own
+// cursor, so the same one can be iterated over more than once at a time.
+func cursor(obj OBJ, env *ENV) (func() (OBJ, bool), OBJ) {
+	switch obj := obj.(type) {
+	case *object.Range:
+		i := int64(0)
+		return func() (OBJ, bool) {
+			n, ok := obj.At(i)
+			if !ok {
+				return nil, false
+			}
+			i++
+			return &object.Integer{Value: n}, true
+		}, nil
+	case *object.Array:
+		i := 0
+		return func() (OBJ, bool) {
+			if i >= len(obj.Elements) {
+				return nil, false
+			}
+			i++
+			return obj.Elements[i-1], true
+		}, nil
+	case *object.String:
+		chars := []rune(obj.Value)
+		i := 0
+		return func() (OBJ, bool) {
+			if i >= len(chars) {
+				return nil, false
+			}
+			i++
+			return &object.String{Value: string(chars[i-1])}, true
+		}, nil
+	case *object.Iterator:
+		return obj.Start(), nil
+	case *object.Generator:
+		return obj.Resume, nil
+	}
+
+	helper, ok := obj.(object.Iterable)
+	if !ok {
+		return nil, NewError("%s object isn't iterable", obj.Type())
+	}
+	helper.Reset()
+	return func() (OBJ, bool) {
+		val, _, ok := helper.Next()
+		return val, ok
+	}, nil
+}
+
+// hashIterator returns an iterator for a hash with a next function, which
+// returns a hash with the next value and whether it's done, like
+// {"value": 1} or {"done": true}. If the hash has a reset function, it's
+// called before each iteration. It returns nil for other hashes. Only
+// iter.from does this, so a hash that happens to have a next key still
+// iterates like any other hash.
+func hashIterator(hash *object.Hash, env *ENV) *object.Iterator {
+	method := func(name string) OBJ {
+		key := &object.String{Value: name}
+		if pair, ok := hash.Pairs[key.HashKey()]; ok {
+			switch pair.Value.(type) {
+			case *object.Function, *object.Builtin:
+				return pair.Value
+			}
+		}
+		return nil
+	}
+	next := method("next")
+	if next == nil {
+		return nil
+	}
+	reset := method("reset")
+
+	return &object.Iterator{Start: func() func() (OBJ, bool) {
+		if reset != nil {
+			if res := ApplyFunction(env, reset, []OBJ{}); isError(res) {
+				return func() (OBJ, bool) { return res, true }
+			}
+		}
+		return func() (OBJ, bool) {
+			res := ApplyFunction(env, next, []OBJ{})
+			if isError(res) {
+				return res, true
+			}
+			step, ok := res.(*object.Hash)
+			if !ok {
+				return NewError("next should return a hash, got %s",
+					res.Type()), true
+			}
+			get := func(key string) OBJ {
+				k := &object.String{Value: key}
+				if pair, ok := step.Pairs[k.HashKey()]; ok {
+					return pair.Value
+				}
+				return NULL
+			}
+			if isTruthy(get("done")) {
+				return nil, false
+			}
+			return get("value"), true
+		}
+	}}
+}
+
+// iterable returns an error if obj can't be iterated over.
+func iterable(obj OBJ) OBJ {
+	if _, ok := obj.(object.Iterable); !ok {
+		return NewError("%s object isn't iterable", obj.Type())
+	}
+	return nil
+}
+
+// stop tells an iterable nothing more will be taken from it.
+func stop(obj OBJ) {
+	if stopper, ok := obj.(object.Stopper); ok {
+		stopper.Stop()
+	}
+}
+
+// iterator returns an iterator over the values of an iterable, with each
+// passed through step: step returns what to produce, or false to skip
+// the value, and whether to go on after it. Errors are produced as they
+// are, and end the iteration.
+func iterator(
+	obj OBJ,
+	env *ENV,
+	step func(val OBJ, i int64) (OBJ, bool, bool),
+) OBJ {
+	if err := iterable(obj); err != nil {
+		return err
+	}
+	finish := func() { stop(obj) }
+	return &object.Iterator{Finish: finish, Start: func() func() (OBJ, bool) {
+		next, _ := cursor(obj, env)
+		i := int64(0)
+		done := false
+		return func() (OBJ, bool) {
+			for !done {
+				val, ok := next()
+				if !ok {
+					break
+				}
+				if isError(val) {
+					done = true
+					return val, true
+				}
+				res, keep, more := step(val, i)
+				i++
+				if !more {
+					done = true
+					finish()
+				}
+				if isError(res) {
+					done = true
+					return res, true
+				}
+				if keep {
+					return res, true
+				}
+			}
+			done = true
+			return nil, false
+		}
+	}}
+}
+
+func iterFrom(env *ENV, args ...OBJ) OBJ {
+	if len(args) != 1 {
+		return NewError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	if hash, ok := args[0].(*object.Hash); ok {
+		if it := hashIterator(hash, env); it != nil {
+			return it
+		}
+	}
+	return iterator(args[0], env, func(val OBJ, i int64) (OBJ, bool, bool) {
+		return val, true, true
+	})
+}
+
+func iterMap(env *ENV, args ...OBJ) OBJ {
+	if len(args) != 2 {
+		return NewError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	fn := args[1]
+	return iterator(args[0], env, func(val OBJ, i int64) (OBJ, bool, bool) {
+		return ApplyFunction(env, fn, []OBJ{val}), true, true
+	})
+}
+
+func iterFilter(env *ENV, args ...OBJ) OBJ {
+	if len(args) != 2 {
+		return NewError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	fn := args[1]
+	return iterator(args[0], env, func(val OBJ, i int64) (OBJ, bool, bool) {
+		keep := ApplyFunction(env, fn, []OBJ{val})
+		if isError(keep) {
+			return keep, true, false
+		}
+		return val, isTruthy(keep), true
+	})
+}
+
+func iterTake(env *ENV, args ...OBJ) OBJ {
+	if len(args) != 2 {
+		return NewError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	n, ok := args[1].(*object.Integer)
+	if !ok {
+		return NewError("iter.take expected an integer, got %s",
+			args[1].Type())
+	}
+	src := args[0]
+	if n.Value <= 0 {
+		// don't take anything from src
+		src = &object.Array{}
+	}
+	return iterator(src, env, func(val OBJ, i int64) (OBJ, bool, bool) {
+		return val, true, i+1 < n.Value
+	})
+}
+
+func iterEnumerate(env *ENV, args ...OBJ) OBJ {
+	if len(args) != 1 {
+		return NewError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	return iterator(args[0], env, func(val OBJ, i int64) (OBJ, bool, bool) {
+		pair := []OBJ{&object.Integer{Value: i}, val}
+		return &object.Array{Elements: pair}, true, true
+	})
+}
+
+// zip produces arrays of the next value of each of its arguments, until
+// one of them runs out.
+func iterZip(env *ENV, args ...OBJ) OBJ {
+	if len(args) == 0 {
+		return NewError("iter.zip expected at least one argument")
+	}
+	for _, arg := range args {
+		if err := iterable(arg); err != nil {
+			return err
+		}
+	}
+	finish := func() {
+		for _, arg := range args {
+			stop(arg)
+		}
+	}
+	return &object.Iterator{Finish: finish, Start: func() func() (OBJ, bool) {
+		nexts := make([]func() (OBJ, bool), len(args))
+		for i, arg := range args {
+			nexts[i], _ = cursor(arg, env)
+		}
+		done := false
+		return func() (OBJ, bool) {
+			if done {
+				return nil, false
+			}
+			vals := make([]OBJ, len(nexts))
+			for i, next := range nexts {
+				val, ok := next()
+				if !ok || isError(val) {
+					done = true
+					finish()
+					return val, ok
+				}
+				vals[i] = val
+			}
+			return &object.Array{Elements: vals}, true
+		}
+	}}
+}
+
+func iterToArray(env *ENV, args ...OBJ) OBJ {
+	if len(args) != 1 {
+		return NewError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	next, err := cursor(args[0], env)
+	if err != nil {
+		return err
+	}
+	elements := []OBJ{}
+	for {
+		val, ok := next()
+		if !ok {
+			return &object.Array{Elements: elements}
+		}
+		if isError(val) {
+			return val
+		}
+		elements = append(elements, val)
+	}
+}
+
+func init() {
+	RegisterBuiltin("iter.from",
+		"iter.from(iterable) returns an iterator over a range, array, "+
+			"string, or hash. A hash with a next function is iterated by "+
+			"calling it, which is how to make your own iterators.",
+		func(env *ENV, args ...OBJ) OBJ {
+			return iterFrom(env, args...)
+		})
+	RegisterBuiltin("iter.map",
+		"iter.map(iterable, fn) lazily passes each value through fn.",
+		func(env *ENV, args ...OBJ) OBJ {
+			return iterMap(env, args...)
+		})
+	RegisterBuiltin("iter.filter",
+		"iter.filter(iterable, fn) lazily keeps the values fn returns "+
+			"true for.",
+		func(env *ENV, args ...OBJ) OBJ {
+			return iterFilter(env, args...)
+		})
+	RegisterBuiltin("iter.take",
+		"iter.take(iterable, n) lazily stops after the first n values.",
+		func(env *ENV, args ...OBJ) OBJ {
+			return iterTake(env, args...)
+		})
+	RegisterBuiltin("iter.zip",
+		"iter.zip(iterables...) lazily pairs up the values of each, as "+
+			"arrays, until one runs out.",
+		func(env *ENV, args ...OBJ) OBJ {
+			return iterZip(env, args...)
+		})
+	RegisterBuiltin("iter.enumerate",
+		"iter.enumerate(iterable) lazily pairs each value with its index, "+
+			"as [i, value].",
+		func(env *ENV, args ...OBJ) OBJ {
+			return iterEnumerate(env, args...)
+		})
+	RegisterBuiltin("iter.to_array",
+		"iter.to_array(iterable) returns all the values of an iterable "+
+			"in an array.",
+		func(env *ENV, args ...OBJ) OBJ {
+			return iterToArray(env, args...)
+		})
+}
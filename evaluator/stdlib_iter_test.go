@@ -0,0 +1,113 @@
+package evaluator
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/zautumnz/keai/utils"
+)
+
+func TestIter(t *testing.T) {
+	prelude := `let counter = fn (n) {
+    mutable i = 0
+    {
+        "reset": fn () { i = 0 },
+        "next": fn () {
+            i = i + 1
+            if i > n { {"done": true} } else { {"value": i} }
+        },
+    }
+};
+`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`iter.to_array(iter.from(counter(3)))`, "[1, 2, 3]"},
+		{`iter.to_array(iter.map(1..3, fn (x) { x * 2 }))`, "[2, 4, 6]"},
+		{
+			`iter.to_array(iter.filter(iter.from(counter(6)), ` +
+				`fn (x) { x % 2 == 0 }))`,
+			"[2, 4, 6]",
+		},
+		// lazy, so the range is never built
+		{`iter.to_array(iter.take(0..1000000000000, 3))`, "[0, 1, 2]"},
+		{`iter.to_array(iter.take(iter.from(counter(5)), 0))`, "[]"},
+		{`iter.to_array(iter.zip("ab", 1..5, iter.from(counter(9))))`,
+			"[[a, 1, 1], [b, 2, 2]]"},
+		{`iter.to_array(iter.enumerate(["x", "y"]))`, "[[0, x], [1, y]]"},
+		// iterators can be iterated over again, and at the same time
+		{`let c = iter.from(counter(2)); [iter.to_array(c), iter.to_array(c)]`,
+			"[[1, 2], [1, 2]]"},
+		{`let r = 1..3; iter.to_array(iter.zip(r, r))`,
+			"[[1, 1], [2, 2], [3, 3]]"},
+		{`iter.map(1, fn (x) { x })`, "ERROR: INTEGER object isn't iterable"},
+		{`iter.to_array(iter.map(1..3, fn (x) { error("bad") }))`,
+			"ERROR: bad"},
+		{`iter.to_array(iter.from({"next": fn () { 1 }}))`,
+			"ERROR: next should return a hash, got INTEGER"},
+	}
+	utils.SetReplOrRun(true)
+	for _, tt := range tests {
+		evaluated := testEval(prelude + tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected,
+				evaluated.Inspect())
+		}
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	prelude := `let count = fn (from) {
+    mutable i = from
+    for true {
+        yield i
+        i = i + 1
+    }
+}
+let lines = fn (xs) {
+    foreach x in xs {
+        if x == "stop" { return null }
+        yield x
+    }
+}
+`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`iter.to_array(lines(["a", "b"]))`, "[a, b]"},
+		{`iter.to_array(lines(["a", "stop", "b"]))`, "[a]"},
+		{`iter.to_array(iter.take(count(5), 3))`, "[5, 6, 7]"},
+		{`fn () { foreach x in count(1) { if x > 2 { return x } } }()`, "3"},
+		{`let g = lines(["a"]); [g.next(), g.next()]`,
+			"[{value: a}, {done: true}]"},
+		{`let g = count(1); g.next(); g.stop(); g.next()`, "{done: true}"},
+		{`iter.to_array(fn () { yield 1; error("boom") }())`, "ERROR: boom"},
+		{`util.type(count(1))`, "generator"},
+	}
+	utils.SetReplOrRun(true)
+	for _, tt := range tests {
+		evaluated := testEval(prelude + tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected,
+				evaluated.Inspect())
+		}
+	}
+
+	// stopping early doesn't leave generators waiting to be resumed
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		testEval(prelude +
+			`fn () { foreach x in count(1) { if x > 2 { return x } } }()`)
+		testEval(prelude + `iter.to_array(iter.take(count(1), 2))`)
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines left running", n-before)
+	}
+}
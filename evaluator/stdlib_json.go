@@ -75,10 +75,13 @@ func jsonSerialize(args ...OBJ) OBJ {
 
 func init() {
 	RegisterBuiltin("json.deserialize",
+		"json.deserialize(string) parses JSON into keai values.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return jsonDeserialize(args...)
 		})
 	RegisterBuiltin("json.serialize",
+		"json.serialize(value, [indent]) returns value as JSON, indented "+
+			"if indent is true.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return jsonSerialize(args...)
 		})
@@ -62,14 +62,17 @@ func init() {
 	// Setup our random seed.
 	rand.Seed(time.Now().UnixNano())
 	RegisterBuiltin("math.abs",
+		"math.abs(number) returns the absolute value of a number.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return mathAbs(args...)
 		})
 	RegisterBuiltin("math.rand",
+		"math.rand() returns a random float between 0 and 1.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return mathRandom(args...)
 		})
 	RegisterBuiltin("math.sqrt",
+		"math.sqrt(number) returns the square root of a number.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return mathSqrt(args...)
 		})
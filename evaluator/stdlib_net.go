@@ -299,34 +299,47 @@ func Read(args ...OBJ) OBJ {
 
 func init() {
 	RegisterBuiltin("net.socket",
+		"net.socket(type) makes a socket, where type is unix, tcp4, tcp6, "+
+			"udp4, or udp6, and returns its file descriptor.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return Socket(args...)
 		})
 	RegisterBuiltin("net.listen",
+		"net.listen(fd, backlog) listens on a bound socket.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return Listen(args...)
 		})
 	RegisterBuiltin("net.connect",
+		"net.connect(fd, address) connects a socket to an address like "+
+			"\"127.0.0.1:8080\".",
 		func(env *ENV, args ...OBJ) OBJ {
 			return Connect(args...)
 		})
 	RegisterBuiltin("net.close",
+		"net.close(fd) closes a socket.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return Close(args...)
 		})
 	RegisterBuiltin("net.bind",
+		"net.bind(fd, address) binds a socket to an address like "+
+			"\"0.0.0.0:8080\".",
 		func(env *ENV, args ...OBJ) OBJ {
 			return Bind(args...)
 		})
 	RegisterBuiltin("net.accept",
+		"net.accept(fd) waits for a connection and returns its file "+
+			"descriptor.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return Accept(args...)
 		})
 	RegisterBuiltin("net.write",
+		"net.write(fd, string) writes to a socket, returning how many "+
+			"bytes were written.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return Write(args...)
 		})
 	RegisterBuiltin("net.read",
+		"net.read(fd, [size]) reads up to size bytes, or 4096, from a socket.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return Read(args...)
 		})
@@ -149,11 +149,19 @@ func sysExec(args ...OBJ) OBJ {
 	})
 }
 
+// scriptArgs are what sys.args returns: the name of the running script
+// followed by its arguments, without the interpreter's own.
+var scriptArgs = os.Args[1:]
+
+// SetArgs sets the script's arguments, starting with its name.
+func SetArgs(args []string) {
+	scriptArgs = args
+}
+
 // Implemention of "args()" function.
 func argsFn(args ...OBJ) OBJ {
-	l := len(os.Args[1:])
-	result := make([]OBJ, l)
-	for i, txt := range os.Args[1:] {
+	result := make([]OBJ, len(scriptArgs))
+	for i, txt := range scriptArgs {
 		result[i] = &object.String{Value: txt}
 	}
 	return &object.Array{Elements: result}
@@ -165,9 +173,13 @@ func flagFn(args ...OBJ) OBJ {
 	name := args[0].(*object.String)
 	found := false
 
-	// Loop through all the arguments passed to the script
+	// Loop through all the arguments passed to the script, skipping its name
 	// This is O(n), but performance is not a big deal
-	for _, v := range os.Args {
+	for i, v := range scriptArgs {
+		if i == 0 {
+			continue
+		}
+
 		// If the flag was found in the previous argument...
 		if found {
 			// ...and the next one is another flag
@@ -224,41 +236,76 @@ func infoFn(args ...OBJ) OBJ {
 	})
 }
 
+// modules() -> (Hash) of every loaded module, keyed by import name
+func modulesFn(args ...OBJ) OBJ {
+	mods := make(StringObjectMap)
+	for _, path := range importOrder {
+		m := importCache[path]
+		mods[m.Name] = NewHash(StringObjectMap{
+			"path": &object.String{Value: m.Path},
+			"loaded_at": &object.Float{
+				Value: float64(m.LoadedAt.UnixNano() / 1000000),
+			},
+			"load_time": &object.Float{
+				Value: float64(m.Duration.Microseconds()) / 1000,
+			},
+		})
+	}
+	return NewHash(mods)
+}
+
 func init() {
 	RegisterBuiltin("sys.getenv",
+		"sys.getenv(name) returns an environment variable.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return getEnvFn(args...)
 		})
 	RegisterBuiltin("sys.setenv",
+		"sys.setenv(name, value) sets an environment variable.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return setEnvFn(args...)
 		})
 	RegisterBuiltin("sys.environment",
+		"sys.environment() returns a hash of every environment variable.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return envFn(args...)
 		})
 	RegisterBuiltin("sys.exit",
+		"sys.exit([code]) exits with code, or 0.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return sysExit(args...)
 		})
 	RegisterBuiltin("sys.exec",
+		"sys.exec(command) runs a command, returning a hash of its stdout "+
+			"and stderr.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return sysExec(args...)
 		})
 	RegisterBuiltin("sys.flag",
+		"sys.flag(name) returns the value of --name or -name, true if it "+
+			"has no value, or false if it wasn't given.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return flagFn(args...)
 		})
 	RegisterBuiltin("sys.args",
+		"sys.args() returns the script's name followed by its arguments.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return argsFn(args...)
 		})
 	RegisterBuiltin("sys.cd",
+		"sys.cd(path) changes the working directory.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return cdFn(args...)
 		})
 	RegisterBuiltin("sys.info",
+		"sys.info() returns a hash of the os, arch, and number of cpus.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return infoFn(args...)
 		})
+	RegisterBuiltin("sys.modules",
+		"sys.modules() returns a hash of every loaded module's path, "+
+			"loaded_at, and load_time, keyed by the name it was imported as.",
+		func(env *ENV, args ...OBJ) OBJ {
+			return modulesFn(args...)
+		})
 }
@@ -0,0 +1,325 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zautumnz/keai/ast"
+	"github.com/zautumnz/keai/object"
+	"github.com/zautumnz/keai/utils"
+)
+
+// Tests are written with core.test. When a program is run directly, each
+// test runs right away and prints TAP. When keai test loads a test file
+// with CollectTests, tests are registered instead, and the runner runs them
+// one at a time with RunTest.
+
+// TestCase is a test registered by core.test.
+type TestCase struct {
+	// Name is the name given to core.test
+	Name string
+
+	// Fn is the test's callback, which is passed the assertion function
+	Fn OBJ
+
+	// Env is the environment core.test was called in
+	Env *ENV
+
+	// Skip is true for tests registered with core.test.skip
+	Skip bool
+
+	// Only is true for tests registered with core.test.only
+	Only bool
+}
+
+// TestFile holds what a test file registered.
+type TestFile struct {
+	Tests      []*TestCase
+	BeforeEach []OBJ
+	AfterEach  []OBJ
+}
+
+// TestResult is the outcome of running a test.
+type TestResult struct {
+	// Assertions is how many assertions were made
+	Assertions int
+
+	// Failures describes each failed assertion and uncaught error
+	Failures []string
+}
+
+// testExit is what ExitConditionally panics with while a test is running.
+type testExit struct {
+	code int
+}
+
+var (
+	// collecting holds the file being loaded by CollectTests
+	collecting *TestFile
+
+	// running holds the result of the test currently running
+	running *TestResult
+
+	// direct holds the hooks registered when tests run directly
+	direct = &TestFile{}
+
+	// runningDirectly is true while a test is run by core.test itself
+	runningDirectly bool
+
+	// testsFailed is true if a test run directly has failed
+	testsFailed bool
+)
+
+// TestsFailed returns true if a test run directly, rather than by keai
+// test, has failed.
+func TestsFailed() bool {
+	return testsFailed
+}
+
+// recordAssertion records an assertion made in the running test. When
+// running directly it also prints a TAP line.
+func recordAssertion(ok bool, msg string) {
+	if running == nil {
+		return
+	}
+	running.Assertions++
+	if !ok {
+		running.Failures = append(running.Failures, msg)
+	}
+	if runningDirectly {
+		status := "ok"
+		if !ok {
+			status = "not ok"
+		}
+		fmt.Printf("%s %d - %s\n", status, running.Assertions, msg)
+	}
+}
+
+// catchExit calls f, turning a fatal error into a test failure.
+func catchExit(f func()) (exited bool, code int) {
+	prev := utils.ExitHandler
+	utils.ExitHandler = func(code int) {
+		panic(testExit{code})
+	}
+	defer func() {
+		utils.ExitHandler = prev
+		if r := recover(); r != nil {
+			e, ok := r.(testExit)
+			if !ok {
+				panic(r)
+			}
+			// a fatal error can happen halfway through an import
+			importStack = nil
+			exited, code = true, e.code
+		}
+	}()
+	f()
+	return false, 0
+}
+
+// CollectTests evaluates a test file, registering its tests without running
+// them. It returns an error if the file couldn't be evaluated.
+func CollectTests(program *ast.Program, env *ENV) (*TestFile, error) {
+	file := &TestFile{}
+	collecting = file
+	defer func() {
+		collecting = nil
+	}()
+
+	var res OBJ
+	exited, code := catchExit(func() {
+		res = Eval(program, env)
+	})
+	if exited {
+		return nil, fmt.Errorf("exited with code %d while loading", code)
+	}
+	if isError(res) {
+		return nil, fmt.Errorf("%s", res.Inspect())
+	}
+	return file, nil
+}
+
+// RunTest runs a test collected from file, along with the file's
+// before_each and after_each hooks. If ctx is cancelled, the test stops at
+// the next node it evaluates, and it's up to the caller to record why.
+func RunTest(ctx context.Context, file *TestFile, tc *TestCase) *TestResult {
+	res := &TestResult{}
+	running = res
+	prevCtx := CTX
+	SetContext(ctx)
+	defer func() {
+		running = nil
+		SetContext(prevCtx)
+	}()
+
+	call := func(what string, fn OBJ, args ...OBJ) bool {
+		var ret OBJ
+		exited, code := catchExit(func() {
+			ret = ApplyFunction(tc.Env, fn, args)
+		})
+		switch {
+		case ctx.Err() != nil:
+			// the caller knows why it cancelled the test
+			return false
+		case exited:
+			res.Failures = append(
+				res.Failures,
+				fmt.Sprintf("%s: exited with code %d", what, code),
+			)
+			return false
+		case isError(ret):
+			res.Failures = append(res.Failures, what+": "+ret.Inspect())
+			return false
+		}
+		return true
+	}
+
+	ok := true
+	for _, hook := range file.BeforeEach {
+		if ok = call("before_each", hook); !ok {
+			break
+		}
+	}
+	if ok {
+		call("test", tc.Fn, builtins["core.test.assert"])
+	}
+	// after_each hooks run even if the test failed, to clean up
+	for _, hook := range file.AfterEach {
+		call("after_each", hook)
+	}
+
+	return res
+}
+
+// runTestDirectly runs a test as soon as core.test is called.
+func runTestDirectly(env *ENV, name string, fn OBJ) OBJ {
+	fmt.Printf("# %s\n", name)
+
+	res := &TestResult{}
+	running = res
+	runningDirectly = true
+	defer func() {
+		running = nil
+		runningDirectly = false
+	}()
+
+	for _, hook := range direct.BeforeEach {
+		ApplyFunction(env, hook, nil)
+	}
+	ret := ApplyFunction(env, fn, []OBJ{builtins["core.test.assert"]})
+	if isError(ret) {
+		res.Failures = append(res.Failures, ret.Inspect())
+		fmt.Printf("not ok %d - %s\n", res.Assertions+1, ret.Inspect())
+	}
+	for _, hook := range direct.AfterEach {
+		ApplyFunction(env, hook, nil)
+	}
+
+	fmt.Printf("1..%d\n", res.Assertions)
+	if len(res.Failures) > 0 {
+		testsFailed = true
+	}
+	return NULL
+}
+
+// registerTest handles core.test and its skip and only variants.
+func registerTest(env *ENV, skip, only bool, args ...OBJ) OBJ {
+	if len(args) != 2 {
+		return NewError("core.test expects a name and a function")
+	}
+	name, ok := args[0].(*object.String)
+	if !ok {
+		return NewError("core.test expects a string name, got %s",
+			args[0].Type())
+	}
+
+	if collecting != nil {
+		collecting.Tests = append(collecting.Tests, &TestCase{
+			Name: name.Value,
+			Fn:   args[1],
+			Env:  env,
+			Skip: skip,
+			Only: only,
+		})
+		return NULL
+	}
+
+	if skip {
+		fmt.Printf("# %s\n1..0 # SKIP\n", name.Value)
+		return NULL
+	}
+	return runTestDirectly(env, name.Value, args[1])
+}
+
+// registerHook handles core.test.before_each and core.test.after_each.
+func registerHook(after bool, args ...OBJ) OBJ {
+	if len(args) != 1 {
+		return NewError("expected a function")
+	}
+
+	file := direct
+	if collecting != nil {
+		file = collecting
+	}
+	if after {
+		file.AfterEach = append(file.AfterEach, args[0])
+	} else {
+		file.BeforeEach = append(file.BeforeEach, args[0])
+	}
+	return NULL
+}
+
+// assertion passed to test callbacks: t(value, message)
+func testAssertFn(args ...OBJ) OBJ {
+	if len(args) == 0 {
+		return NewError("assertion expects a value and optional message")
+	}
+
+	msg := "Result was not 'true'!"
+	if len(args) > 1 {
+		if s, ok := args[1].(*object.String); ok {
+			msg = s.Value
+		} else {
+			msg = args[1].Inspect()
+		}
+	}
+
+	recordAssertion(isTruthy(args[0]), msg)
+	return NULL
+}
+
+func init() {
+	RegisterBuiltin("core.test",
+		"core.test(name, fn) defines a test. fn is called with an "+
+			"assertion function.",
+		func(env *ENV, args ...OBJ) OBJ {
+			return registerTest(env, false, false, args...)
+		})
+	RegisterBuiltin("core.test.skip",
+		"core.test.skip(name, fn) defines a test that doesn't run.",
+		func(env *ENV, args ...OBJ) OBJ {
+			return registerTest(env, true, false, args...)
+		})
+	RegisterBuiltin("core.test.only",
+		"core.test.only(name, fn) defines a test, and skips every test not "+
+			"defined with core.test.only.",
+		func(env *ENV, args ...OBJ) OBJ {
+			return registerTest(env, false, true, args...)
+		})
+	RegisterBuiltin("core.test.before_each",
+		"core.test.before_each(fn) calls fn before each test in the file.",
+		func(env *ENV, args ...OBJ) OBJ {
+			return registerHook(false, args...)
+		})
+	RegisterBuiltin("core.test.after_each",
+		"core.test.after_each(fn) calls fn after each test in the file.",
+		func(env *ENV, args ...OBJ) OBJ {
+			return registerHook(true, args...)
+		})
+	RegisterBuiltin("core.test.assert",
+		"core.test.assert(value, [message]) records an assertion that "+
+			"value is truthy.",
+		func(env *ENV, args ...OBJ) OBJ {
+			return testAssertFn(args...)
+		})
+}
@@ -120,26 +120,34 @@ func init() {
 	timeoutIDs = make(map[int64]bool)
 
 	RegisterBuiltin("time.sleep",
+		"time.sleep(ms) waits for a number of milliseconds.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return timeSleep(args...)
 		})
 	RegisterBuiltin("time.unix",
+		"time.unix() returns the time in milliseconds since the Unix epoch.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return timeUnix(args...)
 		})
 	RegisterBuiltin("time.utc",
+		"time.utc() returns the time as an RFC 3339 string.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return timeUtc(args...)
 		})
 	RegisterBuiltin("time.interval",
+		"time.interval(ms, fn) calls fn every ms milliseconds, and returns "+
+			"an id for time.cancel.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return timeInterval(env, args...)
 		})
 	RegisterBuiltin("time.timeout",
+		"time.timeout(ms, fn) calls fn after ms milliseconds, and returns "+
+			"an id for time.cancel.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return timeTimeout(env, args...)
 		})
 	RegisterBuiltin("time.cancel",
+		"time.cancel(id) stops an interval or timeout.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return timeCancel(args...)
 		})
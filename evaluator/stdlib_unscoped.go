@@ -37,14 +37,12 @@ func errorFn(args ...OBJ) OBJ {
 	case *object.String:
 		return &object.Error{Message: t.Value, BuiltinCall: true}
 	case *object.Hash:
-		msgStr := &object.String{Value: "message"}
-		codeStr := &object.String{Value: "code"}
-		dataStr := &object.String{Value: "data"}
-		msg := t.Pairs[msgStr.HashKey()].Value
-		code := t.Pairs[codeStr.HashKey()].Value
-		data := t.Pairs[dataStr.HashKey()].Value
+		get := func(key string) OBJ {
+			k := &object.String{Value: key}
+			return t.Pairs[k.HashKey()].Value
+		}
 		e := &object.Error{BuiltinCall: true}
-		if msg != nil {
+		if msg := get("message"); msg != nil {
 			switch m := msg.(type) {
 			case *object.String:
 				e.Message = m.Value
@@ -52,7 +50,7 @@ func errorFn(args ...OBJ) OBJ {
 				return NewError("error.message should be string!")
 			}
 		}
-		if code != nil {
+		if code := get("code"); code != nil {
 			switch c := code.(type) {
 			case *object.Integer:
 				cc := int(c.Value)
@@ -61,9 +59,23 @@ func errorFn(args ...OBJ) OBJ {
 				return NewError("error.code should be integer!")
 			}
 		}
-		if data != nil {
-			e.Data = data.JSON(false)
+		if kind := get("kind"); kind != nil {
+			switch k := kind.(type) {
+			case *object.String:
+				e.Kind = k.Value
+			default:
+				return NewError("error.kind should be string!")
+			}
+		}
+		if cause := get("cause"); cause != nil && cause != NULL {
+			switch c := cause.(type) {
+			case *object.Error:
+				e.Cause = c
+			default:
+				return NewError("error.cause should be an error!")
+			}
 		}
+		e.Data = get("data")
 		return e
 	default:
 		return NewError("error() expected a string or hash!")
@@ -101,14 +113,18 @@ func printFn(args ...OBJ) OBJ {
 
 func init() {
 	RegisterBuiltin("print",
+		"print(...) prints its arguments, separated by spaces.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return printFn(args...)
 		})
 	RegisterBuiltin("error",
+		"error(value) returns an error, from a message string or a hash "+
+			"with message, code, kind, cause (another error), and data.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return errorFn(args...)
 		})
 	RegisterBuiltin("panic",
+		"panic(error) prints an error's message and exits with its code, or 1.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return panicFn(args...)
 		})
@@ -93,6 +93,8 @@ func lenFn(args ...OBJ) OBJ {
 		return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 	case *object.Array:
 		return &object.Integer{Value: int64(len(arg.Elements))}
+	case *object.Range:
+		return &object.Integer{Value: arg.Len()}
 	case *object.Null:
 		return &object.Integer{Value: 0}
 	case *object.Hash:
@@ -119,27 +121,56 @@ func typeFn(args ...OBJ) OBJ {
 		return NewError("wrong number of arguments. got=%d, want=1",
 			len(args))
 	}
-	return &object.String{Value: strings.ToLower(string(args[0].Type()))}
+	return &object.String{Value: typeName(args[0])}
+}
+
+// typeName returns the name of a value's type, like "string". Ranges are
+// lazy arrays, so they're arrays too.
+func typeName(obj OBJ) string {
+	if obj.Type() == object.RANGE_OBJ {
+		return "array"
+	}
+	return strings.ToLower(string(obj.Type()))
+}
+
+// deep_equals(a, b) compares structurally, see object.Equal
+func deepEqualsFn(args ...OBJ) OBJ {
+	if len(args) != 2 {
+		return NewError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	return nativeBoolToBooleanObject(object.Equal(args[0], args[1]))
 }
 
 func init() {
 	RegisterBuiltin("util.int",
+		"util.int(value) converts a string, boolean, or float to an integer.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return intFn(args...)
 		})
 	RegisterBuiltin("util.float",
+		"util.float(value) converts a string, boolean, or integer to a float.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return floatFn(args...)
 		})
 	RegisterBuiltin("util.len",
+		"util.len(value) returns the length of a string, array, range, or "+
+			"hash.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return lenFn(args...)
 		})
 	RegisterBuiltin("util.string",
+		"util.string(value) returns value as a string.",
 		func(env *ENV, args ...OBJ) OBJ {
 			return strFn(args...)
 		})
+	RegisterBuiltin("util.deep_equals",
+		"util.deep_equals(a, b) compares arrays and hashes structurally.",
+		func(env *ENV, args ...OBJ) OBJ {
+			return deepEqualsFn(args...)
+		})
 	RegisterBuiltin("util.type",
+		"util.type(value) returns the name of value's type, like \"string\".",
 		func(env *ENV, args ...OBJ) OBJ {
 			return typeFn(args...)
 		})
@@ -0,0 +1,157 @@
+package evaluator
+
+import (
+	"time"
+
+	"github.com/zautumnz/keai/ast"
+	"github.com/zautumnz/keai/object"
+)
+
+// Frame is a call in progress.
+type Frame struct {
+	// Name is the name of the function, "fn" for an anonymous one, or
+	// "main" for the outermost frame
+	Name string
+
+	// File and Line are the statement the frame is running, or will run
+	// next for the innermost frame
+	File string
+	Line int
+
+	// Env is the environment the statement runs in
+	Env *ENV
+
+	// def is where the function was defined, and start and callees are
+	// when it was called and how long the calls it made took, for the
+	// profiler
+	def     position
+	start   time.Time
+	callees time.Duration
+}
+
+// position is where a statement or function is.
+type position struct {
+	file string
+	line int
+}
+
+// The debugger and profiler both need to know which statement of which
+// function the program is running. We only keep track while one of them
+// is on. It's all only touched by the program's goroutine, or while it's
+// paused.
+var (
+	tracing bool
+
+	// statements holds where every statement of the registered files is,
+	// and functions where every function in them is
+	statements map[ast.Node]position
+	functions  map[*ast.BlockStatement]position
+
+	// callStack holds the calls in progress, outermost first
+	callStack []*Frame
+)
+
+// setTracing starts or stops keeping track of the call stack.
+func setTracing() {
+	on := debugger != nil || profiler != nil
+	if on && !tracing {
+		statements = map[ast.Node]position{}
+		functions = map[*ast.BlockStatement]position{}
+		callStack = []*Frame{{Name: "main", start: time.Now()}}
+	}
+	tracing = on
+}
+
+// Register registers a parsed file, for coverage, the debugger, and the
+// profiler. It does nothing if none of them are on.
+func Register(name, src string, program *ast.Program) {
+	Cover(name, src, program)
+	if !tracing {
+		return
+	}
+
+	add := func(stmts []ast.Statement) {
+		for _, s := range stmts {
+			if b, ok := extent(s); ok {
+				statements[s] = position{name, b.startLine}
+			}
+		}
+	}
+	add(program.Statements)
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStatement:
+			add(n.Statements)
+		case *ast.FunctionLiteral:
+			functions[n.Body] = position{name, n.Token.Line}
+		}
+		return true
+	})
+}
+
+// Stack returns the calls in progress, innermost first. It's only
+// meaningful while the debugger has the program paused.
+func Stack() []Frame {
+	frames := make([]Frame, 0, len(callStack))
+	for i := len(callStack) - 1; i >= 0; i-- {
+		frames = append(frames, *callStack[i])
+	}
+	return frames
+}
+
+// Depth returns how many calls are in progress, counting the outermost
+// frame.
+func Depth() int {
+	return len(callStack)
+}
+
+// traceStatement keeps track of the statement running, if it's one from a
+// registered file, and tells the debugger about it.
+func traceStatement(node ast.Node, env *ENV) {
+	pos, ok := statements[node]
+	if !ok {
+		return
+	}
+
+	// a sample that's due belongs to the statement that was running
+	if profiler != nil {
+		profiler.maybeSample()
+	}
+	f := callStack[len(callStack)-1]
+	f.File, f.Line, f.Env = pos.file, pos.line, env
+
+	if debugger != nil {
+		debugger.Statement(pos.file, pos.line, env)
+	}
+}
+
+// traceCall pushes a frame for a function call, returning a function that
+// pops it.
+func traceCall(fn *object.Function, env *ENV) func() {
+	name := fn.Name
+	if name == "" {
+		name = "fn"
+	}
+
+	caller := callStack[len(callStack)-1]
+	f := &Frame{
+		Name: name,
+		File: caller.File,
+		Line: caller.Line,
+		Env:  env,
+		def:  functions[fn.Body],
+	}
+	if profiler != nil {
+		f.start = time.Now()
+		profiler.enter(f)
+	}
+	callStack = append(callStack, f)
+	n := len(callStack) - 1
+
+	return func() {
+		callStack = callStack[:n]
+		if profiler != nil && !f.start.IsZero() {
+			profiler.leave(f, callStack[n-1])
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/zautumnz/keai/lexer"
 	"github.com/zautumnz/keai/object"
 	"github.com/zautumnz/keai/parser"
+	"github.com/zautumnz/keai/pkgmgr"
 )
 
 var searchPaths []string
@@ -47,8 +48,18 @@ func exists(path string) bool {
 	return err == nil
 }
 
-// FindModule finds a module based on name, used by the evaluator
+// FindModule finds a module based on name, used by the evaluator.
+// When running a bundled program, the bundle is searched first.
+// The search paths are tried first, followed by the keai_modules
+// directories of the search paths and of the current project (the closest
+// directory with a keai.json). An installed package can be imported by
+// its name, which resolves to its entry point, or by its name followed by
+// the path of one of its files.
 func FindModule(name string) string {
+	if filename := findBundledModule(name); filename != "" {
+		return filename
+	}
+
 	basename := fmt.Sprintf("%s.keai", name)
 	for _, p := range searchPaths {
 		filename := filepath.Join(p, basename)
@@ -56,6 +67,34 @@ func FindModule(name string) string {
 			return filename
 		}
 	}
+
+	dirs := []string{}
+	for _, p := range searchPaths {
+		dirs = append(dirs, filepath.Join(p, pkgmgr.ModulesDir))
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		if root := pkgmgr.FindProjectRoot(cwd); root != "" {
+			dirs = append(dirs, filepath.Join(root, pkgmgr.ModulesDir))
+		}
+	}
+
+	for _, d := range dirs {
+		filename := filepath.Join(d, basename)
+		if exists(filename) {
+			return filename
+		}
+
+		pkg := filepath.Join(d, name)
+		if m, err := pkgmgr.ReadManifest(pkg); err == nil {
+			filename = filepath.Join(pkg, m.Entry())
+		} else {
+			filename = filepath.Join(pkg, "main.keai")
+		}
+		if exists(filename) {
+			return filename
+		}
+	}
+
 	return ""
 }
 
@@ -1,6 +1,8 @@
 package evaluator
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/zautumnz/keai/object"
@@ -23,6 +25,62 @@ func TestIsNumber(t *testing.T) {
 	}
 }
 
+func TestFindModuleInstalled(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"keai.json":                           `{"name": "app"}`,
+		"keai_modules/colors/keai.json":       `{"main": "lib/colors.keai"}`,
+		"keai_modules/colors/lib/colors.keai": `let red = "red"`,
+		"keai_modules/colors/extra.keai":      `let extra = true`,
+		"keai_modules/plain/main.keai":        `let plain = true`,
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// keai_modules is found from anywhere inside the project
+	sub := filepath.Join(dir, "src")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+	if err := os.Chdir(sub); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"colors", "keai_modules/colors/lib/colors.keai"},
+		{"colors/extra", "keai_modules/colors/extra.keai"},
+		{"plain", "keai_modules/plain/main.keai"},
+		{"missing", ""},
+	}
+	for _, tt := range tests {
+		expected := ""
+		if tt.expected != "" {
+			expected = filepath.Join(dir, tt.expected)
+		}
+		if got := FindModule(tt.name); got != expected {
+			t.Errorf("FindModule(%q) expected %q, got %q", tt.name, expected, got)
+		}
+	}
+}
+
 func TestInterpolate(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -0,0 +1,945 @@
+// Package format implements keai fmt, which prints a program back out in
+// the style described in the README: four spaces to indent, spaces around
+// operators, braces around every block, parens around if and for
+// conditions, and a trailing comma after each element of a multi-line
+// array, hash, or call. Comments, docstrings, and single blank lines
+// between statements are kept.
+package format
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/zautumnz/keai/ast"
+	"github.com/zautumnz/keai/lexer"
+	"github.com/zautumnz/keai/parser"
+	"github.com/zautumnz/keai/token"
+)
+
+// indentation is one level of indentation
+const indentation = "    "
+
+// closed is the precedence of an expression nothing can bind into, like a
+// literal or something ending in a bracket.
+const closed = math.MaxInt32
+
+// Source formats a program, returning an error if it doesn't parse.
+func Source(src []byte) ([]byte, error) {
+	l := lexer.New(string(src))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf(
+			"parse errors:\n\t%s",
+			strings.Join(p.Errors(), "\n\t"),
+		)
+	}
+
+	pr := &printer{
+		src:        []rune(string(src)),
+		lineStarts: []int{0},
+		comments:   l.Comments(),
+		blockStart: true,
+	}
+	for i, r := range pr.src {
+		if r == '\n' {
+			pr.lineStarts = append(pr.lineStarts, i+1)
+		}
+	}
+	pr.anchors = anchors(program, pr.comments)
+
+	pr.statements(program.Statements, 0)
+	pr.flushComments(math.MaxInt32)
+	if pr.err != nil {
+		return nil, pr.err
+	}
+	return []byte(pr.out.String()), nil
+}
+
+// printer writes a program back out.
+type printer struct {
+	src        []rune
+	lineStarts []int
+	out        strings.Builder
+	depth      int
+	err        error
+
+	// comments holds every comment in the source, and next is the first
+	// one that hasn't been printed. anchors has the end of the last token
+	// before each comment on its line, which it's printed after, or a zero
+	// pos for a comment on its own line.
+	comments []token.Token
+	anchors  []pos
+	next     int
+
+	// lastLine is the source line of the last thing printed, for keeping
+	// blank lines
+	lastLine int
+
+	// blockStart is true until something is printed in a new block, since
+	// blocks don't start with blank lines
+	blockStart bool
+}
+
+func (p *printer) write(s string) {
+	p.out.WriteString(s)
+}
+
+// indent starts a line at the current depth.
+func (p *printer) indent() {
+	p.write(strings.Repeat(indentation, p.depth))
+}
+
+// blankBefore prints a blank line if there was one before line in the
+// source.
+func (p *printer) blankBefore(line int) {
+	if !p.blockStart && p.lastLine > 0 && line > p.lastLine+1 {
+		p.write("\n")
+	}
+	p.blockStart = false
+}
+
+// flushComments prints the comments before line, each on its own line.
+func (p *printer) flushComments(line int) {
+	for p.next < len(p.comments) && p.comments[p.next].Line < line {
+		c := p.comments[p.next]
+		p.blankBefore(c.Line)
+		p.indent()
+		p.write(strings.TrimRight(c.Literal, " \t\r"))
+		p.write("\n")
+		p.lastLine = c.Line
+		p.next++
+	}
+}
+
+// trailingComments prints the comments that come after what's been
+// printed, which ends at end in the source. A comment that follows
+// something still to be printed on the same line, like the else block of
+// `if a { b } else { c } # d`, waits for it.
+func (p *printer) trailingComments(end pos) {
+	for p.next < len(p.comments) {
+		a := p.anchors[p.next]
+		if a.line == 0 || end.before(a) {
+			return
+		}
+		p.write(" ")
+		p.write(strings.TrimRight(p.comments[p.next].Literal, " \t\r"))
+		p.next++
+	}
+}
+
+// source returns a token as it was written.
+func (p *printer) source(tok token.Token) string {
+	if tok.Line == 0 || tok.EndLine > len(p.lineStarts) {
+		return tok.Literal
+	}
+	start := p.lineStarts[tok.Line-1] + tok.Column - 1
+	end := p.lineStarts[tok.EndLine-1] + tok.EndColumn - 1
+	if start < 0 || end > len(p.src) || start > end {
+		return tok.Literal
+	}
+	return string(p.src[start:end])
+}
+
+// closing are the tokens that end a node without being in ast.Inspect.
+func closing(n ast.Node) token.Token {
+	switch n := n.(type) {
+	case *ast.BlockStatement:
+		return n.Rbrace
+	case *ast.CallExpression:
+		return n.Rparen
+	case *ast.ArrayLiteral:
+		return n.Rbracket
+	case *ast.HashLiteral:
+		return n.Rbrace
+	case *ast.MatchExpression:
+		return n.Rbrace
+	}
+	return token.Token{}
+}
+
+// pos is a place in the source.
+type pos struct {
+	line, col int
+}
+
+// before returns true if a comes before b.
+func (a pos) before(b pos) bool {
+	return a.line < b.line || (a.line == b.line && a.col < b.col)
+}
+
+// endOf returns where a token ends.
+func endOf(tok token.Token) pos {
+	return pos{tok.EndLine, tok.EndColumn}
+}
+
+// tokens calls f with each token of a node that came from source.
+func tokens(node ast.Node, f func(token.Token)) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		for _, tok := range []token.Token{ast.TokenOf(n), closing(n)} {
+			if tok.Line != 0 {
+				f(tok)
+			}
+		}
+		return true
+	})
+}
+
+// span returns the first source line of a node and where it ends, or
+// zeroes if none of it came from source.
+func span(node ast.Node) (start int, end pos) {
+	tokens(node, func(tok token.Token) {
+		if start == 0 || tok.Line < start {
+			start = tok.Line
+		}
+		if end.before(endOf(tok)) {
+			end = endOf(tok)
+		}
+	})
+	return start, end
+}
+
+// anchors returns the end of the last token before each comment on its
+// line, or a zero pos if there isn't one.
+func anchors(program *ast.Program, comments []token.Token) []pos {
+	last := map[int]pos{}
+	tokens(program, func(tok token.Token) {
+		if e := endOf(tok); last[e.line].before(e) {
+			last[e.line] = e
+		}
+	})
+
+	res := make([]pos, len(comments))
+	for i, c := range comments {
+		if e, ok := last[c.Line]; ok && e.col <= c.Column {
+			res[i] = e
+		}
+	}
+	return res
+}
+
+// rootPrec is the precedence of the operator at the root of an expression,
+// if the parser had to bind it to something on its left.
+func rootPrec(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(e.Operator)
+	case *ast.AssignStatement:
+		return parser.Precedence(e.Operator)
+	case *ast.CallExpression:
+		if e.Token.Type == token.PIPE {
+			return parser.PIPE
+		}
+		return parser.CALL
+	case *ast.IndexExpression:
+		if dotted(e) {
+			return parser.CALL
+		}
+		return parser.INDEX
+	case *ast.TryExpression, *ast.SliceExpression:
+		return parser.INDEX
+	}
+	return closed
+}
+
+// openPrec is the lowest precedence an operator after an expression would
+// need to have to be bound inside it instead of to the whole thing.
+func openPrec(e ast.Expression) int {
+	right := func(prec int, r ast.Expression) int {
+		if parenRight(r, prec) {
+			return prec
+		}
+		if o := openPrec(r); o < prec {
+			return o
+		}
+		return prec
+	}
+
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return right(parser.Precedence(e.Operator), e.Right)
+	case *ast.AssignStatement, *ast.YieldExpression:
+		return parser.LOWEST
+	case *ast.PrefixExpression:
+		return right(parser.PREFIX, e.Right)
+	case *ast.SpreadLiteral:
+		return right(parser.PREFIX, e.Right)
+	case *ast.CallExpression:
+		if e.Token.Type != token.PIPE {
+			return closed
+		}
+		if bare(e) {
+			return right(parser.PIPE, e.Function)
+		}
+		return parser.PIPE
+	case *ast.FunctionLiteral:
+		if e.Token.Type != token.FUNCTION {
+			return parser.LOWEST
+		}
+	}
+	return closed
+}
+
+// dotted returns true for a.b and a?.b.
+func dotted(e *ast.IndexExpression) bool {
+	return e.Token.Type == token.PERIOD ||
+		e.Token.Type == token.QUESTION_PERIOD
+}
+
+// endsInQuestion returns true if e is printed ending in ?, which would run
+// into a . after it and make ?.
+func endsInQuestion(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.TryExpression:
+		return true
+	case *ast.Identifier:
+		return strings.HasSuffix(e.Value, "?")
+	}
+	return false
+}
+
+// bare returns true if a pipe can be written without parens after its
+// function, like `xs |> f`, instead of `xs |> f(a)`.
+func bare(pipe *ast.CallExpression) bool {
+	call, ok := pipe.Function.(*ast.CallExpression)
+	return len(pipe.Arguments) == 1 && (!ok || call.Token.Type == token.PIPE)
+}
+
+// parenRight returns true if e needs parens after an operator whose
+// operand is parsed at prec.
+func parenRight(e ast.Expression, prec int) bool {
+	return rootPrec(e) <= prec
+}
+
+// parenLeft returns true if e needs parens before an operator of prec.
+func parenLeft(e ast.Expression, prec int) bool {
+	return openPrec(e) < prec
+}
+
+// leading returns the first character a statement is printed with, to see
+// if it would continue the statement before it.
+func leading(s ast.Statement) byte {
+	es, ok := s.(*ast.ExpressionStatement)
+	if !ok {
+		return 0
+	}
+
+	e := es.Expression
+	for {
+		var left ast.Expression
+		prec := 0
+		switch n := e.(type) {
+		case *ast.InfixExpression:
+			left, prec = n.Left, parser.Precedence(n.Operator)
+		case *ast.CallExpression:
+			if n.Token.Type == token.PIPE {
+				left, prec = n.Arguments[0], parser.PIPE
+			} else {
+				left, prec = n.Function, parser.CALL
+			}
+		case *ast.IndexExpression:
+			left, prec = n.Left, rootPrec(n)
+		case *ast.TryExpression:
+			left, prec = n.Value, parser.INDEX
+		case *ast.SliceExpression:
+			left, prec = n.Left, parser.INDEX
+		case *ast.ArrayLiteral:
+			return '['
+		case *ast.PrefixExpression:
+			return n.Operator[0]
+		case *ast.FunctionLiteral:
+			return n.Token.Literal[0]
+		default:
+			return 0
+		}
+		if parenLeft(left, prec) {
+			return '('
+		}
+		e = left
+	}
+}
+
+// incrementOf returns the name in an x++ or x-- statement. The parser
+// reads those as a statement with just the name, followed by one with the
+// operator.
+func incrementOf(s ast.Statement) string {
+	if es, ok := s.(*ast.ExpressionStatement); ok {
+		if pe, ok := es.Expression.(*ast.PostfixExpression); ok {
+			return pe.Token.Literal
+		}
+	}
+	return ""
+}
+
+// statements prints a list of statements, one per line. end is the line
+// of the closing brace, if there is one, so comments before it stay in
+// the block.
+func (p *printer) statements(stmts []ast.Statement, end int) {
+	for i, s := range stmts {
+		if isNil(s) {
+			p.err = errors.New("could not parse program")
+			return
+		}
+
+		// x++ is printed with the statement after it
+		if es, ok := s.(*ast.ExpressionStatement); ok && i+1 < len(stmts) {
+			id, isIdent := es.Expression.(*ast.Identifier)
+			if isIdent && incrementOf(stmts[i+1]) == id.Value {
+				continue
+			}
+		}
+
+		start, last := span(s)
+		if start == 0 {
+			start, last = p.lastLine, pos{p.lastLine, math.MaxInt32}
+		}
+		p.flushComments(start)
+		p.blankBefore(start)
+		p.indent()
+		p.statement(s)
+
+		// without a semicolon, these would continue this statement
+		if i+1 < len(stmts) {
+			switch leading(stmts[i+1]) {
+			case '(', '[', '-', '|':
+				p.write(";")
+			}
+		}
+
+		p.trailingComments(last)
+		p.write("\n")
+		if last.line > p.lastLine {
+			p.lastLine = last.line
+		}
+	}
+
+	if end > 0 {
+		p.depth++
+		p.flushComments(end)
+		p.depth--
+	}
+}
+
+// isNil returns true for nil nodes, and for interfaces holding a nil
+// pointer, which the parser produces for some syntax errors.
+func isNil(n ast.Node) bool {
+	if n == nil {
+		return true
+	}
+	v := reflect.ValueOf(n)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// statement prints a statement, without indentation or a newline.
+func (p *printer) statement(s ast.Statement) {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		p.write("let ")
+		p.pattern(s.Name, s.Pattern)
+		p.write(" = ")
+		p.expr(s.Value)
+	case *ast.MutableStatement:
+		p.write("mutable ")
+		p.pattern(s.Name, s.Pattern)
+		p.write(" = ")
+		p.expr(s.Value)
+	case *ast.ExportStatement:
+		p.write("export ")
+		p.statement(s.Statement)
+	case *ast.ReturnStatement:
+		p.write("return")
+		if s.ReturnValue != nil {
+			p.write(" ")
+			p.expr(s.ReturnValue)
+		}
+	case *ast.ExpressionStatement:
+		p.expr(s.Expression)
+	case *ast.BlockStatement:
+		p.block(s, nil)
+	default:
+		p.err = fmt.Errorf("can't format %T", s)
+	}
+}
+
+// block prints a block in braces. before prints anything that goes at the
+// top of the block, like a docstring.
+func (p *printer) block(b *ast.BlockStatement, before func()) {
+	if b == nil {
+		p.err = errors.New("could not parse block")
+		return
+	}
+
+	end := b.Rbrace.Line
+	empty := len(b.Statements) == 0 && before == nil &&
+		(p.next >= len(p.comments) || p.comments[p.next].Line >= end)
+	if empty {
+		p.write("{}")
+		return
+	}
+
+	p.write("{\n")
+	p.depth++
+	p.blockStart = true
+	if before != nil {
+		before()
+	}
+	p.statements(b.Statements, 0)
+	p.flushComments(end)
+	p.depth--
+	p.indent()
+	p.write("}")
+	if end > p.lastLine {
+		p.lastLine = end
+	}
+}
+
+// operand prints an expression, in parens if needed.
+func (p *printer) operand(e ast.Expression, parens bool) {
+	if parens {
+		p.write("(")
+	}
+	p.expr(e)
+	if parens {
+		p.write(")")
+	}
+}
+
+// list prints the elements of an array, hash, or call between open and
+// close. If any started on a line after the opening token in the source,
+// they each go on their own line, with trailing commas.
+func (p *printer) list(
+	open, close string,
+	openTok, closeTok token.Token,
+	items []ast.Node,
+	item func(i int),
+) {
+	starts := make([]int, len(items))
+	ends := make([]pos, len(items))
+	multiline := false
+	for i, n := range items {
+		starts[i], ends[i] = span(n)
+		if openTok.Line > 0 && starts[i] > openTok.Line {
+			multiline = true
+		}
+	}
+
+	p.write(open)
+	if !multiline {
+		for i := range items {
+			if i > 0 {
+				p.write(", ")
+			}
+			item(i)
+		}
+		p.write(close)
+		return
+	}
+
+	p.write("\n")
+	p.depth++
+	p.blockStart = true
+	for i := range items {
+		if i > 0 {
+			p.trailingComments(ends[i-1])
+			p.write("\n")
+		}
+		if starts[i] > 0 {
+			p.flushComments(starts[i])
+			p.blankBefore(starts[i])
+		}
+		p.indent()
+		item(i)
+		p.write(",")
+		if ends[i].line > p.lastLine {
+			p.lastLine = ends[i].line
+		}
+	}
+	p.trailingComments(ends[len(ends)-1])
+	p.write("\n")
+	p.flushComments(closeTok.Line)
+	p.depth--
+	p.indent()
+	p.write(close)
+}
+
+// expr prints an expression.
+func (p *printer) expr(e ast.Expression) {
+	if isNil(e) {
+		p.err = errors.New("could not parse expression")
+		return
+	}
+
+	switch e := e.(type) {
+	case *ast.Identifier:
+		p.write(e.Value)
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.Boolean,
+		*ast.NullLiteral:
+		p.write(e.TokenLiteral())
+	case *ast.StringLiteral:
+		if e.Token.Type == token.STRING {
+			p.write(p.source(e.Token))
+		} else {
+			p.write(quote(e.Value))
+		}
+	case *ast.DocStringLiteral:
+		p.write(p.source(e.Token))
+	case *ast.CurrentArgsLiteral:
+		p.write("...")
+	case *ast.SpreadLiteral:
+		p.write("....")
+		p.operand(e.Right, parenRight(e.Right, parser.PREFIX))
+	case *ast.PrefixExpression:
+		p.write(e.Operator)
+		parens := parenRight(e.Right, parser.PREFIX)
+		if pe, ok := e.Right.(*ast.PrefixExpression); ok &&
+			pe.Operator == e.Operator {
+			parens = true
+		}
+		p.operand(e.Right, parens)
+	case *ast.PostfixExpression:
+		p.write(e.Token.Literal + e.Operator)
+	case *ast.YieldExpression:
+		p.write("yield ")
+		p.expr(e.Value)
+	case *ast.TryExpression:
+		// a name can end in ?, so x? would be a different name
+		_, name := e.Value.(*ast.Identifier)
+		p.operand(e.Value, name || parenLeft(e.Value, parser.INDEX))
+		p.write("?")
+	case *ast.InfixExpression:
+		prec := parser.Precedence(e.Operator)
+		p.operand(e.Left, parenLeft(e.Left, prec))
+		if e.Operator == ".." {
+			p.write("..")
+		} else {
+			p.write(" " + e.Operator + " ")
+		}
+		p.operand(e.Right, parenRight(e.Right, prec))
+	case *ast.AssignStatement:
+		if e.Target != nil {
+			p.expr(e.Target)
+			p.write(" " + e.Operator + " ")
+		} else {
+			p.write(e.Name.Value + " " + e.Operator + " ")
+		}
+		p.expr(e.Value)
+	case *ast.IndexExpression:
+		parens := parenLeft(e.Left, rootPrec(e))
+		if dotted(e) || ast.Optional(e) {
+			parens = parens || endsInQuestion(e.Left)
+		}
+		p.operand(e.Left, parens)
+		if name, ok := e.Index.(*ast.StringLiteral); ok && dotted(e) {
+			p.write(e.Token.Literal + name.Value)
+			return
+		}
+		if ast.Optional(e) {
+			p.write("?.")
+		}
+		p.write("[")
+		p.expr(e.Index)
+		p.write("]")
+	case *ast.SliceExpression:
+		parens := parenLeft(e.Left, parser.INDEX)
+		if ast.Optional(e) {
+			parens = parens || endsInQuestion(e.Left)
+			p.operand(e.Left, parens)
+			p.write("?.")
+		} else {
+			p.operand(e.Left, parens)
+		}
+		p.write("[")
+		for i, part := range []ast.Expression{e.Start, e.End, e.Step} {
+			if i == 2 && part == nil {
+				break
+			}
+			if i > 0 {
+				p.write(":")
+			}
+			if part != nil {
+				p.expr(part)
+			}
+		}
+		p.write("]")
+	case *ast.CallExpression:
+		args := e.Arguments
+		if e.Token.Type == token.PIPE {
+			p.operand(args[0], parenLeft(args[0], parser.PIPE))
+			p.pipe(args[0], e.Token)
+			if bare(e) {
+				p.operand(e.Function, parenRight(e.Function, parser.PIPE))
+				return
+			}
+			args = args[1:]
+		}
+		open := "("
+		parens := parenLeft(e.Function, parser.CALL)
+		if ast.Optional(e) {
+			open = "?.("
+			parens = parens || endsInQuestion(e.Function)
+		}
+		p.operand(e.Function, parens)
+		items := make([]ast.Node, len(args))
+		for i, a := range args {
+			items[i] = a
+		}
+		p.list(open, ")", e.Token, e.Rparen, items, func(i int) {
+			p.expr(args[i])
+		})
+	case *ast.ArrayLiteral:
+		items := make([]ast.Node, len(e.Elements))
+		for i, el := range e.Elements {
+			items[i] = el
+		}
+		p.list("[", "]", e.Token, e.Rbracket, items, func(i int) {
+			p.expr(e.Elements[i])
+		})
+	case *ast.HashLiteral:
+		p.hash(e)
+	case *ast.FunctionLiteral:
+		p.function(e)
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expr(e.Condition)
+		p.write(") ")
+		p.block(e.Consequence, nil)
+		if e.Alternative == nil {
+			return
+		}
+		p.write(" else ")
+		alt := e.Alternative
+		if alt.Token.Type == "" && len(alt.Statements) == 1 {
+			if es, ok := alt.Statements[0].(*ast.ExpressionStatement); ok {
+				if ie, ok := es.Expression.(*ast.IfExpression); ok {
+					p.expr(ie)
+					return
+				}
+			}
+		}
+		p.block(alt, nil)
+	case *ast.MatchExpression:
+		p.match(e)
+	case *ast.ForLoopExpression:
+		p.write("for (")
+		p.expr(e.Condition)
+		p.write(") ")
+		p.block(e.Consequence, nil)
+	case *ast.ForeachStatement:
+		p.write("foreach ")
+		if e.Index != "" {
+			p.write(e.Index + ", ")
+		}
+		if e.Pattern != nil {
+			p.pattern(nil, e.Pattern)
+		} else {
+			p.write(e.Ident)
+		}
+		p.write(" in ")
+		p.expr(e.Value)
+		p.write(" ")
+		p.block(e.Body, nil)
+	case *ast.ImportExpression:
+		p.write("import(")
+		p.expr(e.Name)
+		p.write(")")
+	default:
+		p.err = fmt.Errorf("can't format %T", e)
+	}
+}
+
+// match prints a match expression, with each arm on its own line and
+// followed by a comma.
+func (p *printer) match(m *ast.MatchExpression) {
+	p.write("match ")
+	p.expr(m.Value)
+	p.write(" {\n")
+	p.depth++
+	for _, arm := range m.Arms {
+		start, _ := span(arm.Pattern)
+		_, last := span(arm.Body)
+		p.flushComments(start)
+		p.indent()
+		p.pattern(nil, arm.Pattern)
+		if arm.Guard != nil {
+			p.write(" if ")
+			p.expr(arm.Guard)
+		}
+		p.write(" => ")
+		body := arm.Body
+		if body.Token.Type != token.LBRACE && len(body.Statements) == 1 {
+			p.statement(body.Statements[0])
+		} else {
+			p.block(body, nil)
+		}
+		// without commas, an arm starting with [ would index the one
+		// before it
+		p.write(",")
+		p.trailingComments(last)
+		p.write("\n")
+		if last.line > p.lastLine {
+			p.lastLine = last.line
+		}
+	}
+	p.flushComments(m.Rbrace.Line)
+	p.depth--
+	p.indent()
+	p.write("}")
+}
+
+// pattern prints what a let, mutable, foreach, or parameter binds: a
+// name, or a pattern if there is one.
+func (p *printer) pattern(name *ast.Identifier, pattern ast.Expression) {
+	var elements []*ast.PatternElement
+	var rest *ast.Identifier
+	switch pat := pattern.(type) {
+	case *ast.ArrayPattern:
+		p.write("[")
+		elements, rest = pat.Elements, pat.Rest
+	case *ast.HashPattern:
+		p.write("{")
+		elements = pat.Pairs
+	case nil:
+		p.write(name.Value)
+		return
+	default:
+		// a name, or in a match, a literal or range
+		p.expr(pattern)
+		return
+	}
+
+	for i, e := range elements {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.write(e.KeyPrefix())
+		p.pattern(nil, e.Target)
+		if e.Default != nil {
+			p.write(" = ")
+			p.expr(e.Default)
+		}
+	}
+	if rest != nil {
+		if len(elements) > 0 {
+			p.write(", ")
+		}
+		p.write("..." + rest.Value)
+	}
+
+	if _, ok := pattern.(*ast.ArrayPattern); ok {
+		p.write("]")
+	} else {
+		p.write("}")
+	}
+}
+
+// hash prints a hash, with its pairs in the order they were written.
+func (p *printer) hash(h *ast.HashLiteral) {
+	keys := make([]ast.Expression, 0, len(h.Pairs))
+	for k := range h.Pairs {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := ast.TokenOf(keys[i]), ast.TokenOf(keys[j])
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	items := make([]ast.Node, len(keys))
+	for i, k := range keys {
+		items[i] = k
+	}
+	p.list("{", "}", h.Token, h.Rbrace, items, func(i int) {
+		p.expr(keys[i])
+		p.write(": ")
+		p.expr(h.Pairs[keys[i]])
+	})
+}
+
+// function prints a function literal, with its docstring at the top of
+// its body.
+func (p *printer) function(f *ast.FunctionLiteral) {
+	if f.Token.Type != token.FUNCTION {
+		p.shortFunction(f)
+		return
+	}
+	p.write("fn (")
+	for i, param := range f.Parameters {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.pattern(param, f.Patterns[param.Value])
+		if d, ok := f.Defaults[param.Value]; ok {
+			p.write(" = ")
+			p.expr(d)
+		}
+	}
+	p.write(") ")
+
+	var doc func()
+	if f.DocString != nil {
+		doc = func() {
+			line := f.DocString.Token.Line
+			p.flushComments(line)
+			p.indent()
+			p.expr(f.DocString)
+			p.trailingComments(endOf(f.DocString.Token))
+			p.write("\n")
+			p.lastLine = f.DocString.Token.EndLine
+		}
+	}
+	p.block(f.Body, doc)
+}
+
+// pipe prints a |> after left, starting a new line if it did in the
+// source, so long pipelines keep one step per line.
+func (p *printer) pipe(left ast.Expression, tok token.Token) {
+	_, end := span(left)
+	if end.line == 0 || tok.Line <= end.line {
+		p.write(" |> ")
+		return
+	}
+	p.trailingComments(end)
+	p.write("\n")
+	p.depth++
+	p.flushComments(tok.Line)
+	p.indent()
+	p.depth--
+	p.write("|> ")
+}
+
+// shortFunction prints a function written like `x => x * 2` or
+// `|a, b| a + b`, whose body is one expression.
+func (p *printer) shortFunction(f *ast.FunctionLiteral) {
+	if f.Token.Type == token.ARROW {
+		p.write(f.Parameters[0].Value + " => ")
+	} else {
+		p.write("|")
+		for i, param := range f.Parameters {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.write(param.Value)
+		}
+		p.write("| ")
+	}
+	p.expr(f.Body.Statements[0].(*ast.ExpressionStatement).Expression)
+}
+
+// quote writes a string that has no source, escaping what the lexer
+// unescapes.
+func quote(s string) string {
+	r := strings.NewReplacer(
+		"\\", "\\\\",
+		"\"", "\\\"",
+		"\n", "\\n",
+		"\r", "\\r",
+		"\t", "\\t",
+	)
+	return "\"" + r.Replace(s) + "\""
+}
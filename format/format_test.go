@@ -0,0 +1,304 @@
+package format
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/zautumnz/keai/lexer"
+	"github.com/zautumnz/keai/parser"
+	"github.com/zautumnz/keai/token"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let  x=1+2*3;let y = (1 + 2) * 3",
+			"let x = 1 + 2 * 3\nlet y = (1 + 2) * 3\n",
+		},
+		{
+			"if x { print(1) } else if (y) print(2) else { print(3) }",
+			`if (x) {
+    print(1)
+} else if (y) {
+    print(2)
+} else {
+    print(3)
+}
+`,
+		},
+		{
+			`let f = fn(a,b=2){'adds'
+a+b}`,
+			`let f = fn (a, b = 2) {
+    'adds'
+    a + b
+}
+`,
+		},
+		{
+			`let h = {"a": 1,
+"b": [1,
+2]}`,
+			`let h = {
+    "a": 1,
+    "b": [
+        1,
+        2,
+    ],
+}
+`,
+		},
+		{
+			"# leading\n\n\nlet x = 1 # trailing\n\nfoo(fn () {\n# inside\n\n})\n",
+			"# leading\n\nlet x = 1 # trailing\n\nfoo(fn () {\n    # inside\n})\n",
+		},
+		{
+			"mutable i = 0\ni++\nfoo;(bar)\nx;-1\n",
+			"mutable i = 0\ni++\nfoo\nbar\nx;\n-1\n",
+		},
+		{
+			`foreach i, x in xs print("\t{{x}}")`,
+			`foreach i, x in xs {
+    print("\t{{x}}")
+}
+`,
+		},
+		{
+			`let [a,b=1,...c]=xs;let {name,age:years,"x-y":z}=h
+foreach i,[k,v] in xs k
+let f=fn([a],{b}={}){a}`,
+			`let [a, b = 1, ...c] = xs
+let {name, age: years, "x-y": z} = h
+foreach i, [k, v] in xs {
+    k
+}
+let f = fn ([a], {b} = {}) {
+    a
+}
+`,
+		},
+		{
+			`match x {0=>"zero",[a,...b] if a>1=>{a}
+# anything
+_=>null}`,
+			`match x {
+    0 => "zero",
+    [a, ...b] if a > 1 => {
+        a
+    },
+    # anything
+    _ => null,
+}
+`,
+		},
+		{
+			"let g=fn(){yield 1+2;let x=(yield 1)+2}",
+			"let g = fn () {\n    yield 1 + 2\n    let x = (yield 1) + 2\n}\n",
+		},
+		{
+			"let f=x=>x*2;let g=|a,b|a+b;let k=||1;(x=>x)(1);|x|x",
+			"let f = x => x * 2\nlet g = |a, b| a + b\nlet k = || 1;\n" +
+				"(x => x)(1);\n|x| x\n",
+		},
+		{
+			"let r=xs|>f|>g(1) # c\n  |>(a|>h)\nlet s=(xs|>f)+1",
+			"let r = xs |> f |> g(1) # c\n    |> (a |> h)\n" +
+				"let s = (xs |> f) + 1\n",
+		},
+		{
+			"a?.b?.[0]?.(1)?.c;xs?.[1:];(ok?).x;(ok?)?.x;(f()?)?.y;a??b??c",
+			"a?.b?.[0]?.(1)?.c\nxs?.[1:]\n(ok?).x\n(ok?)?.x\n(f()?)?.y\n" +
+				"a ?? b ?? c\n",
+		},
+		{
+			"xs[1 : -1];s[::2];(a+b)[i:]",
+			"xs[1:-1]\ns[::2];\n(a + b)[i:]\n",
+		},
+		{
+			"let a=f(x)?;let b=-(c ?).d()?",
+			"let a = f(x)?\nlet b = -((c)?).d()?\n",
+		},
+		{
+			"xs[i+1]=2;h.a.b+=1",
+			"xs[i + 1] = 2\nh.a.b += 1\n",
+		},
+		// comments stay after the last thing on their line
+		{
+			"if a > b { return a } else { b } # after if",
+			`if (a > b) {
+    return a
+} else {
+    b
+} # after if
+`,
+		},
+		{
+			"let m = match x { 1 => 2, _ => 3 } # match\n" +
+				"let y = [1, # one\n2] # two",
+			`let m = match x {
+    1 => 2,
+    _ => 3,
+} # match
+let y = [
+    1, # one
+    2,
+] # two
+`,
+		},
+		{
+			"util.assert(!util.deep_equals([1, 2],\n  [1, 3]), \"msg\")",
+			`util.assert(
+    !util.deep_equals(
+        [1, 2],
+        [1, 3],
+    ),
+    "msg",
+)
+`,
+		},
+		{
+			"a - (b - c); -(-x); (a + b).c(); a..b.c; (-a).b",
+			"a - (b - c);\n-(-x);\n(a + b).c()\na..b.c;\n(-a).b\n",
+		},
+	}
+
+	for _, tt := range tests {
+		out, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Fatalf("error formatting %q: %s", tt.input, err)
+		}
+		if string(out) != tt.expected {
+			t.Errorf(
+				"wrong output for %q. expected=\n%s\ngot=\n%s",
+				tt.input,
+				tt.expected,
+				out,
+			)
+		}
+	}
+
+	if _, err := Source([]byte("let = 1")); err == nil {
+		t.Errorf("expected an error for a program that doesn't parse")
+	}
+}
+
+// dump prints a tree without positions, with hash pairs sorted, so two
+// programs can be compared.
+func dump(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return "nil"
+		}
+		return dump(v.Elem())
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(token.Token{}) {
+			return ""
+		}
+		fields := []string{}
+		for i := 0; i < v.NumField(); i++ {
+			fields = append(fields, dump(v.Field(i)))
+		}
+		return v.Type().Name() + "{" + strings.Join(fields, " ") + "}"
+	case reflect.Slice:
+		items := []string{}
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, dump(v.Index(i)))
+		}
+		return "[" + strings.Join(items, " ") + "]"
+	case reflect.Map:
+		items := []string{}
+		for _, k := range v.MapKeys() {
+			items = append(items, dump(k)+":"+dump(v.MapIndex(k)))
+		}
+		sort.Strings(items)
+		return "map[" + strings.Join(items, " ") + "]"
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+func parse(t *testing.T, src []byte) (string, []string) {
+	l := lexer.New(string(src))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	comments := []string{}
+	for _, c := range l.Comments() {
+		comments = append(comments, strings.TrimSpace(c.Literal))
+	}
+	return dump(reflect.ValueOf(program)), comments
+}
+
+// Formatting the stdlib and examples mustn't change what they do, lose
+// any comments, or be different the second time.
+func TestSourceKeepsMeaning(t *testing.T) {
+	files, _ := filepath.Glob("../stdlib/*.keai")
+	examples, _ := filepath.Glob("../examples/*.keai")
+	files = append(files, examples...)
+	if len(files) == 0 {
+		t.Fatal("no files found")
+	}
+
+	for _, f := range files {
+		src, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := Source(src)
+		if err != nil {
+			t.Errorf("%s: %s", f, err)
+			continue
+		}
+
+		before, beforeComments := parse(t, src)
+		after, afterComments := parse(t, out)
+		if before != after {
+			t.Errorf("%s: formatting changed the program", f)
+		}
+		if !reflect.DeepEqual(beforeComments, afterComments) {
+			t.Errorf("%s: formatting changed the comments", f)
+		}
+
+		again, err := Source(out)
+		if err != nil || string(again) != string(out) {
+			t.Errorf("%s: formatting isn't idempotent", f)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	after := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\n"
+	expected := `--- x.keai
++++ x.keai
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -11,3 +11,4 @@
+ k
+ l
+ m
++n
+`
+	if got := Diff("x.keai", []byte(before), []byte(after)); got != expected {
+		t.Errorf("wrong diff. expected=\n%s\ngot=\n%s", expected, got)
+	}
+	if got := Diff("x.keai", []byte(before), []byte(before)); got != "" {
+		t.Errorf("expected no diff, got=\n%s", got)
+	}
+}
@@ -5,18 +5,16 @@ package main
 
 import (
 	"embed"
-	"flag"
 	"fmt"
 	"io/fs"
-	"io/ioutil"
 	"os"
 	"strings"
 
+	"github.com/zautumnz/keai/bundle"
 	"github.com/zautumnz/keai/evaluator"
 	"github.com/zautumnz/keai/lexer"
 	"github.com/zautumnz/keai/object"
 	"github.com/zautumnz/keai/parser"
-	"github.com/zautumnz/keai/repl"
 	"github.com/zautumnz/keai/utils"
 )
 
@@ -26,9 +24,9 @@ var KEAI_VERSION = "keai-version"
 //go:embed stdlib
 var stdlibFs embed.FS
 
-// turn the embed fs into a string we can use
-func getStdlibString() string {
-	s := ""
+// read every file in the embed fs
+func getStdlibFiles() []evaluator.SourceFile {
+	files := []evaluator.SourceFile{}
 	fs.WalkDir(
 		stdlibFs,
 		".",
@@ -37,17 +35,29 @@ func getStdlibString() string {
 				return err
 			}
 			if strings.HasSuffix(path, ".keai") {
-				c, err := ioutil.ReadFile(path)
+				c, err := stdlibFs.ReadFile(path)
 				if err != nil {
 					return err
 				}
-				s += string(c)
-				s += "\n"
+				files = append(files, evaluator.SourceFile{
+					Name:   path,
+					Source: string(c),
+				})
 			}
 
 			return nil
 		})
 
+	return files
+}
+
+// turn the embed fs into a string we can use
+func getStdlibString() string {
+	s := ""
+	for _, f := range getStdlibFiles() {
+		s += f.Source
+		s += "\n"
+	}
 	return s
 }
 
@@ -56,9 +66,19 @@ func versionFn(args ...object.Object) object.Object {
 	return &object.String{Value: KEAI_VERSION}
 }
 
-// Execute the supplied string as a program.
-func Execute(input string) int {
-	env := object.NewEnvironment()
+// Register version() with the other builtins, so it's in the docs and
+// known to keai check.
+func init() {
+	evaluator.RegisterBuiltin("version",
+		"version() returns the version of keai.",
+		func(env *object.Environment, args ...object.Object) object.Object {
+			return versionFn(args...)
+		})
+}
+
+// Execute the supplied string as a program. The name is the file it came
+// from, for coverage.
+func Execute(name, input string) int {
 	l := lexer.New(input)
 	p := parser.New(l)
 
@@ -66,68 +86,71 @@ func Execute(input string) int {
 	if len(p.Errors()) != 0 {
 		parser.PrintParserErrors(parser.ParserErrorsParams{Errors: p.Errors()})
 	}
-
-	// Register a function called version()
-	// that the script can call.
-	evaluator.RegisterBuiltin("version",
-		func(env *object.Environment, args ...object.Object) object.Object {
-			return versionFn(args...)
-		})
+	evaluator.Register(name, input, program)
 
 	//  Parse and evaluate our standard-library.
-	initL := lexer.New(getStdlibString())
-	initP := parser.New(initL)
-	initProg := initP.ParseProgram()
-	evaluator.Eval(initProg, env)
+	env := evaluator.NewStdlibEnvironment()
 
 	//  Now evaluate the code the user wanted to load.
 	//  Note that here our environment will still contain
 	// the code we just loaded from our data-resource
 	//  (i.e. Our keai-based standard library.)
-	evaluator.Eval(program, env)
+	res := evaluator.Eval(program, env)
+	if evaluator.ImportFailed(res) {
+		fmt.Fprintln(os.Stderr, res.(*object.Error).Message)
+		return 1
+	}
+
+	// Tests run with core.test set the exit code.
+	if evaluator.TestsFailed() {
+		return 1
+	}
 	return 0
 }
 
-func main() {
-	// Setup some flags.
-	evalDesc := "Code to execute"
-	eval := flag.String("eval", "", evalDesc)
-	flag.StringVar(eval, "e", "", evalDesc)
-	versDesc := "Show our version and exit"
-	vers := flag.Bool("version", false, versDesc)
-	flag.BoolVar(vers, "v", false, versDesc)
-
-	// Parse the flags
-	flag.Parse()
-
-	// Showing the version?
-	if *vers {
-		fmt.Printf("keai %s\n", KEAI_VERSION)
-		utils.ExitConditionally(0)
+// Run the program bundled into our own executable, if there is one,
+// returning its exit code.
+func runBundle() (int, bool) {
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, false
 	}
-
-	// Executing code?
-	if *eval != "" {
-		Execute(*eval)
-		utils.ExitConditionally(0)
+	b, err := bundle.Open(exe)
+	if err != nil {
+		fmt.Printf("Error reading bundle: %s\n", err.Error())
+		utils.ExitConditionally(1)
+	}
+	if b == nil {
+		return 0, false
 	}
 
-	// Otherwise we're either reading from STDIN, or the
-	// named file containing source-code.
-	var input []byte
-	var err error
-
-	if len(flag.Args()) > 0 {
-		input, err = ioutil.ReadFile(os.Args[1])
-	} else {
-		fmt.Printf("keai version %s\n", KEAI_VERSION)
-		fmt.Println("Use ctrl+d to quit")
-		repl.Start(os.Stdin, os.Stdout, getStdlibString())
+	evaluator.SetBundle(b.FS)
+	evaluator.SetStdlib(getStdlibFiles()...)
+	evaluator.SetArgs(os.Args)
+	return Execute("main.keai", string(b.Main)), true
+}
+
+func main() {
+	// Built with `keai build`? Then all arguments belong to the program.
+	if code, ok := runBundle(); ok {
+		utils.ExitConditionally(code)
+		return
 	}
 
-	if err != nil {
-		fmt.Printf("Error reading: %s\n", err.Error())
+	// Make the stdlib available to imported modules.
+	evaluator.SetStdlib(getStdlibFiles()...)
+
+	args := os.Args[1:]
+	if len(args) == 0 {
+		utils.ExitConditionally(replCmd(args))
+		return
+	}
+	if cmd := findCommand(args[0]); cmd != nil {
+		utils.ExitConditionally(cmd.run(args[1:]))
+		return
 	}
 
-	Execute(string(input))
+	// Anything else is a program to run, which keeps `keai app.keai` and
+	// shebang lines working.
+	utils.ExitConditionally(runCmd(args))
 }
@@ -3,6 +3,7 @@
 package lexer
 
 import (
+	"sort"
 	"strings"
 	"unicode"
 
@@ -25,6 +26,12 @@ type Lexer struct {
 
 	// Previous token.
 	prevToken token.Token
+
+	// The position each line starts at
+	lineStarts []int
+
+	// Comments skipped so far, for tools like keai fmt
+	comments []token.Token
 }
 
 // New a Lexer instance from string input.
@@ -34,11 +41,36 @@ func New(inputs ...string) *Lexer {
 		input += inp
 		input += "\n\n"
 	}
-	l := &Lexer{characters: []rune(input)}
+	l := &Lexer{characters: []rune(input), lineStarts: []int{0}}
+	for i, ch := range l.characters {
+		if ch == '\n' {
+			l.lineStarts = append(l.lineStarts, i+1)
+		}
+	}
 	l.readChar()
 	return l
 }
 
+// Position returns the line and column of a character position, counting
+// from 1.
+func (l *Lexer) Position(pos int) (int, int) {
+	line := sort.SearchInts(l.lineStarts, pos+1) - 1
+	return line + 1, pos - l.lineStarts[line] + 1
+}
+
+// setPosition records where a token started, and that it ends where the
+// lexer is now.
+func (l *Lexer) setPosition(tok *token.Token, start int) {
+	tok.Line, tok.Column = l.Position(start)
+	tok.EndLine, tok.EndColumn = l.Position(l.position)
+}
+
+// Comments returns the comments the lexer has skipped so far, in order. The
+// literal of each includes the leading #.
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
+}
+
 // GetLine returns the rough line-number of our current position.
 func (l *Lexer) GetLine() int {
 	line := 0
@@ -78,6 +110,8 @@ func (l *Lexer) NextToken() token.Token {
 		return l.NextToken()
 	}
 
+	start := l.position
+
 	switch l.ch {
 	case rune('&'):
 		if l.peekChar() == rune('&') {
@@ -101,6 +135,9 @@ func (l *Lexer) NextToken() token.Token {
 				Type:    token.OR,
 				Literal: string(ch) + string(l.ch),
 			}
+		} else if l.peekChar() == rune('>') {
+			l.readChar()
+			tok = token.Token{Type: token.PIPE, Literal: "|>"}
 		} else {
 			tok = token.Token{
 				Type:    token.BIT_OR,
@@ -125,13 +162,32 @@ func (l *Lexer) NextToken() token.Token {
 				Type:    token.EQ,
 				Literal: string(ch) + string(l.ch),
 			}
+		} else if l.peekChar() == rune('>') {
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: "=>"}
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
 	case rune(';'):
 		tok = newToken(token.SEMICOLON, l.ch)
 	case rune('?'):
-		tok = newToken(token.QUESTION, l.ch)
+		if l.peekChar() == rune('?') {
+			l.readChar()
+			tok = token.Token{Type: token.QUESTION_QUESTION, Literal: "??"}
+		} else if l.peekChar() == rune('.') {
+			l.readChar()
+			tok = token.Token{Type: token.QUESTION_PERIOD, Literal: "?."}
+			switch l.peekChar() {
+			case rune('['):
+				l.readChar()
+				tok = token.Token{Type: token.QUESTION_LBRACKET, Literal: "?.["}
+			case rune('('):
+				l.readChar()
+				tok = token.Token{Type: token.QUESTION_LPAREN, Literal: "?.("}
+			}
+		} else {
+			tok = newToken(token.QUESTION, l.ch)
+		}
 	case rune('('):
 		tok = newToken(token.LPAREN, l.ch)
 	case rune(')'):
@@ -311,18 +367,21 @@ func (l *Lexer) NextToken() token.Token {
 	default:
 		if isDigit(l.ch) {
 			tok = l.readDecimal()
+			l.setPosition(&tok, start)
 			l.prevToken = tok
 			return tok
 
 		}
 		tok.Literal = l.readIdentifier()
 		tok.Type = token.LookupIdentifier(tok.Literal)
+		l.setPosition(&tok, start)
 		l.prevToken = tok
 
 		return tok
 	}
 
 	l.readChar()
+	l.setPosition(&tok, start)
 	l.prevToken = tok
 	return tok
 }
@@ -332,94 +391,20 @@ func newToken(tokenType token.Type, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
-// readIdentifier is designed to read an identifier (name of variable,
-// function, etc).
-//
-// However there is a complication due to our historical implementation
-// of the standard library. We really want to stop identifiers if we hit
-// a period, to allow method-calls to work on objects.
-//
-// So with input like this:
-//
-//	a.blah();
-//
-// Our identifier should be "a" (then we have a period, then a second
-// identifier "blah", followed by opening & closing parenthesis).
-//
-// However we also have to cover the case of:
-//
-//	string.toupper("blah");
-//	os.getenv("PATH");
-//	etc.
+// readIdentifier reads an identifier (name of variable, function, etc).
+// Periods aren't part of one, so `fs.open` and `sys.STDOUT.write` are
+// member accesses, however deep they go.
 func (l *Lexer) readIdentifier() string {
-	// Types and objects which will have valid methods.
-	types := []string{
-		"array.",
-		"core.",
-		"float.",
-		"fs.",
-		"hash.",
-		"http.",
-		"integer.",
-		"json.",
-		"math.",
-		"net.",
-		"object.",
-		"string.",
-		"sys.",
-		"time.",
-		"util.",
-	}
-
 	id := ""
-
-	// Save our position, in case we need to jump backwards in
-	// our scanning.
-	position := l.position
-	rposition := l.readPosition
-
-	// Build up our identifier, handling only valid characters.
-	// NOTE: This WILL consider the period valid, allowing the
-	// parsing of "foo.bar", "os.getenv", "blah.blah.blah", etc.
 	for isIdentifier(l.ch) {
+		// a?.b and a ?? b aren't part of the name
+		if l.ch == rune('?') &&
+			(l.peekChar() == rune('.') || l.peekChar() == rune('?')) {
+			break
+		}
 		id += string(l.ch)
 		l.readChar()
 	}
-
-	// Now we to see if our identifier had a period inside it.
-	if strings.Contains(id, ".") {
-		ok := false
-		for _, i := range types {
-			if strings.HasPrefix(id, i) {
-				ok = true
-			}
-		}
-
-		// Not permitted? Then we abort.
-		// We reset our lexer-state to the position just ahead
-		// of the period. This will then lead to a syntax
-		// error.
-		// Which probably means our lexer should abort instead.
-		// For the moment we'll leave as-is.
-		if !ok {
-			// OK first of all we truncate our identifier
-			// at the position before the "."
-			offset := strings.Index(id, ".")
-			id = id[:offset]
-
-			// Now we have to move backwards - as a quickie
-			// We'll reset our position and move forwards
-			// the length of the bits we went too-far.
-			l.position = position
-			l.readPosition = rposition
-			for offset > 0 {
-				l.readChar()
-				offset--
-			}
-		}
-	}
-
-	// And now our pain is over.
 	return id
 }
 
@@ -430,11 +415,18 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-// skip comment (until the end of the line).
+// skip comment (until the end of the line), remembering it.
 func (l *Lexer) skipComment() {
+	start := l.position
 	for l.ch != '\n' && l.ch != rune(0) {
 		l.readChar()
 	}
+	tok := token.Token{
+		Type:    token.COMMENT,
+		Literal: string(l.characters[start:l.position]),
+	}
+	l.setPosition(&tok, start)
+	l.comments = append(l.comments, tok)
 	l.skipWhitespace()
 }
 
@@ -541,7 +533,6 @@ func (l *Lexer) peekChar() rune {
 func isIdentifier(ch rune) bool {
 	return unicode.IsLetter(ch) ||
 		unicode.IsDigit(ch) ||
-		ch == '.' ||
 		ch == '?' ||
 		ch == '$' ||
 		ch == '_'
@@ -41,7 +41,7 @@ func TestNull(t *testing.T) {
 }
 
 func TestNextToken1(t *testing.T) {
-	input := "%=+(){},;?|| &&++--***=..>>"
+	input := "%=+(){},;?|| &&++--***=..>>|>|=>??a?.[?.("
 
 	tests := []struct {
 		expectedType    token.Type
@@ -65,6 +65,13 @@ func TestNextToken1(t *testing.T) {
 		{token.ASTERISK_EQUALS, "*="},
 		{token.RANGE, ".."},
 		{token.BIT_RIGHT_SHIFT, ">>"},
+		{token.PIPE, "|>"},
+		{token.BIT_OR, "|"},
+		{token.ARROW, "=>"},
+		{token.QUESTION_QUESTION, "??"},
+		{token.IDENT, "a"},
+		{token.QUESTION_LBRACKET, "?.["},
+		{token.QUESTION_LPAREN, "?.("},
 		{token.EOF, ""},
 	}
 	l := New(input)
@@ -448,22 +455,13 @@ let c = 3.113;
 	}
 }
 
-// TestStdLib ensures that identifiers are parsed correctly
+// TestStdLib ensures that namespaced names are lexed as member accesses,
+// however deep they go
 func TestStdLib(t *testing.T) {
 	input := `
 sys.getenv
-sys.setenv
-sys.environment
-fs.glob
-math.abs
-math.random
-math.sqrt
-string.interpolate
 string.toupper
-string.tolower
-string.trim
-string.reverse
-string.split
+sys.STDOUT.write
 foo.bar
 `
 
@@ -471,19 +469,17 @@ foo.bar
 		expectedType    token.Type
 		expectedLiteral string
 	}{
-		{token.IDENT, "sys.getenv"},
-		{token.IDENT, "sys.setenv"},
-		{token.IDENT, "sys.environment"},
-		{token.IDENT, "fs.glob"},
-		{token.IDENT, "math.abs"},
-		{token.IDENT, "math.random"},
-		{token.IDENT, "math.sqrt"},
-		{token.IDENT, "string.interpolate"},
-		{token.IDENT, "string.toupper"},
-		{token.IDENT, "string.tolower"},
-		{token.IDENT, "string.trim"},
-		{token.IDENT, "string.reverse"},
-		{token.IDENT, "string.split"},
+		{token.IDENT, "sys"},
+		{token.PERIOD, "."},
+		{token.IDENT, "getenv"},
+		{token.IDENT, "string"},
+		{token.PERIOD, "."},
+		{token.IDENT, "toupper"},
+		{token.IDENT, "sys"},
+		{token.PERIOD, "."},
+		{token.IDENT, "STDOUT"},
+		{token.PERIOD, "."},
+		{token.IDENT, "write"},
 		{token.IDENT, "foo"},
 		{token.PERIOD, "."},
 		{token.IDENT, "bar"},
@@ -529,8 +525,8 @@ a?.b?();
 		{token.LPAREN, "("},
 		{token.RPAREN, ")"},
 		{token.SEMICOLON, ";"},
-		{token.IDENT, "a?"},
-		{token.PERIOD, "."},
+		{token.IDENT, "a"},
+		{token.QUESTION_PERIOD, "?."},
 		{token.IDENT, "b?"},
 		{token.LPAREN, "("},
 		{token.RPAREN, ")"},
@@ -690,3 +686,77 @@ func TestRangeDots(t *testing.T) {
 		}
 	}
 }
+
+func TestPositions(t *testing.T) {
+	input := "let abc = 1.5 # comment\n  foo(\"hé\")\n"
+	tests := []struct {
+		expectedLiteral string
+		line, col       int
+		endLine, endCol int
+	}{
+		{"let", 1, 1, 1, 4},
+		{"abc", 1, 5, 1, 8},
+		{"=", 1, 9, 1, 10},
+		{"1.5", 1, 11, 1, 14},
+		{"foo", 2, 3, 2, 6},
+		{"(", 2, 6, 2, 7},
+		{"hé", 2, 7, 2, 11},
+		{")", 2, 11, 2, 12},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf(
+				"tests[%d] - Literal wrong, expected=%q, got=%q",
+				i,
+				tt.expectedLiteral,
+				tok.Literal,
+			)
+		}
+		if tok.Line != tt.line || tok.Column != tt.col ||
+			tok.EndLine != tt.endLine || tok.EndColumn != tt.endCol {
+			t.Fatalf(
+				"tests[%d] - position wrong, expected=%d.%d-%d.%d, got=%d.%d-%d.%d",
+				i,
+				tt.line,
+				tt.col,
+				tt.endLine,
+				tt.endCol,
+				tok.Line,
+				tok.Column,
+				tok.EndLine,
+				tok.EndColumn,
+			)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "#!/bin/keai\nlet x = 1 # one\n# two\n"
+	l := New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+	}
+
+	comments := l.Comments()
+	expected := []struct {
+		literal string
+		line    int
+		col     int
+	}{
+		{"#!/bin/keai", 1, 1},
+		{"# one", 2, 11},
+		{"# two", 3, 1},
+	}
+	if len(comments) != len(expected) {
+		t.Fatalf("wrong number of comments. got=%d", len(comments))
+	}
+	for i, c := range comments {
+		e := expected[i]
+		if c.Type != token.COMMENT || c.Literal != e.literal ||
+			c.Line != e.line || c.Column != e.col {
+			t.Errorf("comments[%d] wrong. expected=%q at %d.%d, got=%q at %d.%d",
+				i, e.literal, e.line, e.col, c.Literal, c.Line, c.Column)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package lsp
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/zautumnz/keai/ast"
+	"github.com/zautumnz/keai/check"
+	"github.com/zautumnz/keai/lexer"
+	"github.com/zautumnz/keai/parser"
+	"github.com/zautumnz/keai/token"
+)
+
+// document is an open file, or a module one refers to.
+type document struct {
+	uri  string
+	path string
+	text string
+
+	// lines holds the text split on newlines, for converting positions
+	lines []string
+
+	tokens []token.Token
+
+	// program is nil if the text doesn't parse, in which case errors
+	// holds the parse errors
+	program *ast.Program
+	errors  []string
+
+	// defs holds the names declared in the last version of the text that
+	// parsed, so they can still be completed while it's being edited
+	defs []check.Definition
+}
+
+// newDocument parses text, keeping the definitions from prev if it
+// doesn't parse.
+func newDocument(
+	uri, text string,
+	checker *check.Checker,
+	prev *document,
+) *document {
+	d := &document{
+		uri:   uri,
+		path:  uriToPath(uri),
+		text:  text,
+		lines: strings.Split(text, "\n"),
+	}
+
+	l := lexer.New(text)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		d.tokens = append(d.tokens, tok)
+	}
+
+	p := parser.New(lexer.New(text))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		d.errors = p.Errors()
+		if prev != nil {
+			d.defs = prev.defs
+		}
+		return d
+	}
+	d.program = program
+	d.defs = checker.Definitions(program)
+	return d
+}
+
+// uriToPath returns the path of a file URI, or an empty string if it isn't
+// one.
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// pathToURI returns the URI of a file.
+func pathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	return u.String()
+}
+
+// utf16Len returns how many UTF-16 code units the runes take up.
+func utf16Len(runes []rune) int {
+	n := 0
+	for _, r := range runes {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// position converts a 1-based line and column, in runes, to a protocol
+// position.
+func (d *document) position(line, column int) position {
+	if line < 1 || line > len(d.lines) {
+		return position{Line: line - 1}
+	}
+	runes := []rune(d.lines[line-1])
+	if column-1 < len(runes) {
+		runes = runes[:column-1]
+	}
+	return position{Line: line - 1, Character: utf16Len(runes)}
+}
+
+// column converts a protocol position to a 1-based line and column, in
+// runes.
+func (d *document) column(pos position) (int, int) {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return pos.Line + 1, 1
+	}
+	n := 0
+	for i, r := range d.lines[pos.Line] {
+		if n >= pos.Character {
+			return pos.Line + 1, len([]rune(d.lines[pos.Line][:i])) + 1
+		}
+		n += utf16.RuneLen(r)
+	}
+	return pos.Line + 1, len([]rune(d.lines[pos.Line])) + 1
+}
+
+// tokenRange returns the range a token covers.
+func (d *document) tokenRange(tok token.Token) span {
+	return span{
+		Start: d.position(tok.Line, tok.Column),
+		End:   d.position(tok.EndLine, tok.EndColumn),
+	}
+}
+
+// end returns the position of the end of the text.
+func (d *document) end() position {
+	last := len(d.lines) - 1
+	return position{Line: last, Character: utf16Len([]rune(d.lines[last]))}
+}
+
+// tokenAt returns the index of the name or string at a position, or -1.
+// A position just after a name counts, since that's where the cursor is
+// after typing it.
+func (d *document) tokenAt(pos position) int {
+	line, column := d.column(pos)
+	for i, tok := range d.tokens {
+		if tok.Type != token.IDENT && tok.Type != token.STRING {
+			continue
+		}
+		if tok.Line == line && tok.EndLine == line &&
+			tok.Column <= column && column <= tok.EndColumn {
+			return i
+		}
+	}
+	return -1
+}
+
+// definition returns where the name at tok was declared, if it was
+// declared in the document.
+func (d *document) definition(tok token.Token) *check.Definition {
+	for i, def := range d.defs {
+		if def.Use.Line == tok.Line && def.Use.Column == tok.Column {
+			return &d.defs[i]
+		}
+	}
+	return nil
+}
+
+// dotted returns where a name like fs.open that the token at i is the end
+// of starts, and the name. For anything else it's i and the token.
+func (d *document) dotted(i int) (int, string) {
+	start, name := i, d.tokens[i].Literal
+	for start >= 2 && d.tokens[start-1].Type == token.PERIOD &&
+		d.tokens[start-2].Type == token.IDENT {
+		start -= 2
+		name = d.tokens[start].Literal + "." + name
+	}
+	return start, name
+}
+
+// use finds where the name the token at i is the end of was declared, like
+// fs.x in fs.x(), which is used and declared as one name.
+func (d *document) use(i int) *check.Definition {
+	start, name := d.dotted(i)
+	def := d.definition(d.tokens[start])
+	if def == nil || def.Use.Literal != name {
+		return nil
+	}
+	return def
+}
+
+// declared returns the let with a name at the top level of the document.
+func (d *document) declared(name string) *ast.LetStatement {
+	if d.program == nil {
+		return nil
+	}
+	for _, stmt := range d.program.Statements {
+		if e, ok := stmt.(*ast.ExportStatement); ok {
+			stmt = e.Statement
+		}
+		if l, ok := stmt.(*ast.LetStatement); ok {
+			for _, n := range l.Names() {
+				if n.Value == name {
+					return l
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// prefix returns the name being typed before a position, which may
+// include periods.
+func (d *document) prefix(pos position) string {
+	line, column := d.column(pos)
+	if line < 1 || line > len(d.lines) {
+		return ""
+	}
+	runes := []rune(d.lines[line-1])
+	if column-1 < len(runes) {
+		runes = runes[:column-1]
+	}
+	start := len(runes)
+	for start > 0 && isNameChar(runes[start-1]) {
+		start--
+	}
+	return string(runes[start:])
+}
+
+// isNameChar is true for the characters the lexer allows in identifiers,
+// including the periods in namespaced names.
+func isNameChar(r rune) bool {
+	return unicode.IsLetter(r) ||
+		unicode.IsDigit(r) ||
+		r == '.' ||
+		r == '?' ||
+		r == '$' ||
+		r == '_'
+}
@@ -0,0 +1,590 @@
+// Package lsp implements keai lsp, a language server that talks to editors
+// over stdio. It publishes parse errors and keai check problems, shows
+// docstrings on hover, jumps to where lets, parameters, and the names in
+// imported modules are defined, completes builtins, stdlib functions, and
+// methods, and formats documents with keai fmt.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zautumnz/keai/ast"
+	"github.com/zautumnz/keai/check"
+	"github.com/zautumnz/keai/evaluator"
+	"github.com/zautumnz/keai/format"
+	"github.com/zautumnz/keai/lexer"
+	"github.com/zautumnz/keai/object"
+	"github.com/zautumnz/keai/parser"
+	"github.com/zautumnz/keai/token"
+)
+
+// server holds what the language server knows.
+type server struct {
+	conn *conn
+
+	// docs holds the open documents, by URI
+	docs map[string]*document
+
+	checker *check.Checker
+
+	// globals holds every builtin and stdlib name, sorted
+	globals []string
+
+	// stdlib holds the stdlib's top-level lets, by name
+	stdlib map[string]*ast.LetStatement
+
+	// methods maps each method name to the types that have it
+	methods map[string][]string
+
+	shutdown bool
+}
+
+// Serve runs a language server, reading requests from in and writing to
+// out, until the client tells it to exit. It uses the stdlib registered
+// with evaluator.SetStdlib. It returns an error if the connection breaks,
+// or if the client exits without asking the server to shut down first.
+func Serve(in io.Reader, out io.Writer) error {
+	s := newServer()
+	s.conn = &conn{r: bufio.NewReader(in), w: out}
+
+	for {
+		body, err := s.conn.read()
+		if err != nil {
+			return err
+		}
+		msg := &message{}
+		if err := json.Unmarshal(body, msg); err != nil {
+			s.conn.reply(
+				json.RawMessage("null"),
+				nil,
+				&rpcError{Code: parseError, Message: err.Error()},
+			)
+			continue
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit without shutdown")
+			}
+			return nil
+		}
+		s.handle(msg)
+	}
+}
+
+func newServer() *server {
+	s := &server{
+		docs:    map[string]*document{},
+		stdlib:  map[string]*ast.LetStatement{},
+		methods: map[string][]string{},
+	}
+
+	programs := []*ast.Program{}
+	for _, f := range evaluator.StdlibFiles() {
+		program := parser.New(lexer.New(f.Source)).ParseProgram()
+		programs = append(programs, program)
+		for _, stmt := range program.Statements {
+			if l, ok := stmt.(*ast.LetStatement); ok {
+				for _, name := range l.Names() {
+					s.stdlib[name.Value] = l
+				}
+			}
+		}
+	}
+	builtins := evaluator.BuiltinNames()
+	s.checker = check.New(builtins, programs...)
+
+	s.globals = builtins
+	for name := range s.stdlib {
+		s.globals = append(s.globals, name)
+	}
+	sort.Strings(s.globals)
+
+	// every type can list its methods, including the ones the stdlib adds
+	env := evaluator.NewStdlibEnvironment()
+	for typ, obj := range object.SystemTypesMap {
+		fn := obj.GetMethod("methods")
+		if fn == nil {
+			continue
+		}
+		names, ok := fn(env).(*object.Array)
+		if !ok {
+			continue
+		}
+		seen := map[string]bool{}
+		for _, n := range names.Elements {
+			name := n.(*object.String).Value
+			if !seen[name] {
+				seen[name] = true
+				s.methods[name] = append(
+					s.methods[name],
+					strings.ToLower(string(typ)),
+				)
+			}
+		}
+	}
+	for _, types := range s.methods {
+		sort.Strings(types)
+	}
+
+	return s
+}
+
+// decode reads a message's params.
+func decode(msg *message, v interface{}) *rpcError {
+	if err := json.Unmarshal(msg.Params, v); err != nil {
+		return &rpcError{Code: invalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// handle answers a message, if it's a request.
+func (s *server) handle(msg *message) {
+	var result interface{}
+	var err *rpcError
+
+	switch msg.Method {
+	case "initialize":
+		result = map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				// the client sends the whole document on every change
+				"textDocumentSync":   1,
+				"hoverProvider":      true,
+				"definitionProvider": true,
+				"completionProvider": map[string]interface{}{
+					"triggerCharacters": []string{"."},
+				},
+				"documentFormattingProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "keai"},
+		}
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/didOpen":
+		p := didOpenParams{}
+		if err = decode(msg, &p); err == nil {
+			s.update(p.TextDocument.URI, p.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		p := didChangeParams{}
+		if err = decode(msg, &p); err == nil && len(p.ContentChanges) > 0 {
+			last := p.ContentChanges[len(p.ContentChanges)-1]
+			s.update(p.TextDocument.URI, last.Text)
+		}
+	case "textDocument/didClose":
+		p := didCloseParams{}
+		if err = decode(msg, &p); err == nil {
+			delete(s.docs, p.TextDocument.URI)
+			s.conn.notify(
+				"textDocument/publishDiagnostics",
+				publishDiagnosticsParams{
+					URI:         p.TextDocument.URI,
+					Diagnostics: []diagnostic{},
+				},
+			)
+		}
+	case "textDocument/hover":
+		p := textDocumentPositionParams{}
+		if err = decode(msg, &p); err == nil {
+			result = s.hover(p)
+		}
+	case "textDocument/definition":
+		p := textDocumentPositionParams{}
+		if err = decode(msg, &p); err == nil {
+			result = s.definition(p)
+		}
+	case "textDocument/completion":
+		p := textDocumentPositionParams{}
+		if err = decode(msg, &p); err == nil {
+			result = s.completion(p)
+		}
+	case "textDocument/formatting":
+		p := formattingParams{}
+		if err = decode(msg, &p); err == nil {
+			result = s.formatting(p)
+		}
+	default:
+		if msg.ID != nil {
+			err = &rpcError{
+				Code:    methodNotFound,
+				Message: "unknown method: " + msg.Method,
+			}
+		}
+	}
+
+	if msg.ID != nil {
+		s.conn.reply(msg.ID, result, err)
+	}
+}
+
+// update parses a new version of a document and publishes its
+// diagnostics.
+func (s *server) update(uri, text string) {
+	d := newDocument(uri, text, s.checker, s.docs[uri])
+	s.docs[uri] = d
+	s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: s.diagnostics(d),
+	})
+}
+
+// aroundLine finds the line number in a parse error.
+var aroundLine = regexp.MustCompile(`around line (\d+)`)
+
+// diagnostics returns a document's parse errors, or if it parsed, the
+// problems keai check finds in it.
+func (s *server) diagnostics(d *document) []diagnostic {
+	diags := []diagnostic{}
+	for _, msg := range d.errors {
+		// the parser's line numbers are rough, and count from 0
+		line := 0
+		if m := aroundLine.FindStringSubmatch(msg); m != nil {
+			line, _ = strconv.Atoi(m[1])
+		}
+		if line >= len(d.lines) {
+			line = len(d.lines) - 1
+		}
+		diags = append(diags, diagnostic{
+			Range: span{
+				Start: position{Line: line},
+				End:   d.position(line+1, len([]rune(d.lines[line]))+1),
+			},
+			Severity: severityError,
+			Source:   "keai",
+			Message:  msg,
+		})
+	}
+	if d.program == nil {
+		return diags
+	}
+
+	for _, p := range s.checker.Check(d.path, d.program) {
+		start := d.position(p.Line, p.Column)
+		end := start
+		// underline the name the problem is about
+		for _, tok := range d.tokens {
+			if tok.Line == p.Line && tok.Column == p.Column {
+				end = d.position(tok.EndLine, tok.EndColumn)
+				break
+			}
+		}
+		diags = append(diags, diagnostic{
+			Range:    span{Start: start, End: end},
+			Severity: severityWarning,
+			Source:   "keai check",
+			Message:  p.Message,
+		})
+	}
+	return diags
+}
+
+// hover describes the name under the cursor.
+func (s *server) hover(p textDocumentPositionParams) *hover {
+	d := s.docs[p.TextDocument.URI]
+	if d == nil {
+		return nil
+	}
+	i := d.tokenAt(p.Position)
+	if i < 0 || d.tokens[i].Type != token.IDENT {
+		return nil
+	}
+	tok := d.tokens[i]
+	_, name := d.dotted(i)
+
+	text := ""
+	if def := d.use(i); def != nil {
+		text = describe(name, def.Node)
+	} else if _, l := s.member(d, i); l != nil {
+		text = describe(tok.Literal, l)
+	} else if l := s.stdlib[name]; l != nil {
+		text = describe(name, l)
+	} else if s.isBuiltin(name) {
+		text = "```keai\n" + name + "\n```\n\n" +
+			evaluator.BuiltinDoc(name)
+	}
+	if text == "" {
+		return nil
+	}
+
+	r := d.tokenRange(tok)
+	return &hover{
+		Contents: markupContent{Kind: "markdown", Value: text},
+		Range:    &r,
+	}
+}
+
+func (s *server) isBuiltin(name string) bool {
+	i := sort.SearchStrings(s.globals, name)
+	return i < len(s.globals) && s.globals[i] == name
+}
+
+// describe returns markdown describing a declaration of name.
+func describe(name string, node ast.Node) string {
+	sig := ""
+	doc := ""
+	switch n := node.(type) {
+	case *ast.LetStatement:
+		sig = "let " + name
+		if fn, ok := n.Value.(*ast.FunctionLiteral); ok {
+			sig += " = " + signature(fn)
+			if fn.DocString != nil {
+				doc = fn.DocString.Value
+			}
+		}
+	case *ast.MutableStatement:
+		sig = "mutable " + name
+	case *ast.FunctionLiteral:
+		sig = name + " (parameter of " + signature(n) + ")"
+	case *ast.ForeachStatement:
+		sig = name + " (foreach variable)"
+	default:
+		return ""
+	}
+
+	text := "```keai\n" + sig + "\n```"
+	if doc != "" {
+		text += "\n\n" + doc
+	}
+	return text
+}
+
+// signature returns how a function literal starts, like `fn (a, b = 1)`.
+func signature(fn *ast.FunctionLiteral) string {
+	params := []string{}
+	for _, p := range fn.Parameters {
+		if d, ok := fn.Defaults[p.Value]; ok {
+			params = append(params, p.Value+" = "+d.String())
+		} else {
+			params = append(params, p.Value)
+		}
+	}
+	return "fn (" + strings.Join(params, ", ") + ")"
+}
+
+// module returns the module a let imports, if it's an import of a string.
+func (s *server) module(d *document, node ast.Node) *document {
+	l, ok := node.(*ast.LetStatement)
+	if !ok {
+		return nil
+	}
+	imp, ok := l.Value.(*ast.ImportExpression)
+	if !ok {
+		return nil
+	}
+	name, ok := imp.Name.(*ast.StringLiteral)
+	if !ok {
+		return nil
+	}
+	return s.load(d, name.Value)
+}
+
+// load reads the module a document imports by name. Modules are found the
+// way import finds them, or next to the document.
+func (s *server) load(d *document, name string) *document {
+	path := evaluator.FindModule(name)
+	if path == "" && d.path != "" {
+		path = filepath.Join(filepath.Dir(d.path), name+".keai")
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return newDocument(pathToURI(path), string(b), s.checker, nil)
+}
+
+// member returns the module and the let, if the name at tokens[i] is the
+// b of a.b where a is an imported module.
+func (s *server) member(d *document, i int) (*document, *ast.LetStatement) {
+	if i < 2 || d.tokens[i-2].Type != token.IDENT {
+		return nil, nil
+	}
+	dot := d.tokens[i-1].Type
+	if dot != token.PERIOD && dot != token.QUESTION_PERIOD {
+		return nil, nil
+	}
+	def := d.definition(d.tokens[i-2])
+	if def == nil {
+		return nil, nil
+	}
+	m := s.module(d, def.Node)
+	if m == nil {
+		return nil, nil
+	}
+	return m, m.declared(d.tokens[i].Literal)
+}
+
+// definition finds where the name under the cursor was declared, or the
+// module an import refers to.
+func (s *server) definition(p textDocumentPositionParams) *location {
+	d := s.docs[p.TextDocument.URI]
+	if d == nil {
+		return nil
+	}
+	i := d.tokenAt(p.Position)
+	if i < 0 {
+		return nil
+	}
+	tok := d.tokens[i]
+
+	if tok.Type == token.STRING {
+		if i < 2 || d.tokens[i-1].Type != token.LPAREN ||
+			d.tokens[i-2].Type != token.IMPORT {
+			return nil
+		}
+		if m := s.load(d, tok.Literal); m != nil {
+			return &location{URI: m.uri}
+		}
+		return nil
+	}
+
+	if def := d.use(i); def != nil {
+		return &location{URI: d.uri, Range: d.tokenRange(def.Token)}
+	}
+	if m, l := s.member(d, i); l != nil {
+		return &location{URI: m.uri, Range: m.tokenRange(l.Name.Token)}
+	}
+	return nil
+}
+
+// completion lists the names that could finish what's being typed: the
+// names in a namespace after `fs.` and so on, the names an imported module
+// defines after its name and a period, methods after anything else and a
+// period, and otherwise globals and the document's own names.
+func (s *server) completion(p textDocumentPositionParams) []completionItem {
+	d := s.docs[p.TextDocument.URI]
+	if d == nil {
+		return nil
+	}
+	prefix := d.prefix(p.Position)
+	items := []completionItem{}
+
+	if dot := strings.LastIndex(prefix, "."); dot >= 0 {
+		ns := prefix[:dot+1]
+
+		found := false
+		for _, name := range s.globals {
+			if strings.HasPrefix(name, ns) {
+				found = true
+				items = append(items, completionItem{
+					Label:  name[len(ns):],
+					Kind:   s.kind(name),
+					Detail: name,
+				})
+			}
+		}
+		if found {
+			return items
+		}
+
+		if m := s.importedBy(d, prefix[:dot]); m != nil && m.program != nil {
+			for _, stmt := range m.program.Statements {
+				if e, ok := stmt.(*ast.ExportStatement); ok {
+					stmt = e.Statement
+				}
+				if l, ok := stmt.(*ast.LetStatement); ok {
+					for _, name := range l.Names() {
+						items = append(items, completionItem{
+							Label:  name.Value,
+							Kind:   kindOf(l),
+							Detail: prefix[:dot+1] + name.Value,
+						})
+					}
+				}
+			}
+			return items
+		}
+
+		names := []string{}
+		for name := range s.methods {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			items = append(items, completionItem{
+				Label:  name,
+				Kind:   kindMethod,
+				Detail: strings.Join(s.methods[name], ", "),
+			})
+		}
+		return items
+	}
+
+	seen := map[string]bool{}
+	for _, def := range d.defs {
+		name := def.Token.Literal
+		if def.Use != def.Token || seen[name] {
+			continue
+		}
+		seen[name] = true
+		kind := kindVariable
+		if m := s.module(d, def.Node); m != nil {
+			kind = kindModule
+		} else if l, ok := def.Node.(*ast.LetStatement); ok {
+			kind = kindOf(l)
+		}
+		items = append(items, completionItem{Label: name, Kind: kind})
+	}
+	for _, name := range s.globals {
+		if !seen[name] {
+			items = append(items, completionItem{
+				Label: name,
+				Kind:  s.kind(name),
+			})
+		}
+	}
+	return items
+}
+
+// importedBy returns the module a name in the document was bound to with
+// import, if it was.
+func (s *server) importedBy(d *document, name string) *document {
+	for _, def := range d.defs {
+		if def.Use == def.Token && def.Token.Literal == name {
+			if m := s.module(d, def.Node); m != nil {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+// kind returns the completion kind of a global.
+func (s *server) kind(name string) int {
+	if l := s.stdlib[name]; l != nil {
+		return kindOf(l)
+	}
+	return kindFunction
+}
+
+// kindOf returns the completion kind of a let.
+func kindOf(l *ast.LetStatement) int {
+	if _, ok := l.Value.(*ast.FunctionLiteral); ok {
+		return kindFunction
+	}
+	return kindVariable
+}
+
+// formatting formats a document with keai fmt. Documents that don't parse
+// are left alone.
+func (s *server) formatting(p formattingParams) []textEdit {
+	d := s.docs[p.TextDocument.URI]
+	if d == nil {
+		return nil
+	}
+	out, err := format.Source([]byte(d.text))
+	if err != nil || string(out) == d.text {
+		return []textEdit{}
+	}
+	return []textEdit{{
+		Range:   span{End: d.end()},
+		NewText: string(out),
+	}}
+}
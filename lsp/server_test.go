@@ -0,0 +1,126 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zautumnz/keai/evaluator"
+)
+
+const src = `let add = fn(a, b = 1) {
+    'adds two numbers'
+    a + b
+}
+print(add(1, y), util.len)
+fs.
+`
+
+// request frames a request, or a notification if id is 0.
+func request(id int, method string, params interface{}) string {
+	msg := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+	}
+	if id != 0 {
+		msg["id"] = id
+	}
+	b, _ := json.Marshal(msg)
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(b), b)
+}
+
+func at(line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]string{"uri": "file:///x.keai"},
+		"position":     map[string]int{"line": line, "character": character},
+	}
+}
+
+func TestServe(t *testing.T) {
+	evaluator.SetStdlib(evaluator.SourceFile{
+		Name:   "stdlib.keai",
+		Source: "let string.shout = fn () { 'shouts' self }",
+	})
+	defer evaluator.SetStdlib()
+
+	doc := map[string]string{"uri": "file:///x.keai", "text": src}
+	in := strings.Join([]string{
+		request(1, "initialize", map[string]interface{}{}),
+		request(0, "initialized", map[string]interface{}{}),
+		request(0, "textDocument/didOpen", map[string]interface{}{
+			"textDocument": doc,
+		}),
+		request(2, "textDocument/hover", at(4, 7)),
+		request(3, "textDocument/definition", at(4, 7)),
+		request(4, "textDocument/completion", at(5, 3)),
+		request(5, "textDocument/formatting", map[string]interface{}{
+			"textDocument": doc,
+		}),
+		request(6, "nope", nil),
+		request(8, "textDocument/hover", at(4, 23)),
+		request(7, "shutdown", nil),
+		request(0, "exit", nil),
+	}, "")
+
+	var out bytes.Buffer
+	if err := Serve(strings.NewReader(in), &out); err != nil {
+		t.Fatalf("Serve returned %s", err)
+	}
+
+	c := &conn{r: bufio.NewReader(&out)}
+	results := map[int]string{}
+	notes := []string{}
+	for {
+		body, err := c.read()
+		if err != nil {
+			break
+		}
+		msg := struct {
+			ID     int
+			Method string
+			Params json.RawMessage
+			Result json.RawMessage
+			Error  *rpcError
+		}{}
+		json.Unmarshal(body, &msg)
+		switch {
+		case msg.Method != "":
+			notes = append(notes, string(msg.Params))
+		case msg.Error != nil:
+			results[msg.ID] = "error: " + msg.Error.Message
+		default:
+			results[msg.ID] = string(msg.Result)
+		}
+	}
+
+	if len(notes) != 1 ||
+		!strings.Contains(notes[0], `"message":"undefined: y"`) ||
+		!strings.Contains(notes[0], `"start":{"line":4,"character":13}`) {
+		t.Errorf("wrong diagnostics: %s", notes)
+	}
+
+	expected := map[int]string{
+		2: `"value":"` + "```keai\\nlet add = fn (a, b = 1)\\n```\\n\\nadds two numbers",
+		3: `"range":{"start":{"line":0,"character":4},` +
+			`"end":{"line":0,"character":7}}`,
+		4: `{"label":"open","kind":3,"detail":"fs.open"}`,
+		5: `"newText":"let add = fn (a, b = 1) {`,
+		6: "error: unknown method: nope",
+		7: "null",
+		8: `"value":"` + "```keai\\nutil.len\\n```",
+	}
+	for id, want := range expected {
+		if !strings.Contains(results[id], want) {
+			t.Errorf(
+				"wrong result for %d. expected %s in\n%s",
+				id,
+				want,
+				results[id],
+			)
+		}
+	}
+}
@@ -12,6 +12,13 @@ type Boolean struct {
 	Value bool
 }
 
+// TRUE and FALSE are the only booleans, so they can be compared by
+// pointer.
+var (
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+)
+
 // Type returns the type of this object.
 func (b *Boolean) Type() Type {
 	return BOOLEAN_OBJ
@@ -7,6 +7,12 @@ type BuiltinFunction func(env *Environment, args ...Object) Object
 type Builtin struct {
 	// Value holds the function we wrap.
 	Fn BuiltinFunction
+
+	// Name is the name it was registered with, if it was
+	Name string
+
+	// Doc is its docstring, if it has one
+	Doc string
 }
 
 // Type returns the type of this object.
@@ -24,7 +30,7 @@ func (b *Builtin) Inspect() string {
 func (b *Builtin) GetMethod(method string) BuiltinFunction {
 	if method == "methods" {
 		return func(env *Environment, args ...Object) Object {
-			names := []string{"methods"}
+			names := []string{"doc", "methods"}
 
 			result := make([]Object, len(names))
 			for i, txt := range names {
@@ -33,6 +39,11 @@ func (b *Builtin) GetMethod(method string) BuiltinFunction {
 			return &Array{Elements: result}
 		}
 	}
+	if method == "doc" {
+		return func(env *Environment, args ...Object) Object {
+			return &String{Value: b.Doc}
+		}
+	}
 	return nil
 }
 
@@ -15,6 +15,10 @@ type Environment struct {
 	// readonly marks names as read-only.
 	readonly map[string]bool
 
+	// owned marks names whose array or hash was copied by an element
+	// assignment and hasn't been read since, so it can be changed in place.
+	owned map[string]bool
+
 	// outer holds any parent environment. Our env. allows
 	// nesting to implement scope.
 	outer *Environment
@@ -23,6 +27,13 @@ type Environment struct {
 	// environment, if any
 	permit []string
 
+	// module is true for the top-level scope of an imported module,
+	// whose outer environment is the shared standard library.
+	module bool
+
+	// exports holds the names marked with `export`, if any.
+	exports map[string]bool
+
 	// Args used when creating this env. Used in ...
 	CurrentArgs []Object
 
@@ -45,6 +56,16 @@ func NewEnclosedEnvironment(outer *Environment, args []Object) *Environment {
 	return env
 }
 
+// NewModuleEnvironment creates the top-level environment of an imported
+// module. Names the module doesn't define itself are looked up in stdlib,
+// but only the module's own bindings are exported.
+func NewModuleEnvironment(stdlib *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = stdlib
+	env.module = true
+	return env
+}
+
 // NewTemporaryScope creates a temporary scope where some values
 // are ignored.
 // This is used as a sneaky hack to allow `foreach` to access all
@@ -78,20 +99,101 @@ func (e *Environment) Names(prefix string) []string {
 	return ret
 }
 
+// Members returns the bindings named like name.x, in this scope and the
+// ones it encloses, keyed by x. This is how namespaces like `fs` and `sys`
+// are put together, since `let fs.ls = ...` binds "fs.ls".
+func (e *Environment) Members(name string) map[string]Object {
+	members := map[string]Object{}
+	if e.outer != nil {
+		members = e.outer.Members(name)
+	}
+	prefix := name + "."
+	e.owned = nil
+	for key, val := range e.store {
+		if strings.HasPrefix(key, prefix) {
+			members[strings.TrimPrefix(key, prefix)] = val
+		}
+	}
+	return members
+}
+
+// Outer returns the enclosing environment, or nil for the outermost one.
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// Vars returns the bindings in this scope, without the ones it encloses.
+func (e *Environment) Vars() map[string]Object {
+	vars := make(map[string]Object, len(e.store))
+	e.owned = nil
+	for k, v := range e.store {
+		vars[k] = v
+	}
+	return vars
+}
+
+// IsTopLevel returns true if this is the outermost scope of a program
+// or module.
+func (e *Environment) IsTopLevel() bool {
+	return e.outer == nil || e.module
+}
+
 // Get returns the value of a given variable, by name.
 func (e *Environment) Get(name string) (Object, bool) {
 	obj, ok := e.store[name]
+	if ok {
+		delete(e.owned, name)
+	}
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
 	return obj, ok
 }
 
+// Owned returns the value of a variable without reading it, if the
+// variable owns it (see Own).
+func (e *Environment) Owned(name string) (Object, bool) {
+	if obj, ok := e.store[name]; ok {
+		return obj, e.owned[name]
+	}
+	if e.outer != nil {
+		return e.outer.Owned(name)
+	}
+	return nil, false
+}
+
+// Own marks the value of a variable as owned by it: nothing else has
+// seen it, so element assignments can change it in place instead of
+// copying it. Reading the variable with Get gives that up.
+func (e *Environment) Own(name string) {
+	if _, ok := e.store[name]; ok {
+		if e.owned == nil {
+			e.owned = make(map[string]bool)
+		}
+		e.owned[name] = true
+		return
+	}
+	if e.outer != nil {
+		e.outer.Own(name)
+	}
+}
+
+// IsConstant returns true if a variable was bound with let.
+func (e *Environment) IsConstant(name string) bool {
+	if _, ok := e.store[name]; ok {
+		return e.readonly[name]
+	}
+	if e.outer != nil {
+		return e.outer.IsConstant(name)
+	}
+	return false
+}
+
 // Set stores the value of a variable, by name.
 func (e *Environment) Set(name string, val Object) Object {
 	cur := e.store[name]
 
-	if e.outer == nil && !utils.IsRepl {
+	if e.IsTopLevel() && !utils.IsRepl {
 		fmt.Printf(
 			"No mutable variables at the top level! %s must be bound with let!\n",
 			name,
@@ -121,6 +223,7 @@ func (e *Environment) Set(name string, val Object) Object {
 			// we're permitted to store this variable
 			if v == name {
 				e.store[name] = val
+				delete(e.owned, name)
 				return val
 			}
 		}
@@ -142,6 +245,7 @@ func (e *Environment) Set(name string, val Object) Object {
 
 	// ...and otherwise, just store it in the current scope
 	e.store[name] = val
+	delete(e.owned, name)
 	return val
 }
 
@@ -154,6 +258,7 @@ func (e *Environment) SetLet(name string, val Object) Object {
 
 	// store the value
 	e.store[name] = val
+	delete(e.owned, name)
 
 	// flag as read-only.
 	e.readonly[name] = true
@@ -161,14 +266,27 @@ func (e *Environment) SetLet(name string, val Object) Object {
 	return val
 }
 
+// Export marks a top-level binding as exported.
+func (e *Environment) Export(name string) {
+	if e.exports == nil {
+		e.exports = make(map[string]bool)
+	}
+	e.exports[name] = true
+}
+
 // ExportedHash returns a new Hash with the names and values of every publically
-// exported binding in the environment; that is, every top-level binding (not
-// in a block).
+// exported binding in the environment. If the module used `export`, that's
+// only the names it marked; otherwise it's every top-level binding (not in a
+// block).
 // This is used by the module import system to wrap up the
 // evaulated module into an object.
 func (e *Environment) ExportedHash() *Hash {
 	pairs := make(map[HashKey]HashPair)
+	e.owned = nil
 	for k, v := range e.store {
+		if len(e.exports) > 0 && !e.exports[k] {
+			continue
+		}
 		s := &String{Value: k}
 		pairs[s.HashKey()] = HashPair{Key: s, Value: v}
 	}
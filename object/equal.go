@@ -0,0 +1,227 @@
+package object
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Equal reports whether two objects are deeply equal. Arrays are equal if
+// their elements are, and hashes if they have the same keys with equal
+// values, whatever order they were built in. Functions are equal if they
+// have the same source and were defined in the same environment, and
+// builtins if they wrap the same Go function. Integers and floats are
+// never equal to each other, since they're different types.
+func Equal(a, b Object) bool {
+	if a == b {
+		return true
+	}
+	// ranges are lazy arrays, and equal to arrays with the same elements
+	if r, ok := a.(*Range); ok && b != nil && b.Type() == ARRAY_OBJ {
+		a = r.Array()
+	}
+	if r, ok := b.(*Range); ok && a != nil && a.Type() == ARRAY_OBJ {
+		b = r.Array()
+	}
+	if a == nil || b == nil || a.Type() != b.Type() {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		return a.Value == b.(*Integer).Value
+	case *Float:
+		return a.Value == b.(*Float).Value
+	case *String:
+		return a.Value == b.(*String).Value
+	case *Boolean:
+		return a.Value == b.(*Boolean).Value
+	case *Null:
+		return true
+	case *DocString:
+		return a.Value == b.(*DocString).Value
+	case *Error:
+		b := b.(*Error)
+		if (a.Code == nil) != (b.Code == nil) ||
+			(a.Code != nil && *a.Code != *b.Code) {
+			return false
+		}
+		if (a.Cause == nil) != (b.Cause == nil) ||
+			(a.Cause != nil && !Equal(a.Cause, b.Cause)) {
+			return false
+		}
+		return a.Message == b.Message && a.Kind == b.Kind &&
+			Equal(a.Data, b.Data)
+	case *Range:
+		// ranges with the same length, first, and second elements have
+		// the same elements
+		b := b.(*Range)
+		for i := int64(0); i < 2; i++ {
+			x, xok := a.At(i)
+			y, yok := b.At(i)
+			if xok != yok || x != y {
+				return false
+			}
+		}
+		return a.Len() == b.Len()
+	case *Array:
+		b := b.(*Array)
+		if len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !Equal(a.Elements[i], b.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		b := b.(*Hash)
+		if len(a.Pairs) != len(b.Pairs) {
+			return false
+		}
+		for k, pa := range a.Pairs {
+			pb, ok := b.Pairs[k]
+			if !ok || !Equal(pa.Value, pb.Value) {
+				return false
+			}
+		}
+		return true
+	case *Function:
+		b := b.(*Function)
+		return a.Env == b.Env && a.stringify() == b.stringify()
+	case *Builtin:
+		b := b.(*Builtin)
+		return reflect.ValueOf(a.Fn).Pointer() ==
+			reflect.ValueOf(b.Fn).Pointer()
+	case *Module:
+		b := b.(*Module)
+		return a.Name == b.Name && Equal(a.Attrs, b.Attrs)
+	case *ReturnValue:
+		return Equal(a.Value, b.(*ReturnValue).Value)
+	default:
+		return false
+	}
+}
+
+// sortedKeys returns the keys of the given hashes, sorted by how they look.
+func sortedKeys(hashes ...*Hash) []HashKey {
+	seen := map[HashKey]string{}
+	for _, h := range hashes {
+		for k, p := range h.Pairs {
+			seen[k] = p.Key.Inspect()
+		}
+	}
+
+	keys := []HashKey{}
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return seen[keys[i]] < seen[keys[j]]
+	})
+	return keys
+}
+
+// ANSI colors used by Diff
+const (
+	diffRed   = "\u001b[31m"
+	diffGreen = "\u001b[32m"
+	diffReset = "\u001b[0m"
+)
+
+// differ builds the lines of a diff.
+type differ struct {
+	lines []string
+	color bool
+}
+
+// line adds a line. The marker is "-" for expected, "+" for actual, or " "
+// for both.
+func (d *differ) line(marker string, depth int, label, value string) {
+	s := marker + " " + strings.Repeat("    ", depth) + label + value
+	if d.color && marker == "-" {
+		s = diffRed + s + diffReset
+	} else if d.color && marker == "+" {
+		s = diffGreen + s + diffReset
+	}
+	d.lines = append(d.lines, s)
+}
+
+// quote formats a value for a diff, quoting strings.
+func quote(o Object) string {
+	if s, ok := o.(*String); ok {
+		return strconv.Quote(s.Value)
+	}
+	return o.Inspect()
+}
+
+// keyLabel formats a hash key for a diff.
+func keyLabel(k Object) string {
+	return quote(k) + ": "
+}
+
+// walk diffs two values, nesting into arrays and hashes they both are. A
+// nil value is missing from that side. end follows each value.
+func (d *differ) walk(
+	depth int,
+	label string,
+	expected, actual Object,
+	end string,
+) {
+	switch {
+	case expected == nil:
+		d.line("+", depth, label, quote(actual)+end)
+	case actual == nil:
+		d.line("-", depth, label, quote(expected)+end)
+	case Equal(expected, actual):
+		d.line(" ", depth, label, quote(actual)+end)
+	case expected.Type() == ARRAY_OBJ && actual.Type() == ARRAY_OBJ:
+		exp := expected.(*Array).Elements
+		act := actual.(*Array).Elements
+		d.line(" ", depth, label, "[")
+		for i := 0; i < len(exp) || i < len(act); i++ {
+			var e, a Object
+			if i < len(exp) {
+				e = exp[i]
+			}
+			if i < len(act) {
+				a = act[i]
+			}
+			d.walk(depth+1, "", e, a, ",")
+		}
+		d.line(" ", depth, "", "]"+end)
+	case expected.Type() == HASH_OBJ && actual.Type() == HASH_OBJ:
+		exp := expected.(*Hash)
+		act := actual.(*Hash)
+		d.line(" ", depth, label, "{")
+		for _, k := range sortedKeys(exp, act) {
+			e, inExp := exp.Pairs[k]
+			a, inAct := act.Pairs[k]
+			switch {
+			case inExp && inAct:
+				d.walk(depth+1, keyLabel(e.Key), e.Value, a.Value, ",")
+			case inExp:
+				d.walk(depth+1, keyLabel(e.Key), e.Value, nil, ",")
+			default:
+				d.walk(depth+1, keyLabel(a.Key), nil, a.Value, ",")
+			}
+		}
+		d.line(" ", depth, "", "}"+end)
+	default:
+		d.line("-", depth, label, quote(expected)+end)
+		d.line("+", depth, label, quote(actual)+end)
+	}
+}
+
+// Diff returns a line-by-line diff of expected and actual, nesting into
+// arrays and hashes. Lines only in expected start with "-", lines only in
+// actual start with "+", and with color they're red and green.
+func Diff(expected, actual Object, color bool) string {
+	d := &differ{color: color}
+	d.line("-", 0, "", "expected")
+	d.line("+", 0, "", "actual")
+	d.walk(0, "", expected, actual, "")
+	return strings.Join(d.lines, "\n")
+}
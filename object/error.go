@@ -15,8 +15,23 @@ type Error struct {
 	// If we're calling the error() builtin
 	BuiltinCall bool
 
+	// Import is set when an import failed with this error, which stops
+	// the program or module the import is in
+	Import bool
+
+	// Kind is an optional name for the sort of error this is, like
+	// "not_found", for telling errors apart without parsing messages
+	Kind string
+
+	// Cause is the error this one was made from, if any
+	Cause *Error
+
 	// Any extra data
-	Data string
+	Data Object
+
+	// Stack holds where `?` has returned this error from, innermost
+	// first
+	Stack []string
 }
 
 // Type returns the type of this object.
@@ -27,20 +42,65 @@ func (e *Error) Type() Type {
 // Inspect returns a string-representation of the given object.
 func (e *Error) Inspect() string {
 	msg := "ERROR: " + e.Message
+	if e.Kind != "" {
+		msg += "; KIND: " + e.Kind
+	}
 	if e.Code != nil {
 		msg += "; CODE: " + fmt.Sprint(*e.Code)
 	}
-	if e.Data != "" {
-		msg += "; DATA: " + fmt.Sprint(e.Data)
+	if e.Data != nil {
+		msg += "; DATA: " + e.Data.Inspect()
+	}
+	if e.Cause != nil {
+		msg += "; CAUSE: " + e.Cause.Inspect()
 	}
 	return msg
 }
 
 // GetMethod returns a method against the object.
 // (Built-in methods only.)
-func (e *Error) GetMethod(string) BuiltinFunction {
-	// There are no methods available upon a return-object.
-	return nil
+func (e *Error) GetMethod(method string) BuiltinFunction {
+	var val Object = NULL
+	switch method {
+	case "methods":
+		names := []string{
+			"cause", "code", "data", "kind", "message", "methods", "stack",
+		}
+		result := make([]Object, len(names))
+		for i, name := range names {
+			result[i] = &String{Value: name}
+		}
+		val = &Array{Elements: result}
+	case "message":
+		val = &String{Value: e.Message}
+	case "code":
+		if e.Code != nil {
+			val = &Integer{Value: int64(*e.Code)}
+		}
+	case "kind":
+		if e.Kind != "" {
+			val = &String{Value: e.Kind}
+		}
+	case "cause":
+		if e.Cause != nil {
+			val = e.Cause
+		}
+	case "data":
+		if e.Data != nil {
+			val = e.Data
+		}
+	case "stack":
+		stack := make([]Object, len(e.Stack))
+		for i, s := range e.Stack {
+			stack[i] = &String{Value: s}
+		}
+		val = &Array{Elements: stack}
+	default:
+		return nil
+	}
+	return func(env *Environment, args ...Object) Object {
+		return val
+	}
 }
 
 // ToInterface converts this object to a go-interface, which will allow
@@ -52,11 +112,17 @@ func (e *Error) ToInterface() interface{} {
 // JSON returns a json-friendly string
 func (e *Error) JSON(indent bool) string {
 	s := `{"error":"` + escapeQuotes(e.Message) + `"`
+	if e.Kind != "" {
+		s += `,"kind":"` + escapeQuotes(e.Kind) + `"`
+	}
 	if e.Code != nil {
 		s += `,"code":` + fmt.Sprint(*e.Code)
 	}
-	if e.Data != "" {
-		s += `,"data":` + fmt.Sprint(*e.Code)
+	if e.Data != nil {
+		s += `,"data":` + e.Data.JSON(false)
+	}
+	if e.Cause != nil {
+		s += `,"cause":` + e.Cause.JSON(false)
 	}
 
 	s += "}"
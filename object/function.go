@@ -21,9 +21,11 @@ type Function struct {
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Defaults   map[string]ast.Expression
+	Patterns   map[string]ast.Expression
 	Env        *Environment
 	DocString  *ast.DocStringLiteral
 	Name       string
+	Generator  bool
 }
 
 func (f *Function) stringify() string {
@@ -0,0 +1,107 @@
+package object
+
+// Generator is what calling a function that yields returns. It runs the
+// function a bit at a time, up to each yield, as its values are asked for.
+type Generator struct {
+	// Err is the error that stopped the generator, if any
+	Err Object
+
+	// resume runs the function up to its next yield, returning the value
+	// yielded, or false once the function has finished, and stop ends the
+	// function early
+	resume func() (Object, bool)
+	stop   func()
+
+	// offset holds how many values we've had
+	offset int64
+}
+
+// NewGenerator returns a generator that resumes and stops its function
+// with the given functions.
+func NewGenerator(resume func() (Object, bool), stop func()) *Generator {
+	return &Generator{resume: resume, stop: stop}
+}
+
+// Resume runs the function up to its next yield, returning the value
+// yielded, or false once the function has finished. If the function ends
+// with an error, that's the last value.
+func (g *Generator) Resume() (Object, bool) {
+	return g.resume()
+}
+
+// Stop implements the Stopper interface, ending the function early if it
+// hasn't finished.
+func (g *Generator) Stop() {
+	g.stop()
+}
+
+// Type returns the type of this object.
+func (g *Generator) Type() Type {
+	return GENERATOR_OBJ
+}
+
+// Inspect returns a string-representation of the given object.
+func (g *Generator) Inspect() string {
+	return "<generator>"
+}
+
+// GetMethod returns a method against the object.
+// (Built-in methods only.)
+func (g *Generator) GetMethod(method string) BuiltinFunction {
+	switch method {
+	case "next":
+		return func(env *Environment, args ...Object) Object {
+			val, _, ok := g.Next()
+			if g.Err != nil {
+				return g.Err
+			}
+			step := &Hash{Pairs: map[HashKey]HashPair{}}
+			set := func(key string, val Object) {
+				k := &String{Value: key}
+				step.Pairs[k.HashKey()] = HashPair{Key: k, Value: val}
+			}
+			if ok {
+				set("value", val)
+			} else {
+				set("done", TRUE)
+			}
+			return step
+		}
+	case "stop":
+		return func(env *Environment, args ...Object) Object {
+			g.Stop()
+			return NULL
+		}
+	}
+	return nil
+}
+
+// Reset implements the Iterable interface. Generators can only be
+// iterated over once, so it does nothing.
+func (g *Generator) Reset() {}
+
+// Next implements the Iterable interface, resuming the generator. An
+// error ends the iteration, and is kept in Err.
+func (g *Generator) Next() (Object, Object, bool) {
+	val, ok := g.Resume()
+	if ok && val.Type() == ERROR_OBJ {
+		g.Err = val
+		ok = false
+	}
+	if !ok {
+		return nil, &Integer{Value: 0}, false
+	}
+	g.offset++
+	return val, &Integer{Value: g.offset - 1}, true
+}
+
+// ToInterface converts this object to a go-interface, which will allow
+// it to be used naturally in our sprintf/printf primitives.
+func (g *Generator) ToInterface() interface{} {
+	return "<GENERATOR>"
+}
+
+// JSON returns a json-friendly string
+func (g *Generator) JSON(indent bool) string {
+	return `"<generator>"`
+}
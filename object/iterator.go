@@ -0,0 +1,78 @@
+package object
+
+// Iterator is a lazy sequence of values, like what iter.map returns. Start
+// begins the sequence again, returning a function that gets each value in
+// turn, and false after the last one.
+type Iterator struct {
+	Start func() func() (Object, bool)
+
+	// Err is the error that stopped the last iteration early, if any
+	Err Object
+
+	// Finish, if set, is called when nothing more will be taken from the
+	// iterator, to stop whatever it's iterating over
+	Finish func()
+
+	// next gets the next value, and offset is how many we've had
+	next   func() (Object, bool)
+	offset int64
+}
+
+// Type returns the type of this object.
+func (it *Iterator) Type() Type {
+	return ITERATOR_OBJ
+}
+
+// Inspect returns a string-representation of the given object.
+func (it *Iterator) Inspect() string {
+	return "<iterator>"
+}
+
+// GetMethod returns a method against the object.
+// (Built-in methods only.)
+func (it *Iterator) GetMethod(string) BuiltinFunction {
+	return nil
+}
+
+// Stop implements the Stopper interface.
+func (it *Iterator) Stop() {
+	if it.Finish != nil {
+		it.Finish()
+	}
+}
+
+// Reset implements the Iterable interface, starting the sequence again.
+func (it *Iterator) Reset() {
+	it.next = it.Start()
+	it.offset = 0
+	it.Err = nil
+}
+
+// Next implements the Iterable interface. An error ends the iteration,
+// and is kept in Err.
+func (it *Iterator) Next() (Object, Object, bool) {
+	if it.next == nil {
+		it.Reset()
+	}
+	val, ok := it.next()
+	if ok && val.Type() == ERROR_OBJ {
+		it.Err = val
+		ok = false
+	}
+	if !ok {
+		return nil, &Integer{Value: 0}, false
+	}
+	it.offset++
+	return val, &Integer{Value: it.offset - 1}, true
+}
+
+// ToInterface converts this object to a go-interface, which will allow
+// it to be used naturally in our sprintf/printf primitives.
+func (it *Iterator) ToInterface() interface{} {
+	return "<ITERATOR>"
+}
+
+// JSON returns a json-friendly string
+func (it *Iterator) JSON(indent bool) string {
+	return `"<iterator>"`
+}
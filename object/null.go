@@ -3,6 +3,9 @@ package object
 // Null wraps nothing and implements our Object interface.
 type Null struct{}
 
+// NULL is the one null there is, so null can be compared by pointer.
+var NULL = &Null{}
+
 // Type returns the type of this object.
 func (n *Null) Type() Type {
 	return NULL_OBJ
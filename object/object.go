@@ -14,10 +14,13 @@ const (
 	FILE_OBJ         = "FILE"
 	FLOAT_OBJ        = "FLOAT"
 	FUNCTION_OBJ     = "FUNCTION"
+	GENERATOR_OBJ    = "GENERATOR"
 	HASH_OBJ         = "HASH"
 	INTEGER_OBJ      = "INTEGER"
+	ITERATOR_OBJ     = "ITERATOR"
 	MODULE_OBJ       = "MODULE"
 	NULL_OBJ         = "NULL"
+	RANGE_OBJ        = "RANGE"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
 	STRING_OBJ       = "STRING"
 )
@@ -32,10 +35,13 @@ var SystemTypesMap = map[Type]Object{
 	FILE_OBJ:         &File{},
 	FLOAT_OBJ:        &Float{},
 	FUNCTION_OBJ:     &Function{},
+	GENERATOR_OBJ:    &Generator{},
 	HASH_OBJ:         &Hash{},
 	INTEGER_OBJ:      &Integer{},
+	ITERATOR_OBJ:     &Iterator{},
 	MODULE_OBJ:       &Module{},
 	NULL_OBJ:         &Null{},
+	RANGE_OBJ:        &Range{},
 	RETURN_VALUE_OBJ: &ReturnValue{},
 	STRING_OBJ:       &String{},
 }
@@ -86,3 +92,10 @@ type Iterable interface {
 	// items are available.
 	Next() (Object, Object, bool)
 }
+
+// Stopper is implemented by iterables that need to be told when nothing
+// more will be taken from them, so they can clean up.
+type Stopper interface {
+	// Stop ends the iteration early.
+	Stop()
+}
@@ -1,6 +1,10 @@
 package object
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/zautumnz/keai/ast"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -17,3 +21,139 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("string with different have same hash key")
 	}
 }
+
+// hash builds a Hash with string keys.
+func hash(pairs ...interface{}) *Hash {
+	h := &Hash{Pairs: map[HashKey]HashPair{}}
+	for i := 0; i < len(pairs); i += 2 {
+		k := &String{Value: pairs[i].(string)}
+		h.Pairs[k.HashKey()] = HashPair{Key: k, Value: pairs[i+1].(Object)}
+	}
+	return h
+}
+
+func TestEqual(t *testing.T) {
+	env := NewEnvironment()
+	body := &ast.BlockStatement{}
+	one, uno := 1, 1
+	tests := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{&Integer{Value: 1}, &Integer{Value: 1}, true},
+		{&Integer{Value: 1}, &Float{Value: 1}, false},
+		{&String{Value: "a"}, &String{Value: "a"}, true},
+		{&Null{}, &Null{}, true},
+		{
+			&Array{Elements: []Object{&Integer{Value: 1}, hash("a", &Null{})}},
+			&Array{Elements: []Object{&Integer{Value: 1}, hash("a", &Null{})}},
+			true,
+		},
+		{
+			&Array{Elements: []Object{&Integer{Value: 1}}},
+			&Array{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}}},
+			false,
+		},
+		{
+			hash("a", &Integer{Value: 1}, "b", &String{Value: "x"}),
+			hash("b", &String{Value: "x"}, "a", &Integer{Value: 1}),
+			true,
+		},
+		{hash("a", &Integer{Value: 1}), hash("a", &Integer{Value: 2}), false},
+		{hash("a", &Integer{Value: 1}), hash("b", &Integer{Value: 1}), false},
+		{
+			&Function{Env: env, Body: body},
+			&Function{Env: env, Body: body},
+			true,
+		},
+		{
+			&Function{Env: env, Body: body},
+			&Function{Env: NewEnvironment(), Body: body},
+			false,
+		},
+		{NewRange(0, 3), NewRange(0, 3), true},
+		{NewRange(0, 3), NewRange(3, 0), false},
+		{
+			&Range{Start: 0, End: 6, Step: 2},
+			&Range{Start: 0, End: 7, Step: 2},
+			true,
+		},
+		{NewRange(0, 3), &Array{}, false},
+		{&Error{Message: "a"}, &Error{Message: "a"}, true},
+		{&Error{Message: "a"}, &Error{Message: "b"}, false},
+		{
+			&Error{Message: "a", Code: &one},
+			&Error{Message: "a", Code: &uno},
+			true,
+		},
+		{&Error{Message: "a", Code: &one}, &Error{Message: "a"}, false},
+		{&Error{Message: "a", Kind: "x"}, &Error{Message: "a"}, false},
+		{
+			&Error{Message: "a", Cause: &Error{Message: "b"}},
+			&Error{Message: "a", Cause: &Error{Message: "c"}},
+			false,
+		},
+	}
+
+	for i, tt := range tests {
+		if Equal(tt.a, tt.b) != tt.expected {
+			t.Errorf("test %d: Equal(%s, %s) should be %t",
+				i, tt.a.Inspect(), tt.b.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestErrorJSON(t *testing.T) {
+	code := 2
+	err := &Error{
+		Message: "bad",
+		Kind:    "invalid",
+		Code:    &code,
+		Data:    hash("field", &String{Value: "name"}),
+		Cause:   &Error{Message: "empty"},
+	}
+	expected := `{"error":"bad","kind":"invalid","code":2,` +
+		`"data":{"field": "name"},"cause":{"error":"empty"}}`
+	if err.JSON(false) != expected {
+		t.Errorf("expected %s, got %s", expected, err.JSON(false))
+	}
+}
+
+func TestDiff(t *testing.T) {
+	expected := hash(
+		"name", &String{Value: "keai"},
+		"tags", &Array{Elements: []Object{
+			&String{Value: "a"},
+			&String{Value: "b"},
+		}},
+		"gone", &Boolean{Value: true},
+	)
+	actual := hash(
+		"name", &String{Value: "keai"},
+		"tags", &Array{Elements: []Object{&String{Value: "c"}}},
+		"new", &Integer{Value: 1},
+	)
+
+	diff := Diff(expected, actual, false)
+	want := `- expected
++ actual
+  {
+-     "gone": true,
+      "name": "keai",
++     "new": 1,
+      "tags": [
+-         "a",
++         "c",
+-         "b",
+      ],
+  }`
+	if diff != want {
+		t.Errorf("wrong diff. expected:\n%s\ngot:\n%s", want, diff)
+	}
+
+	if d := Diff(&Integer{Value: 1}, &Integer{Value: 2}, true); d !=
+		"\u001b[31m- expected\u001b[0m\n\u001b[32m+ actual\u001b[0m\n"+
+			"\u001b[31m- 1\u001b[0m\n\u001b[32m+ 2\u001b[0m" {
+		t.Errorf("wrong colored diff. got=%q", d)
+	}
+}
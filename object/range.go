@@ -0,0 +1,130 @@
+package object
+
+// Range is the integers from Start to End, inclusive, Step apart. It's
+// what `a..b` evaluates to, and works out its elements as they're needed
+// instead of holding them all.
+type Range struct {
+	Start int64
+	End   int64
+
+	// Step is negative for a range that counts down, like 10..1
+	Step int64
+
+	// offset holds our iteration-offset
+	offset int64
+}
+
+// NewRange returns the range from start to end, counting down if end is
+// less than start.
+func NewRange(start, end int64) *Range {
+	if end < start {
+		return &Range{Start: start, End: end, Step: -1}
+	}
+	return &Range{Start: start, End: end, Step: 1}
+}
+
+// Len returns how many integers are in the range.
+func (r *Range) Len() int64 {
+	if r.Step > 0 && r.End >= r.Start {
+		return (r.End-r.Start)/r.Step + 1
+	}
+	if r.Step < 0 && r.End <= r.Start {
+		return (r.Start-r.End)/-r.Step + 1
+	}
+	return 0
+}
+
+// At returns the i'th integer in the range, and false if there isn't one.
+func (r *Range) At(i int64) (int64, bool) {
+	if i < 0 || i >= r.Len() {
+		return 0, false
+	}
+	return r.Start + i*r.Step, true
+}
+
+// Array returns all the integers in the range.
+func (r *Range) Array() *Array {
+	elements := make([]Object, r.Len())
+	for i := range elements {
+		n, _ := r.At(int64(i))
+		elements[i] = &Integer{Value: n}
+	}
+	return &Array{Elements: elements}
+}
+
+// Type returns the type of this object.
+func (r *Range) Type() Type {
+	return RANGE_OBJ
+}
+
+// Inspect returns a string-representation of the given object. That's
+// its elements, like an array's, since ranges used to be arrays.
+func (r *Range) Inspect() string {
+	return r.Array().Inspect()
+}
+
+// GetMethod returns a method against the object.
+// (Built-in methods only.)
+func (r *Range) GetMethod(method string) BuiltinFunction {
+	switch method {
+	case "step":
+		return func(env *Environment, args ...Object) Object {
+			if len(args) != 1 {
+				return &Error{Message: "step expects one argument"}
+			}
+			n, ok := args[0].(*Integer)
+			if !ok || n.Value <= 0 {
+				return &Error{
+					Message: "step should be a positive integer, got " +
+						args[0].Inspect(),
+				}
+			}
+			step := n.Value
+			if r.Step < 0 {
+				step = -step
+			}
+			return &Range{Start: r.Start, End: r.End, Step: step}
+		}
+	case "reverse":
+		return func(env *Environment, args ...Object) Object {
+			if r.Len() == 0 {
+				return &Range{Start: r.End, End: r.Start, Step: -r.Step}
+			}
+			last, _ := r.At(r.Len() - 1)
+			return &Range{Start: last, End: r.Start, Step: -r.Step}
+		}
+	case "to_array":
+		return func(env *Environment, args ...Object) Object {
+			return r.Array()
+		}
+	}
+	return nil
+}
+
+// Reset implements the Iterable interface, and allows the range to be
+// iterated over again.
+func (r *Range) Reset() {
+	r.offset = 0
+}
+
+// Next implements the Iterable interface, and allows the contents
+// of our range to be iterated over.
+func (r *Range) Next() (Object, Object, bool) {
+	n, ok := r.At(r.offset)
+	if !ok {
+		return nil, &Integer{Value: 0}, false
+	}
+	r.offset++
+	return &Integer{Value: n}, &Integer{Value: r.offset - 1}, true
+}
+
+// ToInterface converts this object to a go-interface, which will allow
+// it to be used naturally in our sprintf/printf primitives.
+func (r *Range) ToInterface() interface{} {
+	return r.Array().ToInterface()
+}
+
+// JSON returns a json-friendly string
+func (r *Range) JSON(indent bool) string {
+	return r.Array().JSON(indent)
+}
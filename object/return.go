@@ -4,6 +4,9 @@ package object
 type ReturnValue struct {
 	// Value is the object that is to be returned
 	Value Object
+
+	// Try is set when it's an error being returned by `?`
+	Try bool
 }
 
 // Type returns the type of this object.
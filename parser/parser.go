@@ -31,6 +31,7 @@ const (
 	ASSIGN      // =
 	EQUALS      // == or !=
 	LESSGREATER // > or <
+	PIPE        // |>
 	SUM         // + or -
 	PRODUCT     // * or /
 	POWER       // **
@@ -57,6 +58,7 @@ var precedences = map[token.Type]int{
 	token.LT_EQUALS: LESSGREATER,
 	token.GT:        LESSGREATER,
 	token.GT_EQUALS: LESSGREATER,
+	token.PIPE:      PIPE,
 
 	token.PLUS:            SUM,
 	token.PLUS_EQUALS:     SUM,
@@ -73,6 +75,12 @@ var precedences = map[token.Type]int{
 	token.LPAREN:          CALL,
 	token.PERIOD:          CALL,
 	token.LBRACKET:        INDEX,
+	token.QUESTION:        INDEX,
+
+	token.QUESTION_QUESTION: COND,
+	token.QUESTION_PERIOD:   CALL,
+	token.QUESTION_LPAREN:   CALL,
+	token.QUESTION_LBRACKET: INDEX,
 
 	token.BIT_OR:          BIT_OR,
 	token.BIT_XOR:         BIT_XOR,
@@ -82,6 +90,15 @@ var precedences = map[token.Type]int{
 	token.BIT_RIGHT_SHIFT: BIT_SHIFT,
 }
 
+// Precedence returns how tightly an infix operator binds, or LOWEST if it
+// isn't one. keai fmt uses it to work out which parens are needed.
+func Precedence(operator string) int {
+	if p, ok := precedences[token.Type(operator)]; ok {
+		return p
+	}
+	return LOWEST
+}
+
 // Parser object
 type Parser struct {
 	// l is our lexer
@@ -111,6 +128,10 @@ type Parser struct {
 	// postfixParseFns holds a map of parsing methods for
 	// postfix-based syntax.
 	postfixParseFns map[token.Type]postfixParseFn
+
+	// noArrow is set while parsing the pattern or guard of a match arm,
+	// where => ends it instead of making a function
+	noArrow bool
 }
 
 // New returns our new parser-object.
@@ -143,8 +164,12 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.NULL, p.parseNull)
 	p.registerPrefix(token.STRING, p.ParseStringLiteral)
 	p.registerPrefix(token.DOCSTRING, p.parseDocStringLiteral)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
 	p.registerPrefix(token.TRUE, p.ParseBoolean)
+	p.registerPrefix(token.YIELD, p.parseYieldExpression)
 	p.registerPrefix(token.BIT_NOT, p.parsePrefixExpression)
+	p.registerPrefix(token.BIT_OR, p.parseBarFunction)
+	p.registerPrefix(token.OR, p.parseBarFunction)
 
 	// Register infix functions
 	p.infixParseFns = make(map[token.Type]infixParseFn)
@@ -166,9 +191,15 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.OR, p.parseInfixExpression)
 	p.registerInfix(token.PERIOD, p.parseIndexDotExpression)
+	p.registerInfix(token.PIPE, p.parsePipeExpression)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.PLUS_EQUALS, p.parseAssignExpression)
 	p.registerInfix(token.POW, p.parseInfixExpression)
+	p.registerInfix(token.QUESTION, p.parseTryExpression)
+	p.registerInfix(token.QUESTION_QUESTION, p.parseInfixExpression)
+	p.registerInfix(token.QUESTION_PERIOD, p.parseIndexDotExpression)
+	p.registerInfix(token.QUESTION_LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.QUESTION_LPAREN, p.parseCallExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.SLASH_EQUALS, p.parseAssignExpression)
 	p.registerInfix(token.BIT_OR, p.parseInfixExpression)
@@ -243,6 +274,8 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseMutableStatement()
 	case token.LET:
 		return p.parseLetStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
 	default:
@@ -253,10 +286,20 @@ func (p *Parser) parseStatement() ast.Statement {
 // parseMutableStatement parses a mutable-statement.
 func (p *Parser) parseMutableStatement() *ast.MutableStatement {
 	stmt := &ast.MutableStatement{Token: p.curToken}
-	if !p.expectPeek(token.IDENT) {
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		stmt.Pattern = p.parsePattern()
+		if stmt.Pattern == nil {
+			return nil
+		}
+	} else if !p.expectPeek(token.IDENT) {
 		return nil
+	} else {
+		stmt.Name = &ast.Identifier{
+			Token: p.curToken,
+			Value: p.curToken.Literal,
+		}
 	}
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
@@ -273,10 +316,43 @@ func (p *Parser) parseMutableStatement() *ast.MutableStatement {
 // parseLetStatement parses a let (constant) declaration.
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	stmt := &ast.LetStatement{Token: p.curToken}
-	if !p.expectPeek(token.IDENT) {
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		stmt.Pattern = p.parsePattern()
+		if stmt.Pattern == nil {
+			return nil
+		}
+	} else if !p.expectPeek(token.IDENT) {
 		return nil
+	} else {
+		stmt.Name = &ast.Identifier{
+			Token: p.curToken,
+			Value: p.curToken.Literal,
+		}
+
+		// `let fs.ls = ...` adds ls to the fs namespace
+		if p.peekTokenIs(token.PERIOD) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			name := stmt.Name
+			name.Value += "." + p.curToken.Literal
+			name.Token.Literal = name.Value
+			name.Token.EndLine = p.curToken.EndLine
+			name.Token.EndColumn = p.curToken.EndColumn
+
+			if p.peekTokenIs(token.PERIOD) {
+				p.errors = append(p.errors, fmt.Sprintf(
+					"let can only add to a namespace one level deep, "+
+						"like `let %s = ...`, around line %d",
+					name.Value,
+					p.l.GetLine(),
+				))
+				return nil
+			}
+		}
 	}
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
@@ -290,6 +366,20 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
+// parseExportStatement parses an exported let declaration.
+func (p *Parser) parseExportStatement() *ast.ExportStatement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+	if !p.expectPeek(token.LET) {
+		return nil
+	}
+	stmt.Statement = p.parseLetStatement()
+	if stmt.Statement == nil {
+		return nil
+	}
+
+	return stmt
+}
+
 // parseReturnStatement parses a return-statement.
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
@@ -353,9 +443,15 @@ func (p *Parser) parsingBroken() ast.Expression {
 	return nil
 }
 
-// parseIdentifier parses an identifier.
+// parseIdentifier parses an identifier, or a short function like
+// `x => x * 2` if one starts with it.
 func (p *Parser) parseIdentifier() ast.Expression {
-	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if p.peekTokenIs(token.ARROW) && !p.noArrow {
+		p.nextToken()
+		return p.parseShortFunction(p.curToken, []*ast.Identifier{ident})
+	}
+	return ident
 }
 
 // ParseIntegerLiteral parses an integer literal.
@@ -433,6 +529,11 @@ func (p *Parser) parsePostfixExpression() ast.Expression {
 	return expression
 }
 
+// parseTryExpression parses `value?`.
+func (p *Parser) parseTryExpression(value ast.Expression) ast.Expression {
+	return &ast.TryExpression{Token: p.curToken, Value: value}
+}
+
 // parseInfixExpression parses an infix-based expression.
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	expression := &ast.InfixExpression{
@@ -449,6 +550,10 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 
 // parseGroupedExpression parses a grouped-expression.
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	if p.peekTokenIs(token.RPAREN) {
+		return p.parseEmptyParens()
+	}
+	defer p.nested()()
 	p.nextToken()
 
 	exp := p.parseExpression(LOWEST)
@@ -459,6 +564,25 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 	return exp
 }
 
+// parseEmptyParens reports `()`, which isn't an expression. `() => x`
+// gets its own error, since short functions without arguments are
+// written `|| x`; its body is skipped so that's the only error.
+func (p *Parser) parseEmptyParens() ast.Expression {
+	p.nextToken()
+	if !p.peekTokenIs(token.ARROW) || p.noArrow {
+		p.noPrefixParseFnError(token.RPAREN)
+		return nil
+	}
+	p.errors = append(p.errors, fmt.Sprintf(
+		"a short function without arguments is written `|| x`, "+
+			"not `() => x`, around line %d",
+		p.l.GetLine(),
+	))
+	p.nextToken()
+	p.parseShortFunction(p.curToken, nil)
+	return nil
+}
+
 // parseIfCondition parses an if-expression.
 func (p *Parser) parseIfExpression() ast.Expression {
 	expression := &ast.IfExpression{Token: p.curToken}
@@ -594,12 +718,19 @@ func (p *Parser) parseForLoopExpression() ast.Expression {
 func (p *Parser) parseForEach() ast.Expression {
 	expression := &ast.ForeachStatement{Token: p.curToken}
 
-	// get the id
+	// get the id, or a pattern to destructure each item with
 	p.nextToken()
-	expression.Ident = p.curToken.Literal
+	if p.curTokenIs(token.LBRACKET) || p.curTokenIs(token.LBRACE) {
+		expression.Pattern = p.parsePattern()
+		if expression.Pattern == nil {
+			return nil
+		}
+	} else {
+		expression.Ident = p.curToken.Literal
+	}
 
 	// If we find a "," we then get a second identifier too.
-	if p.peekTokenIs(token.COMMA) {
+	if expression.Pattern == nil && p.peekTokenIs(token.COMMA) {
 		// Generally we have:
 		//    foreach IDENT in THING { .. }
 		// If we have two arguments the first becomes
@@ -608,21 +739,31 @@ func (p *Parser) parseForEach() ast.Expression {
 		// skip the comma
 		p.nextToken()
 
-		if !p.peekTokenIs(token.IDENT) {
+		if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+			p.nextToken()
+			expression.Pattern = p.parsePattern()
+			if expression.Pattern == nil {
+				return nil
+			}
+			expression.Index = expression.Ident
+			expression.Ident = ""
+		} else if !p.peekTokenIs(token.IDENT) {
 			p.errors = append(
 				p.errors,
 				fmt.Sprintf(
-					"second argument to foreach must be ident, got %v",
+					"second argument to foreach must be ident or pattern, "+
+						"got %v",
 					p.peekToken,
 				),
 			)
 			return nil
-		}
-		p.nextToken()
+		} else {
+			p.nextToken()
 
-		// Record the updated values.
-		expression.Index = expression.Ident
-		expression.Ident = p.curToken.Literal
+			// Record the updated values.
+			expression.Index = expression.Ident
+			expression.Ident = p.curToken.Literal
+		}
 
 	}
 
@@ -694,6 +835,7 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		block.Statements = append(block.Statements, stmt)
 		p.nextToken()
 	}
+	block.Rbrace = p.curToken
 	return block
 }
 
@@ -703,7 +845,7 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
-	lit.Defaults, lit.Parameters = p.parseFunctionParameters()
+	lit.Defaults, lit.Parameters, lit.Patterns = p.parseFunctionParameters()
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
@@ -715,9 +857,82 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		}
 	}
 	lit.Body = p.parseBlockStatement()
+	lit.Generator = yields(lit.Body)
 	return lit
 }
 
+// parseBarFunction parses a short function like `|x, y| x + y`, or
+// `|| x` with no parameters.
+func (p *Parser) parseBarFunction() ast.Expression {
+	tok := p.curToken
+	params := []*ast.Identifier{}
+	if tok.Type == token.BIT_OR {
+		for !p.peekTokenIs(token.BIT_OR) {
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			params = append(params, &ast.Identifier{
+				Token: p.curToken,
+				Value: p.curToken.Literal,
+			})
+			if !p.peekTokenIs(token.BIT_OR) && !p.expectPeek(token.COMMA) {
+				return nil
+			}
+		}
+		p.nextToken()
+	}
+	return p.parseShortFunction(tok, params)
+}
+
+// parseShortFunction parses the body of a short function, which is one
+// expression, and makes it a function literal. tok is the => or the first
+// | and is kept so keai fmt can write the function the same way.
+func (p *Parser) parseShortFunction(
+	tok token.Token,
+	params []*ast.Identifier,
+) ast.Expression {
+	p.nextToken()
+	stmt := &ast.ExpressionStatement{Token: p.curToken}
+	stmt.Expression = p.parseExpression(LOWEST)
+	if stmt.Expression == nil {
+		return nil
+	}
+	body := &ast.BlockStatement{
+		Token:      stmt.Token,
+		Statements: []ast.Statement{stmt},
+	}
+	return &ast.FunctionLiteral{
+		Token:      tok,
+		Parameters: params,
+		Body:       body,
+		Generator:  yields(body),
+	}
+}
+
+// yields returns true if a function body has a yield in it. Yielding in a
+// function inside the body doesn't count.
+func yields(body *ast.BlockStatement) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.YieldExpression:
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// parseYieldExpression parses `yield value`.
+func (p *Parser) parseYieldExpression() ast.Expression {
+	exp := &ast.YieldExpression{Token: p.curToken}
+	p.nextToken()
+	exp.Value = p.parseExpression(LOWEST)
+	return exp
+}
+
 // ...
 func (p *Parser) parseCurrentArgsLiteral() ast.Expression {
 	return &ast.CurrentArgsLiteral{Token: p.curToken}
@@ -735,6 +950,7 @@ func (p *Parser) parseSpreadLiteral() ast.Expression {
 func (p *Parser) parseFunctionParameters() (
 	map[string]ast.Expression,
 	[]*ast.Identifier,
+	map[string]ast.Expression,
 ) {
 	// Any default parameters.
 	m := make(map[string]ast.Expression)
@@ -742,10 +958,13 @@ func (p *Parser) parseFunctionParameters() (
 	// The argument-definitions.
 	identifiers := make([]*ast.Identifier, 0)
 
+	// Any parameters that destructure their argument.
+	patterns := make(map[string]ast.Expression)
+
 	// Is the next parameter ")" ?  If so we're done. No args.
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return m, identifiers
+		return m, identifiers, patterns
 	}
 	p.nextToken()
 
@@ -753,11 +972,20 @@ func (p *Parser) parseFunctionParameters() (
 	for !p.curTokenIs(token.RPAREN) {
 		if p.curTokenIs(token.EOF) {
 			p.errors = append(p.errors, "unterminated function parameters")
-			return nil, nil
+			return nil, nil, nil
 		}
 
-		// Get the identifier.
+		// Get the identifier. A pattern is named after itself, which
+		// can't clash with a real name.
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if p.curTokenIs(token.LBRACKET) || p.curTokenIs(token.LBRACE) {
+			pattern := p.parsePattern()
+			if pattern == nil {
+				return nil, nil, nil
+			}
+			ident.Value = pattern.String()
+			patterns[ident.Value] = pattern
+		}
 		identifiers = append(identifiers, ident)
 		p.nextToken()
 
@@ -776,7 +1004,249 @@ func (p *Parser) parseFunctionParameters() (
 		}
 	}
 
-	return m, identifiers
+	return m, identifiers, patterns
+}
+
+// parseMatchExpression parses `match value { pattern if guard => result }`.
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: p.curToken}
+	p.nextToken()
+	expression.Value = p.parseExpression(LOWEST)
+	if expression.Value == nil || !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if p.peekTokenIs(token.EOF) {
+			p.errors = append(p.errors, "unterminated match expression")
+			return nil
+		}
+		p.nextToken()
+		p.noArrow = true
+		arm := &ast.MatchArm{Pattern: p.parseMatchPattern()}
+		if arm.Pattern != nil && p.peekTokenIs(token.IF) {
+			p.nextToken()
+			p.nextToken()
+			arm.Guard = p.parseExpression(LOWEST)
+		}
+		p.noArrow = false
+		if arm.Pattern == nil || !p.expectPeek(token.ARROW) {
+			return nil
+		}
+		if p.peekTokenIs(token.LBRACE) {
+			p.nextToken()
+			arm.Body = p.parseBlockStatement()
+		} else {
+			arm.Body = p.parseBlockStatementWithoutBraces()
+		}
+		if arm.Body == nil {
+			return nil
+		}
+		expression.Arms = append(expression.Arms, arm)
+
+		if p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+		}
+	}
+	p.nextToken()
+	expression.Rbrace = p.curToken
+	return expression
+}
+
+// nested clears noArrow while parsing what's between brackets, where => is
+// always a short function, even in the guard of a match arm. It returns a
+// function that puts noArrow back.
+func (p *Parser) nested() func() {
+	noArrow := p.noArrow
+	p.noArrow = false
+	return func() {
+		p.noArrow = noArrow
+	}
+}
+
+// parseMatchPattern parses the pattern of an arm of a match.
+func (p *Parser) parseMatchPattern() ast.Expression {
+	switch p.curToken.Type {
+	case token.LBRACKET:
+		return p.parseArrayPattern(p.parseMatchPattern)
+	case token.LBRACE:
+		return p.parseHashPattern(p.parseMatchPattern)
+	}
+
+	pattern := p.parseExpression(LOWEST)
+
+	// .. binds tighter than -, but -1..5 is meant as a range
+	if neg, ok := pattern.(*ast.PrefixExpression); ok && neg.Operator == "-" {
+		if r, ok := neg.Right.(*ast.InfixExpression); ok && r.Operator == ".." {
+			neg.Right = r.Left
+			r.Left = neg
+			pattern = r
+		}
+	}
+
+	if !isMatchPattern(pattern) {
+		got := p.curToken.Literal
+		if pattern != nil {
+			got = pattern.String()
+		}
+		p.errors = append(p.errors, fmt.Sprintf(
+			"expected a pattern, got %s instead around line %d",
+			got,
+			p.l.GetLine(),
+		))
+		return nil
+	}
+	return pattern
+}
+
+// isMatchPattern returns true for expressions that can be the pattern of a
+// match arm, other than array and hash patterns: names, literals, and
+// ranges of numbers.
+func isMatchPattern(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.Identifier, *ast.StringLiteral, *ast.Boolean, *ast.NullLiteral:
+		return true
+	case *ast.InfixExpression:
+		return e.Operator == ".." && isNumber(e.Left) && isNumber(e.Right)
+	}
+	return isNumber(e)
+}
+
+// isNumber returns true for number literals, including negative ones.
+func isNumber(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return e.Operator == "-" && isNumber(e.Right)
+	}
+	return false
+}
+
+// parsePattern parses a destructuring pattern, starting at its '[' or '{'.
+func (p *Parser) parsePattern() ast.Expression {
+	if p.curTokenIs(token.LBRACE) {
+		return p.parseHashPattern(p.parsePatternTarget)
+	}
+	return p.parseArrayPattern(p.parsePatternTarget)
+}
+
+// parsePatternTarget parses what a pattern binds a value to: a name or a
+// nested pattern.
+func (p *Parser) parsePatternTarget() ast.Expression {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	case token.LBRACKET, token.LBRACE:
+		return p.parsePattern()
+	}
+	p.errors = append(p.errors, fmt.Sprintf(
+		"expected a name or pattern, got %s instead around line %d",
+		p.curToken.Type,
+		p.l.GetLine(),
+	))
+	return nil
+}
+
+// parsePatternDefault parses the `= value` after a name in a pattern, if
+// it's there.
+func (p *Parser) parsePatternDefault(e *ast.PatternElement) {
+	if p.peekTokenIs(token.ASSIGN) {
+		p.nextToken()
+		p.nextToken()
+		e.Default = p.parseExpression(LOWEST)
+	}
+}
+
+// parsePatternEnd moves past the comma after an element of a pattern,
+// returning false if there's no comma because the pattern is done.
+func (p *Parser) parsePatternEnd(end token.Type) bool {
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		return !p.peekTokenIs(end)
+	}
+	return false
+}
+
+// parseArrayPattern parses `[a, b = 2, ...rest]`, with target parsing
+// each element.
+func (p *Parser) parseArrayPattern(
+	target func() ast.Expression,
+) ast.Expression {
+	pattern := &ast.ArrayPattern{Token: p.curToken}
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		if p.curTokenIs(token.CURRENT_ARGS) {
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			pattern.Rest = &ast.Identifier{
+				Token: p.curToken,
+				Value: p.curToken.Literal,
+			}
+			break
+		}
+
+		e := &ast.PatternElement{Target: target()}
+		if e.Target == nil {
+			return nil
+		}
+		p.parsePatternDefault(e)
+		pattern.Elements = append(pattern.Elements, e)
+		if !p.parsePatternEnd(token.RBRACKET) {
+			break
+		}
+	}
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return pattern
+}
+
+// parseHashPattern parses `{name, age: years, "content-type": type}`,
+// with target parsing what's after each colon.
+func (p *Parser) parseHashPattern(
+	target func() ast.Expression,
+) ast.Expression {
+	pattern := &ast.HashPattern{Token: p.curToken}
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		if !p.curTokenIs(token.IDENT) && !p.curTokenIs(token.STRING) {
+			p.errors = append(p.errors, fmt.Sprintf(
+				"expected a key in hash pattern, got %s instead around line %d",
+				p.curToken.Type,
+				p.l.GetLine(),
+			))
+			return nil
+		}
+
+		e := &ast.PatternElement{Key: p.curToken.Literal}
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			e.Target = target()
+			if e.Target == nil {
+				return nil
+			}
+		} else if p.curTokenIs(token.IDENT) {
+			e.Target = &ast.Identifier{
+				Token: p.curToken,
+				Value: p.curToken.Literal,
+			}
+		} else {
+			p.peekError(token.COLON)
+			return nil
+		}
+		p.parsePatternDefault(e)
+		pattern.Pairs = append(pattern.Pairs, e)
+		if !p.parsePatternEnd(token.RBRACE) {
+			break
+		}
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	return pattern
 }
 
 // ParseStringLiteral parses a string-literal.
@@ -798,11 +1268,13 @@ func (p *Parser) parseDocStringLiteral() ast.Expression {
 func (p *Parser) ParseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Rbracket = p.curToken
 	return array
 }
 
 // parsearray elements literal
 func (p *Parser) parseExpressionList(end token.Type) []ast.Expression {
+	defer p.nested()()
 	list := make([]ast.Expression, 0)
 	if p.peekTokenIs(end) {
 		p.nextToken()
@@ -812,6 +1284,11 @@ func (p *Parser) parseExpressionList(end token.Type) []ast.Expression {
 	list = append(list, p.parseExpression(LOWEST))
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
+
+		// allow a trailing comma
+		if p.peekTokenIs(end) {
+			break
+		}
 		p.nextToken()
 		list = append(list, p.parseExpression(LOWEST))
 	}
@@ -821,11 +1298,46 @@ func (p *Parser) parseExpressionList(end token.Type) []ast.Expression {
 	return list
 }
 
-// parseIndexExpression parses an array index expression.
+// parseIndexExpression parses an array index expression, or a slice.
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	defer p.nested()()
+	tok := p.curToken
 	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+	if p.curTokenIs(token.COLON) {
+		return p.parseSliceExpression(tok, left, nil)
+	}
+	index := p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		return p.parseSliceExpression(tok, left, index)
+	}
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return &ast.IndexExpression{Token: tok, Left: left, Index: index}
+}
+
+// parseSliceExpression parses the rest of a slice, like xs[1:3] or
+// xs[::2], from its first colon.
+func (p *Parser) parseSliceExpression(
+	tok token.Token,
+	left, start ast.Expression,
+) ast.Expression {
+	exp := &ast.SliceExpression{Token: tok, Left: left, Start: start}
+
+	// an expression, unless it's been left out
+	part := func() ast.Expression {
+		if p.peekTokenIs(token.COLON) || p.peekTokenIs(token.RBRACKET) {
+			return nil
+		}
+		p.nextToken()
+		return p.parseExpression(LOWEST)
+	}
+	exp.End = part()
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		exp.Step = part()
+	}
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
@@ -835,6 +1347,27 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 // parseAssignExpression parses a bare assignment, without a `mutable` or `let`
 func (p *Parser) parseAssignExpression(name ast.Expression) ast.Expression {
 	stmt := &ast.AssignStatement{Token: p.curToken}
+	if target, ok := name.(*ast.IndexExpression); ok {
+		// assigning to an element: find the variable it's in
+		stmt.Target = target
+		name = target.Left
+		optional := ast.Optional(target)
+		for {
+			index, ok := name.(*ast.IndexExpression)
+			if !ok {
+				break
+			}
+			optional = optional || ast.Optional(index)
+			name = index.Left
+		}
+		if optional {
+			p.errors = append(p.errors, fmt.Sprintf(
+				"can't assign to %s, which uses ?., around line %d",
+				target,
+				p.l.GetLine(),
+			))
+		}
+	}
 	if n, ok := name.(*ast.Identifier); ok {
 		stmt.Name = n
 	} else {
@@ -874,11 +1407,13 @@ func (p *Parser) parseAssignExpression(name ast.Expression) ast.Expression {
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Rparen = p.curToken
 	return exp
 }
 
 // ParseHashLiteral parses a hash literal.
 func (p *Parser) ParseHashLiteral() ast.Expression {
+	defer p.nested()()
 	hash := &ast.HashLiteral{Token: p.curToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
 	for !p.peekTokenIs(token.RBRACE) {
@@ -897,24 +1432,44 @@ func (p *Parser) ParseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hash.Rbrace = p.curToken
 	return hash
 }
 
+// parsePipeExpression parses `left |> right`, which calls right with left
+// as its only argument. If right is a call, left goes before its
+// arguments instead.
+func (p *Parser) parsePipeExpression(left ast.Expression) ast.Expression {
+	exp := &ast.CallExpression{
+		Token:     p.curToken,
+		Arguments: []ast.Expression{left},
+	}
+	p.nextToken()
+	right := p.parseExpression(PIPE)
+	if right == nil {
+		return nil
+	}
+	call, ok := right.(*ast.CallExpression)
+	if ok && call.Token.Type == token.LPAREN {
+		exp.Function = call.Function
+		exp.Arguments = append(exp.Arguments, call.Arguments...)
+		exp.Rparen = call.Rparen
+	} else {
+		exp.Function = right
+	}
+	return exp
+}
+
 // parseIndexDotExpression parses an index with DOT separator.
 func (p *Parser) parseIndexDotExpression(obj ast.Expression) ast.Expression {
 	curToken := p.curToken
 	p.nextToken()
-	name := p.parseIdentifier()
+	name := p.curToken
+	name.Type = token.IDENT
 	return &ast.IndexExpression{
 		Token: curToken,
 		Left:  obj,
-		Index: &ast.StringLiteral{
-			Token: token.Token{
-				Type:    token.IDENT,
-				Literal: name.TokenLiteral(),
-			},
-			Value: name.String(),
-		},
+		Index: &ast.StringLiteral{Token: name, Value: name.Literal},
 	}
 }
 
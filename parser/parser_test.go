@@ -73,6 +73,7 @@ func TestLetStatements(t *testing.T) {
 		{"let y = true;", "y", true},
 		{"let foobar=y;", "foobar", "y"},
 		{"let baz = quux", "baz", "quux"},
+		{"let fs.ls = 5", "fs.ls", 5},
 	}
 	for _, tt := range tests {
 		l := lexer.New(tt.input)
@@ -94,6 +95,44 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestExportStatements(t *testing.T) {
+	input := `export let x = 5; let y = 1`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d",
+			len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ExportStatement)
+	if !ok {
+		t.Fatalf("s not *ast.ExportStatement. got=%T", program.Statements[0])
+	}
+	if !testLetStatement(t, stmt.Statement, "x") {
+		return
+	}
+	if !testLiteralExpression(t, stmt.Statement.Value, 5) {
+		return
+	}
+
+	l = lexer.New("export x = 5")
+	p = New(l)
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Errorf("expected an error exporting a non-let statement")
+	}
+}
+
+func TestLetNestedNamespace(t *testing.T) {
+	p := New(lexer.New("let a.b.c = 1"))
+	p.ParseProgram()
+	expected := "let can only add to a namespace one level deep"
+	if !strings.Contains(strings.Join(p.Errors(), "\n"), expected) {
+		t.Errorf("expected %q in %q", expected, p.Errors())
+	}
+}
+
 func testMutableStatement(t *testing.T, s ast.Statement, name string) bool {
 	if s.TokenLiteral() != "mutable" {
 		t.Errorf("s.TokenLiteral not 'mutable'. got %q", s.TokenLiteral())
@@ -380,6 +419,24 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		expected string
 	}{
 		{"-a * b", "((-a) * b)"},
+		{"a + b.c()? * 2", "(a + (((b[c])())? * 2))"},
+		{"-f(x)?", "(-(f(x))?)"},
+		{"x ?", "(x)?"},
+		{"xs[1:3]", "(xs[1:3])"},
+		{"s[-3:]", "(s[(-3):])"},
+		{"xs[::2] + 1", "((xs[::2]) + 1)"},
+		{"xs[:n - 1][0]", "((xs[:(n - 1)])[0])"},
+		{"x?", "x?"},
+		{"(f()?).y", "((f())?[y])"},
+		{"f()?.y", "(f()?.[y])"},
+		{"a?.b.c()", "((a?.[b])[c])()"},
+		{"a?.[0]?.(1)", "(a?.[0])?.(1)"},
+		{"xs?.[1:]", "(xs?.[1:])"},
+		{`sys.STDOUT.write("x")`, "((sys[STDOUT])[write])(x)"},
+		{"a ?? b ?? c", "((a ?? b) ?? c)"},
+		{"a ?? b == c", "(a ?? (b == c))"},
+		{"ok? ?? x", "(ok? ?? x)"},
+		{"h?.a.b", "((h?.[a])[b])"},
 		{"!-a", "(!(-a))"},
 		{"a+b+c", "((a + b) + c)"},
 		{"a+b-c", "((a + b) - c)"},
@@ -577,6 +634,142 @@ func TestForLoopExpression(t *testing.T) {
 	}
 }
 
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		input     string
+		generator bool
+	}{
+		{"fn () { yield 1 }", true},
+		{"fn (xs) { foreach x in xs { if x { yield x * 2 } } }", true},
+		{"fn () { 1 }", false},
+		// yielding in a function inside doesn't make this one a generator
+		{"fn () { fn () { yield 1 } }", false},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+		if function.Generator != tt.generator {
+			t.Errorf("%s: expected Generator to be %t", tt.input, tt.generator)
+		}
+	}
+
+	p := New(lexer.New("fn () { let x = yield a + 1 }"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if program.String() != "fn() let x = yield (a + 1);" {
+		t.Errorf("wrong String: %s", program.String())
+	}
+}
+
+func TestShortFunctions(t *testing.T) {
+	tests := []struct {
+		input     string
+		expected  string
+		generator bool
+	}{
+		{"x => x * 2", "fn(x) (x * 2)", false},
+		{"|a, b| a + b", "fn(a, b) (a + b)", false},
+		{"|| 42", "fn() 42", false},
+		{"|x| yield x", "fn(x) yield x", true},
+		{"x => y => x + y", "fn(x) fn(y) (x + y)", false},
+		{`x => {"a": x}`, "fn(x) {a:x}", false},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function, ok := stmt.Expression.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("%s: expected a function, got %T", tt.input,
+				stmt.Expression)
+		}
+		if function.String() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected,
+				function.String())
+		}
+		if function.Generator != tt.generator {
+			t.Errorf("%s: expected Generator to be %t", tt.input, tt.generator)
+		}
+	}
+
+	// => ends the pattern or guard of a match arm
+	p := New(lexer.New("match v { x if x => y => y }"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	arm := stmt.Expression.(*ast.MatchExpression).Arms[0]
+	testIdentifier(t, arm.Guard, "x")
+	body := arm.Body.Statements[0].(*ast.ExpressionStatement)
+	if _, ok := body.Expression.(*ast.FunctionLiteral); !ok {
+		t.Errorf("expected the arm to give a function, got %s", body)
+	}
+
+	// but not inside of parens and brackets in one
+	p = New(lexer.New(`match v {
+    n if xs.filter(y => y > n) == [3] => n,
+    n if (f ?? (z => z))(n) => [z => z][0](n),
+}`))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	stmt = program.Statements[0].(*ast.ExpressionStatement)
+	arms := stmt.Expression.(*ast.MatchExpression).Arms
+	guards := []string{
+		"((xs[filter])(fn(y) (y > n)) == [3])",
+		"(f ?? fn(z) z)(n)",
+	}
+	if len(arms) != len(guards) {
+		t.Fatalf("expected %d arms, got %d", len(guards), len(arms))
+	}
+	for i, arm := range arms {
+		if arm.Guard.String() != guards[i] {
+			t.Errorf("expected guard %s, got %s", guards[i], arm.Guard)
+		}
+	}
+}
+
+func TestEmptyArrowFunction(t *testing.T) {
+	p := New(lexer.New("let f = () => 5\nf()"))
+	p.ParseProgram()
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected one error, got %q", errors)
+	}
+	if !strings.Contains(errors[0], "written `|| x`, not `() => x`") {
+		t.Errorf("wrong error: %q", errors[0])
+	}
+}
+
+func TestPipeExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"xs |> f", "f(xs)"},
+		{"xs |> f(1, 2)", "f(xs, 1, 2)"},
+		{"xs |> f |> g(1)", "g(f(xs), 1)"},
+		{"xs\n    |> f\n    |> g", "g(f(xs))"},
+		{"1 + 2 |> f", "f((1 + 2))"},
+		{"xs |> f == 3", "(f(xs) == 3)"},
+		{"xs |> h.f(1)", "(h[f])(xs, 1)"},
+		{"xs |> x => x + 1", "fn(x) (x + 1)(xs)"},
+		{"xs |> (a |> f)", "f(a)(xs)"},
+		{"xs |> f()()", "f()(xs)"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected,
+				program.String())
+		}
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x,y=3){x+y}`
 	l := lexer.New(input)
@@ -653,6 +846,142 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestPatterns(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b, ...rest] = xs", "let [a, b, ...rest] = xs;"},
+		{"let [a = 1, [b, c],] = xs", "let [a = 1, [b, c]] = xs;"},
+		{"let [...all] = xs", "let [...all] = xs;"},
+		{
+			`let {name, age: years = 0, "content-type": t} = h`,
+			`let {name, age: years = 0, "content-type": t} = h;`,
+		},
+		{"mutable {a: [b, c]} = h", "mutable {a: [b, c]} = h;"},
+		{"foreach i, [k, v] in xs { k }", "foreach [k, v] xsk"},
+		{"foreach {name} in xs { name }", "foreach {name} xsname"},
+		{"fn([a, b], {c} = h, d) { a }", "fn([a, b], {c}, d) a"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected,
+				program.String())
+		}
+	}
+
+	program := New(lexer.New("fn([a, b], c) {}")).ParseProgram()
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	fn := stmt.Expression.(*ast.FunctionLiteral)
+	if _, ok := fn.Patterns["[a, b]"].(*ast.ArrayPattern); !ok {
+		t.Errorf("expected [a, b] to be a pattern, got %v", fn.Patterns)
+	}
+
+	for _, input := range []string{
+		"let [a, 1] = xs",
+		"let {1} = h",
+		`let {"a"} = h`,
+		"let [a, ...b, c] = xs",
+	} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%q: expected a parse error", input)
+		}
+	}
+
+	p := New(lexer.New("match x { => 1 }"))
+	p.ParseProgram()
+	expected := "expected a pattern, got => instead"
+	if !strings.Contains(strings.Join(p.Errors(), "\n"), expected) {
+		t.Errorf("expected %q in %q", expected, p.Errors())
+	}
+}
+
+func TestElementAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		name     string
+	}{
+		{"xs[0] = 1", "(xs[0])=1", "xs"},
+		{"h.a.b += 2", "((h[a])[b])+=2", "h"},
+		{`h["k"][i] -= x`, "((h[k])[i])-=x", "h"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		assign, ok := stmt.Expression.(*ast.AssignStatement)
+		if !ok {
+			t.Fatalf("%q: expected an assignment, got %T", tt.input,
+				stmt.Expression)
+		}
+		if assign.String() != tt.expected || assign.Name.Value != tt.name {
+			t.Errorf("%q: expected %q to %s, got %q to %s", tt.input,
+				tt.expected, tt.name, assign.String(), assign.Name.Value)
+		}
+	}
+
+	p := New(lexer.New("f()[0] = 1"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Errorf("expected an error assigning to an element of a call")
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	input := `match x {
+    0 => "zero",
+    -5..-1 => "negative"
+    integer if x > 9 => { "big" }
+    [a, ...rest] => a,
+    {name, age: 1..9} => name
+    _ => null
+}`
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	m, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("expected a match expression, got %T", stmt.Expression)
+	}
+	expected := []string{
+		"0 => zero",
+		"((-5) .. (-1)) => negative",
+		"integer if (x > 9) => big",
+		"[a, ...rest] => a",
+		"{name, age: (1 .. 9)} => name",
+		"_ => null",
+	}
+	if len(m.Arms) != len(expected) {
+		t.Fatalf("expected %d arms, got %d", len(expected), len(m.Arms))
+	}
+	for i, arm := range m.Arms {
+		if arm.String() != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], arm.String())
+		}
+	}
+
+	for _, input := range []string{
+		"match x { f() => 1 }",
+		"match x { 1 2 }",
+		"match x { a..b => 1 }",
+		"match x { 1 => 1",
+	} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%q: expected a parse error", input)
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := `add(1, 2*3, 4+5)`
 	l := lexer.New(input)
@@ -732,6 +1061,24 @@ func TestParsingArrayLiteral(t *testing.T) {
 	testInfixExpression(t, array.Elements[2], 3, "+", 3)
 }
 
+func TestTrailingCommas(t *testing.T) {
+	input := `[1, 2,]; f(1, 2,)`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	array := program.Statements[0].(*ast.ExpressionStatement).
+		Expression.(*ast.ArrayLiteral)
+	if len(array.Elements) != 2 {
+		t.Fatalf("len(array.Elements) not 2. got=%d", len(array.Elements))
+	}
+	call := program.Statements[1].(*ast.ExpressionStatement).
+		Expression.(*ast.CallExpression)
+	if len(call.Arguments) != 2 {
+		t.Fatalf("len(call.Arguments) not 2. got=%d", len(call.Arguments))
+	}
+}
+
 func TestParsingIndexExpression(t *testing.T) {
 	input := "myArray[1+1]"
 	l := lexer.New(input)
@@ -890,6 +1237,16 @@ func TestObjectMethodCall(t *testing.T) {
 	}
 }
 
+func TestAssignToOptionalChain(t *testing.T) {
+	for _, input := range []string{"h?.a = 1", "h?.[0].b += 1"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.errors) != 1 || !strings.Contains(p.errors[0], "?.") {
+			t.Errorf("%s: expected an error about ?., got %v", input, p.errors)
+		}
+	}
+}
+
 func TestIncompleThings(t *testing.T) {
 	input := []string{
 		`if (true) { `,
@@ -0,0 +1,329 @@
+package pkgmgr
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// pending is a dependency waiting to be installed.
+type pending struct {
+	name string
+	spec string
+
+	// base is the directory relative sources are resolved against; the
+	// directory of the manifest that declared the dependency.
+	base string
+}
+
+// Install installs every dependency of the package in root (including
+// dependencies of dependencies) into root/keai_modules, and writes
+// root/keai.lock. Git dependencies already in the lockfile are checked out
+// at their locked commit. Progress is written to out. Everything's
+// installed into a temporary directory first, which only replaces
+// keai_modules once it's all worked, so a failed install leaves the old
+// one alone.
+func Install(root string, out io.Writer) (*Lockfile, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := ReadManifest(root)
+	if err != nil {
+		return nil, err
+	}
+
+	old, err := ReadLockfile(root)
+	if err != nil {
+		return nil, err
+	}
+
+	// it's hidden, so nothing looks in it if we don't get to remove it
+	modules := filepath.Join(root, ModulesDir)
+	tmp, err := ioutil.TempDir(root, "."+ModulesDir+"-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	lock := &Lockfile{Dependencies: map[string]LockedDependency{}}
+	queue := queueDependencies(nil, m, root)
+
+	for len(queue) > 0 {
+		dep := queue[0]
+		queue = queue[1:]
+
+		src, err := ParseSource(dep.spec, dep.base)
+		if err != nil {
+			return nil, fmt.Errorf("dependency '%s': %s", dep.name, err)
+		}
+		source := src.String(root)
+
+		// Everything is installed side by side, so two packages can't
+		// need different copies of the same dependency.
+		if locked, ok := lock.Dependencies[dep.name]; ok {
+			if locked.Source != source {
+				return nil, fmt.Errorf(
+					"dependency '%s' is required from both %s and %s",
+					dep.name,
+					locked.Source,
+					source,
+				)
+			}
+			continue
+		}
+
+		if err := CheckName(dep.name); err != nil {
+			return nil, err
+		}
+		target := filepath.Join(tmp, dep.name)
+		locked := LockedDependency{Source: source}
+		prev, wasLocked := old.Dependencies[dep.name]
+		wasLocked = wasLocked && prev.Source == source
+		if src.Git {
+			commit := ""
+			if wasLocked {
+				commit = prev.Commit
+			}
+			locked.Commit, err = fetchGit(src, commit, target)
+		} else {
+			err = copyDir(staged(src.Location, modules, tmp), target)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("dependency '%s': %s", dep.name, err)
+		}
+
+		locked.Hash, err = HashDir(target)
+		if err != nil {
+			return nil, err
+		}
+		if src.Git && wasLocked && prev.Commit == locked.Commit &&
+			prev.Hash != "" && prev.Hash != locked.Hash {
+			return nil, fmt.Errorf(
+				"dependency '%s' doesn't match the hash in %s",
+				dep.name,
+				LockFile,
+			)
+		}
+
+		// Queue up the dependency's own dependencies. Relative paths in
+		// a local dependency's manifest are relative to where it lives.
+		dm, err := ReadManifest(target)
+		if err == nil {
+			locked.Version = dm.Version
+			base := filepath.Join(modules, dep.name)
+			if !src.Git {
+				base = src.Location
+			}
+			queue = queueDependencies(queue, dm, base)
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("dependency '%s': %s", dep.name, err)
+		}
+
+		lock.Dependencies[dep.name] = locked
+
+		installed := dep.name
+		if locked.Version != "" {
+			installed += "@" + locked.Version
+		}
+		fmt.Fprintf(out, "installed %s from %s\n", installed, source)
+	}
+
+	if err := os.RemoveAll(modules); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, modules); err != nil {
+		return nil, err
+	}
+	if err := lock.Write(root); err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// staged returns where path is while installing: paths in keai_modules,
+// like a dependency a git package has inside it, are still in tmp.
+func staged(path, modules, tmp string) string {
+	rel, err := filepath.Rel(modules, path)
+	if err != nil || !insideDir(rel) {
+		return path
+	}
+	return filepath.Join(tmp, rel)
+}
+
+// queueDependencies appends the dependencies of m, in a stable order.
+func queueDependencies(queue []pending, m *Manifest, base string) []pending {
+	names := []string{}
+	for name := range m.Dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		queue = append(queue, pending{
+			name: name,
+			spec: m.Dependencies[name],
+			base: base,
+		})
+	}
+	return queue
+}
+
+// git runs a git command, returning its trimmed output.
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var outb, errb bytes.Buffer
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf(
+			"git %s failed: %s",
+			strings.Join(args, " "),
+			strings.TrimSpace(errb.String()),
+		)
+	}
+	return strings.TrimSpace(outb.String()), nil
+}
+
+// fetchGit clones src into target and checks out commit if one is given,
+// or the source's ref otherwise. It returns the commit that was checked
+// out. The .git directory is removed afterwards, so only the package's
+// files are left.
+func fetchGit(src Source, commit, target string) (string, error) {
+	ref := commit
+	if ref == "" {
+		ref = src.Ref
+	}
+	// commit comes from the lockfile, which could have been edited
+	if strings.HasPrefix(src.Location, "-") || strings.HasPrefix(ref, "-") {
+		return "", fmt.Errorf("git url and ref can't start with -")
+	}
+
+	_, err := git("", "clone", "--quiet", "--", src.Location, target)
+	if err != nil {
+		return "", err
+	}
+
+	if ref != "" {
+		sha, err := resolve(target, ref)
+		if err != nil {
+			return "", err
+		}
+		if _, err := git(target, "checkout", "--quiet", sha); err != nil {
+			return "", err
+		}
+	}
+
+	head, err := git(target, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	return head, os.RemoveAll(filepath.Join(target, ".git"))
+}
+
+// resolve returns the commit a ref names in the repository in dir,
+// looking at the remote's branches too, since only the default branch is
+// checked out by a clone.
+func resolve(dir, ref string) (string, error) {
+	for _, name := range []string{ref, "refs/remotes/origin/" + ref} {
+		sha, err := git(dir, "rev-parse", "--verify", name+"^{commit}")
+		if err == nil {
+			return sha, nil
+		}
+	}
+	return "", fmt.Errorf("unknown git ref '%s'", ref)
+}
+
+// skipDir returns true for directories that are never part of an installed
+// package.
+func skipDir(name string) bool {
+	return name == ".git" || name == ModulesDir
+}
+
+// copyDir copies the package in src to target. Only regular files are
+// copied, so a symlink can't pull in files from outside the package.
+func copyDir(src, target string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", src)
+	}
+
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && path != src && skipDir(info.Name()) {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(target, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(dest, 0755)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(dest, b, info.Mode().Perm())
+	})
+}
+
+// HashDir returns a hash of every regular file's path and contents under
+// dir, as recorded in the lockfile. Like copyDir, it doesn't follow
+// symlinks.
+func HashDir(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != dir && skipDir(info.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", filepath.ToSlash(rel), len(b))
+		h.Write(b)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return "sha256-" + hex.EncodeToString(h.Sum(nil)), nil
+}
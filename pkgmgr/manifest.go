@@ -0,0 +1,232 @@
+// Package pkgmgr reads keai package manifests and installs their
+// dependencies into a project-local keai_modules directory.
+package pkgmgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// File and directory names used by the package manager
+const (
+	ManifestFile = "keai.json"
+	LockFile     = "keai.lock"
+	ModulesDir   = "keai_modules"
+)
+
+// Manifest describes a keai package; it's read from keai.json.
+type Manifest struct {
+	// Name is the name the package is imported by
+	Name string `json:"name"`
+
+	// Version is the version of the package
+	Version string `json:"version,omitempty"`
+
+	// Main is the package's entry point, relative to the manifest
+	Main string `json:"main,omitempty"`
+
+	// Dependencies maps package names to sources, see ParseSource
+	Dependencies map[string]string `json:"dependencies,omitempty"`
+}
+
+// Entry returns the entry point of the package, which defaults to
+// main.keai.
+func (m *Manifest) Entry() string {
+	if m.Main != "" {
+		return m.Main
+	}
+	return "main.keai"
+}
+
+// ReadManifest reads the keai.json in dir.
+func ReadManifest(dir string) (*Manifest, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %s", ManifestFile, err)
+	}
+	if m.Main != "" && !insideDir(m.Main) {
+		return nil, fmt.Errorf(
+			"error parsing %s: main '%s' isn't inside the package",
+			ManifestFile,
+			m.Main,
+		)
+	}
+	return m, nil
+}
+
+// insideDir returns true if path is relative and doesn't go up out of the
+// directory it's relative to.
+func insideDir(path string) bool {
+	if filepath.IsAbs(path) || strings.HasPrefix(path, "/") ||
+		strings.HasPrefix(path, `\`) || filepath.VolumeName(path) != "" {
+		return false
+	}
+	path = filepath.ToSlash(filepath.Clean(path))
+	return path != ".." && !strings.HasPrefix(path, "../")
+}
+
+// CheckName returns an error if a dependency name can't be used as a
+// directory in keai_modules, like ../src, which would be installed
+// somewhere else.
+func CheckName(name string) error {
+	if name == "" || name == "." || strings.Contains(name, "..") ||
+		strings.ContainsAny(name, `/\`) ||
+		strings.ContainsRune(name, filepath.Separator) {
+		return fmt.Errorf("invalid dependency name '%s'", name)
+	}
+	return nil
+}
+
+// FindProjectRoot returns the closest directory at or above dir that
+// contains a keai.json, or an empty string if there isn't one.
+func FindProjectRoot(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ManifestFile)); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Source is where a dependency is installed from.
+type Source struct {
+	// Git is true for git repositories, and false for local directories
+	Git bool
+
+	// Location is the directory or git URL
+	Location string
+
+	// Ref is an optional git branch, tag, or commit
+	Ref string
+}
+
+// ParseSource parses a dependency source as written in a manifest.
+// Local directories are written as a path starting with `.` or `/`, or
+// with a `file:` prefix; relative paths are relative to base. Git
+// repositories use a `git+` prefix on their URL, followed by an optional
+// `#ref`, for example `git+https://example.com/colors.git#v1.0.0` or
+// `git+file:///srv/git/colors.git`.
+func ParseSource(spec, base string) (Source, error) {
+	switch {
+	case strings.HasPrefix(spec, "git+"):
+		url := strings.TrimPrefix(spec, "git+")
+		ref := ""
+		if i := strings.LastIndex(url, "#"); i != -1 {
+			url, ref = url[:i], url[i+1:]
+		}
+		if url == "" {
+			return Source{}, fmt.Errorf("missing git url in '%s'", spec)
+		}
+
+		// these go to git, which would take them as options
+		if strings.HasPrefix(url, "-") || strings.HasPrefix(ref, "-") {
+			return Source{}, fmt.Errorf(
+				"git url and ref can't start with - in '%s'",
+				spec,
+			)
+		}
+		return Source{Git: true, Location: url, Ref: ref}, nil
+
+	case strings.HasPrefix(spec, "file:"),
+		strings.HasPrefix(spec, "."),
+		strings.HasPrefix(spec, "/"):
+		path := strings.TrimPrefix(spec, "file:")
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(base, path)
+		}
+		return Source{Location: filepath.Clean(path)}, nil
+	}
+
+	return Source{}, fmt.Errorf(
+		"unknown dependency source '%s'; use a path or a git+ url",
+		spec,
+	)
+}
+
+// String returns the source in manifest syntax. Local paths are made
+// relative to root where possible, so the lockfile stays portable.
+func (s Source) String(root string) string {
+	if s.Git {
+		if s.Ref != "" {
+			return "git+" + s.Location + "#" + s.Ref
+		}
+		return "git+" + s.Location
+	}
+
+	if rel, err := filepath.Rel(root, s.Location); err == nil {
+		return "file:" + filepath.ToSlash(rel)
+	}
+	return "file:" + s.Location
+}
+
+// LockedDependency is a lockfile entry for one installed dependency.
+type LockedDependency struct {
+	// Source is where the dependency was installed from
+	Source string `json:"source"`
+
+	// Version is the version from the dependency's own manifest, if any
+	Version string `json:"version,omitempty"`
+
+	// Commit is the commit git dependencies were checked out at
+	Commit string `json:"commit,omitempty"`
+
+	// Hash is a hash of the installed files, see HashDir. A git
+	// dependency checked out at its locked commit has to match it; local
+	// directories can change, so they're just hashed again.
+	Hash string `json:"hash"`
+}
+
+// Lockfile records exactly what was installed; it's read from keai.lock.
+type Lockfile struct {
+	Dependencies map[string]LockedDependency `json:"dependencies"`
+}
+
+// ReadLockfile reads the keai.lock in dir. A missing lockfile isn't an
+// error, it's just empty.
+func ReadLockfile(dir string) (*Lockfile, error) {
+	lock := &Lockfile{Dependencies: map[string]LockedDependency{}}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, LockFile))
+	if os.IsNotExist(err) {
+		return lock, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, lock); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %s", LockFile, err)
+	}
+	if lock.Dependencies == nil {
+		lock.Dependencies = map[string]LockedDependency{}
+	}
+	return lock, nil
+}
+
+// Write writes the lockfile to dir.
+func (l *Lockfile) Write(dir string) error {
+	b, err := json.MarshalIndent(l, "", "    ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	return ioutil.WriteFile(filepath.Join(dir, LockFile), b, 0644)
+}
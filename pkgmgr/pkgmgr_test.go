@@ -0,0 +1,345 @@
+package pkgmgr
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates files (relative path -> contents) under dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected Source
+	}{
+		{"../colors", Source{Location: "/src/colors"}},
+		{"./colors", Source{Location: "/src/app/colors"}},
+		{"file:vendor/colors", Source{Location: "/src/app/vendor/colors"}},
+		{"/opt/colors", Source{Location: "/opt/colors"}},
+		{
+			"git+https://example.com/colors.git",
+			Source{Git: true, Location: "https://example.com/colors.git"},
+		},
+		{
+			"git+file:///srv/colors.git#v1.0.0",
+			Source{Git: true, Location: "file:///srv/colors.git", Ref: "v1.0.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		src, err := ParseSource(tt.spec, "/src/app")
+		if err != nil {
+			t.Errorf("error parsing %s: %s", tt.spec, err)
+			continue
+		}
+		if src != tt.expected {
+			t.Errorf("wrong source for %s. expected=%+v, got=%+v",
+				tt.spec, tt.expected, src)
+		}
+	}
+
+	for _, spec := range []string{
+		"colors",
+		"git+--upload-pack=touch${IFS}x#y",
+		"git+https://example.com/colors.git#--orphan",
+	} {
+		if _, err := ParseSource(spec, "/src/app"); err == nil {
+			t.Errorf("expected an error for %s", spec)
+		}
+	}
+}
+
+func TestInstallUnsafePaths(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a/main.keai":     `let a = 1`,
+		"name/keai.json":  `{"dependencies": {"../src": "../a"}}`,
+		"main/keai.json":  `{"dependencies": {"b": "../b"}}`,
+		"b/keai.json":     `{"main": "../../x.keai"}`,
+		"b/main.keai":     `let b = 1`,
+		"other/keai.json": `{"main": "/etc/x.keai"}`,
+	})
+
+	_, err := Install(filepath.Join(dir, "name"), ioutil.Discard)
+	if err == nil || !strings.Contains(err.Error(), "invalid dependency name") {
+		t.Errorf("expected an invalid name error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "name/src")); err == nil {
+		t.Errorf("../src shouldn't be installed outside keai_modules")
+	}
+
+	_, err = Install(filepath.Join(dir, "main"), ioutil.Discard)
+	if err == nil || !strings.Contains(err.Error(), "isn't inside the package") {
+		t.Errorf("expected an error for b's main, got %v", err)
+	}
+	if _, err := ReadManifest(filepath.Join(dir, "other")); err == nil {
+		t.Errorf("expected an error for an absolute main")
+	}
+}
+
+func TestInstallLocal(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"app/keai.json": `{
+			"name": "app",
+			"dependencies": {"colors": "../colors"}
+		}`,
+		"colors/keai.json": `{
+			"name": "colors",
+			"version": "1.2.0",
+			"main": "colors.keai",
+			"dependencies": {"shared": "./vendor/shared"}
+		}`,
+		"colors/colors.keai":             `let red = "red"`,
+		"colors/keai_modules/junk.keai":  `let junk = true`,
+		"colors/vendor/shared/main.keai": `let shared = true`,
+	})
+
+	root := filepath.Join(dir, "app")
+	lock, err := Install(root, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []string{
+		"keai_modules/colors/colors.keai",
+		"keai_modules/shared/main.keai",
+		"keai.lock",
+	} {
+		if _, err := os.Stat(filepath.Join(root, f)); err != nil {
+			t.Errorf("expected %s to be installed: %s", f, err)
+		}
+	}
+	junk := filepath.Join(root, "keai_modules/colors/keai_modules")
+	if _, err := os.Stat(junk); err == nil {
+		t.Errorf("nested keai_modules should not be copied")
+	}
+
+	colors := lock.Dependencies["colors"]
+	if colors.Source != "file:../colors" || colors.Version != "1.2.0" {
+		t.Errorf("wrong lock entry for colors: %+v", colors)
+	}
+	if !strings.HasPrefix(colors.Hash, "sha256-") {
+		t.Errorf("expected a content hash, got %q", colors.Hash)
+	}
+	shared := lock.Dependencies["shared"]
+	if shared.Source != "file:../colors/vendor/shared" {
+		t.Errorf("wrong lock entry for shared: %+v", shared)
+	}
+
+	read, err := ReadLockfile(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Dependencies["colors"] != colors {
+		t.Errorf("lockfile round trip failed, got %+v", read.Dependencies)
+	}
+}
+
+func TestInstallFailureKeepsModules(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"app/keai.json":   `{"dependencies": {"dep": "../dep"}}`,
+		"dep/main.keai":   `let dep = true`,
+		"secret.keai":     `let secret = true`,
+		"dep/nested/a.md": `a`,
+	})
+	root := filepath.Join(dir, "app")
+	link := filepath.Join(dir, "dep/secret.keai")
+	if err := os.Symlink(filepath.Join(dir, "secret.keai"), link); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := Install(root, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// symlinks aren't copied or hashed
+	installed := filepath.Join(root, "keai_modules/dep")
+	if _, err := os.Lstat(filepath.Join(installed, "secret.keai")); err == nil {
+		t.Errorf("symlinks should not be copied")
+	}
+	if err := os.Remove(link); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := HashDir(filepath.Join(dir, "dep"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock.Dependencies["dep"].Hash != hash {
+		t.Errorf("symlinks should not be hashed")
+	}
+
+	writeFiles(t, dir, map[string]string{
+		"app/keai.json": `{"dependencies": {"dep": "../dep", "x": "../nope"}}`,
+	})
+	if _, err := Install(root, ioutil.Discard); err == nil {
+		t.Fatalf("expected an error for a missing dependency")
+	}
+	if _, err := os.Stat(filepath.Join(installed, "main.keai")); err != nil {
+		t.Errorf("a failed install removed keai_modules: %s", err)
+	}
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".keai_modules") {
+			t.Errorf("temporary directory %s was left behind", e.Name())
+		}
+	}
+}
+
+func TestInstallConflict(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"app/keai.json": `{"dependencies": {"a": "../a", "shared": "../s1"}}`,
+		"a/keai.json":   `{"dependencies": {"shared": "../s2"}}`,
+		"s1/main.keai":  `let s = 1`,
+		"s2/main.keai":  `let s = 2`,
+	})
+
+	_, err := Install(filepath.Join(dir, "app"), ioutil.Discard)
+	if err == nil || !strings.Contains(err.Error(), "required from both") {
+		t.Errorf("expected a conflict error, got %v", err)
+	}
+}
+
+func TestInstallGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo")
+	writeFiles(t, dir, map[string]string{
+		"repo/main.keai": `let version = 1`,
+		"app/keai.json": `{"dependencies": {"dep": "git+file://` +
+			filepath.ToSlash(repo) + `"}}`,
+	})
+	commit := func() string {
+		args := [][]string{
+			{"add", "."},
+			{"-c", "user.name=keai", "-c", "user.email=keai@example.com",
+				"commit", "--quiet", "-m", "commit"},
+		}
+		for _, a := range args {
+			if _, err := git(repo, a...); err != nil {
+				t.Fatal(err)
+			}
+		}
+		head, err := git(repo, "rev-parse", "HEAD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return head
+	}
+
+	if _, err := git(repo, "init", "--quiet"); err != nil {
+		t.Fatal(err)
+	}
+	first := commit()
+
+	root := filepath.Join(dir, "app")
+	lock, err := Install(root, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock.Dependencies["dep"].Commit != first {
+		t.Errorf("expected commit %s, got %+v", first, lock.Dependencies["dep"])
+	}
+	if _, err := os.Stat(filepath.Join(root, "keai_modules/dep/.git")); err == nil {
+		t.Errorf(".git should be removed from installed packages")
+	}
+
+	// A new upstream commit doesn't change what's installed while the
+	// lockfile pins the old one.
+	writeFiles(t, dir, map[string]string{"repo/main.keai": `let version = 2`})
+	commit()
+
+	lock, err = Install(root, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock.Dependencies["dep"].Commit != first {
+		t.Errorf("expected locked commit %s, got %+v",
+			first, lock.Dependencies["dep"])
+	}
+	b, err := ioutil.ReadFile(filepath.Join(root, "keai_modules/dep/main.keai"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `let version = 1` {
+		t.Errorf("expected the locked version, got %q", b)
+	}
+
+	// The locked commit has to give the locked files.
+	dep := lock.Dependencies["dep"]
+	dep.Hash = "sha256-nope"
+	lock.Dependencies["dep"] = dep
+	if err := lock.Write(root); err != nil {
+		t.Fatal(err)
+	}
+	_, err = Install(root, ioutil.Discard)
+	if err == nil || !strings.Contains(err.Error(), "doesn't match the hash") {
+		t.Errorf("expected a hash mismatch, got %v", err)
+	}
+
+	// Branches that aren't checked out by the clone can be used as refs.
+	if _, err := git(repo, "branch", "next", first); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, dir, map[string]string{
+		"app/keai.json": `{"dependencies": {"dep": "git+file://` +
+			filepath.ToSlash(repo) + `#next"}}`,
+	})
+	lock, err = Install(root, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock.Dependencies["dep"].Commit != first {
+		t.Errorf("expected commit %s for next, got %+v",
+			first, lock.Dependencies["dep"])
+	}
+
+	// Dependencies inside a git package are installed from where it ends
+	// up, not the temporary directory it's installed into first.
+	writeFiles(t, dir, map[string]string{
+		"repo/keai.json":          `{"dependencies": {"v": "./vendor/v"}}`,
+		"repo/vendor/v/main.keai": `let v = true`,
+		"app/keai.json": `{"dependencies": {"dep": "git+file://` +
+			filepath.ToSlash(repo) + `"}}`,
+	})
+	commit()
+	if err := os.Remove(filepath.Join(root, LockFile)); err != nil {
+		t.Fatal(err)
+	}
+	lock, err = Install(root, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := lock.Dependencies["v"].Source
+	if v != "file:keai_modules/dep/vendor/v" {
+		t.Errorf("wrong source for a vendored dependency: %s", v)
+	}
+	_, err = os.Stat(filepath.Join(root, "keai_modules/v/main.keai"))
+	if err != nil {
+		t.Errorf("vendored dependency wasn't installed: %s", err)
+	}
+}
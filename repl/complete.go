@@ -0,0 +1,118 @@
+package repl
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/zautumnz/keai/evaluator"
+	"github.com/zautumnz/keai/object"
+)
+
+// keywords are completed along with names.
+var keywords = []string{
+	"else", "export", "false", "fn", "for", "foreach", "if", "import", "in",
+	"let", "match", "mutable", "null", "return", "true", "yield",
+}
+
+// completer completes names for readline.
+type completer struct {
+	r *repl
+}
+
+// Do returns the rest of each completion of the name before pos, and how
+// long that name is.
+func (c completer) Do(line []rune, pos int) ([][]rune, int) {
+	start := pos
+	for start > 0 && isNameChar(line[start-1]) {
+		start--
+	}
+	prefix := string(line[start:pos])
+
+	var names []string
+	if start == 1 && line[0] == ':' {
+		for _, m := range metaCommands {
+			if strings.HasPrefix(m.name, prefix) {
+				names = append(names, m.name)
+			}
+		}
+	} else {
+		names = c.r.complete(prefix)
+	}
+
+	rest := [][]rune{}
+	for _, name := range names {
+		rest = append(rest, []rune(name[len(prefix):]))
+	}
+	return rest, len([]rune(prefix))
+}
+
+// complete returns the names starting with a prefix: variables, builtins,
+// and keywords, or for `x.`, the methods of x and the keys of x if it's a
+// hash.
+func (r *repl) complete(prefix string) []string {
+	seen := map[string]bool{}
+	names := []string{}
+	add := func(name string) {
+		if strings.HasPrefix(name, prefix) && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for env := r.env; env != nil; env = env.Outer() {
+		for name := range env.Vars() {
+			add(name)
+		}
+	}
+	for _, name := range evaluator.BuiltinNames() {
+		add(name)
+	}
+	for _, k := range keywords {
+		add(k)
+	}
+
+	if i := strings.LastIndex(prefix, "."); i > 0 {
+		if obj, ok := r.env.Get(prefix[:i]); ok {
+			for _, m := range members(obj, r.env) {
+				add(prefix[:i+1] + m)
+			}
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// members returns the methods of a value, and its keys if it's a hash.
+func members(obj object.Object, env *object.Environment) []string {
+	names := []string{}
+	if fn := obj.GetMethod("methods"); fn != nil {
+		if methods, ok := fn(env).(*object.Array); ok {
+			for _, m := range methods.Elements {
+				if s, ok := m.(*object.String); ok {
+					names = append(names, s.Value)
+				}
+			}
+		}
+	}
+	if h, ok := obj.(*object.Hash); ok {
+		for _, pair := range h.Pairs {
+			if s, ok := pair.Key.(*object.String); ok {
+				names = append(names, s.Value)
+			}
+		}
+	}
+	return names
+}
+
+// isNameChar is true for the characters the lexer allows in identifiers,
+// including the periods in namespaced names.
+func isNameChar(r rune) bool {
+	return unicode.IsLetter(r) ||
+		unicode.IsDigit(r) ||
+		r == '.' ||
+		r == '?' ||
+		r == '$' ||
+		r == '_'
+}
@@ -0,0 +1,69 @@
+package repl
+
+import (
+	"github.com/zautumnz/keai/lexer"
+	"github.com/zautumnz/keai/token"
+)
+
+// ANSI colors for highlighting
+const (
+	colorReset   = "\x1b[0m"
+	colorKeyword = "\x1b[35m"
+	colorString  = "\x1b[32m"
+	colorNumber  = "\x1b[33m"
+	colorComment = "\x1b[90m"
+)
+
+// highlighter colors the line being typed.
+type highlighter struct{}
+
+// Paint returns the line with its keywords, strings, numbers, and comments
+// colored.
+func (highlighter) Paint(line []rune, _ int) []rune {
+	colors := make([]string, len(line))
+	color := func(tok token.Token, c string) {
+		if tok.Line != 1 {
+			return
+		}
+		end := tok.EndColumn - 1
+		if tok.EndLine != 1 || end > len(line) {
+			end = len(line)
+		}
+		for i := tok.Column - 1; i < end; i++ {
+			colors[i] = c
+		}
+	}
+
+	l := lexer.New(string(line))
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch {
+		case tok.Type == token.STRING || tok.Type == token.DOCSTRING:
+			color(tok, colorString)
+		case tok.Type == token.INT || tok.Type == token.FLOAT:
+			color(tok, colorNumber)
+		case tok.Type != token.IDENT &&
+			token.LookupIdentifier(tok.Literal) == tok.Type:
+			color(tok, colorKeyword)
+		}
+	}
+	for _, tok := range l.Comments() {
+		color(tok, colorComment)
+	}
+
+	painted := []rune{}
+	current := ""
+	for i, r := range line {
+		if colors[i] != current {
+			if current != "" {
+				painted = append(painted, []rune(colorReset)...)
+			}
+			painted = append(painted, []rune(colors[i])...)
+			current = colors[i]
+		}
+		painted = append(painted, r)
+	}
+	if current != "" {
+		painted = append(painted, []rune(colorReset)...)
+	}
+	return painted
+}
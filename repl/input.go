@@ -0,0 +1,39 @@
+package repl
+
+// balanced returns false if the input has an unclosed bracket, brace,
+// paren, or string, so the REPL should keep reading lines.
+func balanced(input string) bool {
+	open := 0
+	var quote rune
+	escaped := false
+	comment := false
+
+	for _, r := range input {
+		switch {
+		case comment:
+			comment = r != '\n'
+		case quote != 0:
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == quote:
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			comment = true
+		case r == '(' || r == '[' || r == '{':
+			open++
+		case r == ')' || r == ']' || r == '}':
+			// too many closing brackets is a parse error, not something
+			// more lines can fix
+			if open > 0 {
+				open--
+			}
+		}
+	}
+	return open == 0 && quote == 0
+}
@@ -0,0 +1,89 @@
+package repl
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/zautumnz/keai/object"
+)
+
+// width is how long a line of output can be before arrays and hashes are
+// split over several.
+const width = 80
+
+// flat formats a value on one line, like Inspect but with hash keys
+// sorted.
+func flat(obj object.Object) string {
+	switch obj := obj.(type) {
+	case *object.Array:
+		elements := []string{}
+		for _, e := range obj.Elements {
+			elements = append(elements, flat(e))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *object.Hash:
+		pairs := []string{}
+		for _, pair := range sortedPairs(obj) {
+			pairs = append(pairs, pair.Key.Inspect()+": "+flat(pair.Value))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	}
+	return obj.Inspect()
+}
+
+// pretty formats a value for the REPL, splitting arrays and hashes that
+// don't fit on a line over several, one element per line. indent is how
+// far the line the value is on is indented, and column is where on it the
+// value starts.
+func pretty(obj object.Object, indent, column int) string {
+	s := flat(obj)
+	if column+utf8.RuneCountInString(s) <= width {
+		return s
+	}
+
+	inner := strings.Repeat(" ", indent+4)
+	lines := []string{}
+	switch obj := obj.(type) {
+	case *object.Array:
+		if len(obj.Elements) == 0 {
+			return s
+		}
+		for _, e := range obj.Elements {
+			lines = append(lines, inner+pretty(e, indent+4, indent+4)+",")
+		}
+		s = "["
+	case *object.Hash:
+		if len(obj.Pairs) == 0 {
+			return s
+		}
+		for _, pair := range sortedPairs(obj) {
+			key := pair.Key.Inspect() + ": "
+			column := indent + 4 + utf8.RuneCountInString(key)
+			value := pretty(pair.Value, indent+4, column)
+			lines = append(lines, inner+key+value+",")
+		}
+		s = "{"
+	default:
+		return s
+	}
+
+	end := "]"
+	if s == "{" {
+		end = "}"
+	}
+	return s + "\n" + strings.Join(lines, "\n") + "\n" +
+		strings.Repeat(" ", indent) + end
+}
+
+// sortedPairs returns the pairs of a hash, sorted by key.
+func sortedPairs(h *object.Hash) []object.HashPair {
+	pairs := []object.HashPair{}
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Key.Inspect() < pairs[j].Key.Inspect()
+	})
+	return pairs
+}
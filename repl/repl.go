@@ -6,10 +6,13 @@ import (
 	"io/ioutil"
 	"os"
 	"os/user"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/chzyer/readline"
+	"github.com/zautumnz/keai/doc"
 	"github.com/zautumnz/keai/evaluator"
 	"github.com/zautumnz/keai/lexer"
 	"github.com/zautumnz/keai/object"
@@ -59,60 +62,239 @@ func getInitFile() string {
 	return string(s)
 }
 
-// Start runs the REPL
-func Start(in io.Reader, out io.Writer, stdlib string) {
-	// set so we don't os.Exit on errors
-	utils.SetReplOrRun(true)
-	env := object.NewEnvironment()
+// repl holds the state of a REPL session.
+type repl struct {
+	env    *object.Environment
+	out    io.Writer
+	stdlib string
+
+	// initial is what was defined before anything was typed, so :env
+	// can leave it out
+	initial map[string]bool
+}
+
+func newREPL(out io.Writer, stdlib string) *repl {
+	r := &repl{out: out, stdlib: stdlib}
+	r.reset()
+	return r
+}
+
+// reset starts over with just the stdlib and init file.
+func (r *repl) reset() {
+	r.env = object.NewEnvironment()
 
 	// set up initial program with stdlib and optional init file
 	initConfig := getInitFile()
-	initLex := lexer.New(stdlib + "\n" + initConfig + "\n")
+	initLex := lexer.New(r.stdlib + "\n" + initConfig + "\n")
 	initPars := parser.New(initLex)
 	initProg := initPars.ParseProgram()
 	// put the initial program in the env
-	evaluator.Eval(initProg, env)
+	evaluator.Eval(initProg, r.env)
+
+	r.initial = map[string]bool{}
+	for name := range r.env.Vars() {
+		r.initial[name] = true
+	}
+}
+
+// metaCommand is a REPL command starting with a colon.
+type metaCommand struct {
+	name  string
+	usage string
+	desc  string
+	run   func(r *repl, arg string)
+}
+
+var metaCommands []metaCommand
+
+func init() {
+	metaCommands = []metaCommand{
+		{"doc", ":doc x", "show the signature and docstring of x", docMeta},
+		{"type", ":type x", "show the type of x", typeMeta},
+		{"load", ":load file", "run a file in this session", loadMeta},
+		{"reset", ":reset", "forget everything that's been defined",
+			func(r *repl, _ string) { r.reset() }},
+		{"env", ":env", "list what's been defined", envMeta},
+		{"time", ":time expr", "evaluate expr and show how long it took",
+			timeMeta},
+		{"help", ":help", "list these commands", helpMeta},
+	}
+}
+
+// run evaluates a meta-command or keai code.
+func (r *repl) run(input string) {
+	input = strings.TrimSpace(input)
+	if strings.HasPrefix(input, ":") {
+		name, arg, _ := strings.Cut(input[1:], " ")
+		arg = strings.TrimSpace(arg)
+		for _, m := range metaCommands {
+			if m.name == name {
+				m.run(r, arg)
+				return
+			}
+		}
+		fmt.Fprintf(r.out, "Unknown command :%s, try :help\n", name)
+		return
+	}
+
+	if evaluated := r.eval(input); evaluated != nil {
+		io.WriteString(r.out, pretty(evaluated, 0, 0))
+		io.WriteString(r.out, "\n")
+	}
+}
+
+// eval evaluates code, printing any parse errors.
+func (r *repl) eval(input string) object.Object {
+	lex := lexer.New(input)
+	p := parser.New(lex)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		parser.PrintParserErrors(
+			parser.ParserErrorsParams{Errors: p.Errors(), Out: r.out},
+		)
+		return nil
+	}
+	return evaluator.Eval(program, r.env)
+}
+
+func docMeta(r *repl, arg string) {
+	switch fn := r.eval(arg).(type) {
+	case *object.Function:
+		docstring := ""
+		if fn.DocString != nil {
+			docstring = fn.DocString.Value
+		}
+		name := fn.Name
+		if name == "" {
+			name = arg
+		}
+		e := doc.Function(name, fn.Parameters, fn.Defaults, docstring)
+		fmt.Fprintln(r.out, e.Signature())
+		if e.Doc != "" {
+			fmt.Fprintln(r.out, e.Doc)
+		}
+	case *object.Builtin:
+		if fn.Doc == "" {
+			fmt.Fprintf(r.out, "%s has no docstring\n", arg)
+		} else {
+			fmt.Fprintln(r.out, fn.Doc)
+		}
+	case *object.Error:
+		fmt.Fprintln(r.out, fn.Inspect())
+	case nil:
+	default:
+		fmt.Fprintf(r.out, "%s isn't a function\n", arg)
+	}
+}
 
-	l, err := readline.NewEx(&readline.Config{
+func typeMeta(r *repl, arg string) {
+	if arg == "" {
+		fmt.Fprintln(r.out, "Usage: :type x")
+		return
+	}
+	if t := r.eval("util.type(" + arg + ")"); t != nil {
+		if s, ok := t.(*object.String); ok {
+			fmt.Fprintln(r.out, s.Value)
+		} else {
+			fmt.Fprintln(r.out, t.Inspect())
+		}
+	}
+}
+
+func loadMeta(r *repl, arg string) {
+	src, err := ioutil.ReadFile(arg)
+	if err != nil {
+		fmt.Fprintln(r.out, err)
+		return
+	}
+	if err, ok := r.eval(string(src)).(*object.Error); ok {
+		fmt.Fprintln(r.out, err.Inspect())
+	}
+}
+
+func envMeta(r *repl, _ string) {
+	vars := r.env.Vars()
+	names := []string{}
+	for name := range vars {
+		if !r.initial[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := flat(vars[name])
+		if _, ok := vars[name].(*object.Function); ok {
+			value = "fn"
+		} else if len(value) > width-len(name)-3 {
+			value = value[:width-len(name)-6] + "..."
+		}
+		fmt.Fprintf(r.out, "%s = %s\n", name, value)
+	}
+}
+
+func timeMeta(r *repl, arg string) {
+	start := time.Now()
+	evaluated := r.eval(arg)
+	elapsed := time.Since(start)
+	if evaluated != nil {
+		fmt.Fprintln(r.out, pretty(evaluated, 0, 0))
+	}
+	fmt.Fprintf(r.out, "took %s\n", elapsed.Round(time.Microsecond))
+}
+
+func helpMeta(r *repl, _ string) {
+	for _, m := range metaCommands {
+		fmt.Fprintf(r.out, "  %-12s %s\n", m.usage, m.desc)
+	}
+}
+
+// Start runs the REPL
+func Start(in io.Reader, out io.Writer, stdlib string) {
+	// set so we don't os.Exit on errors
+	utils.SetReplOrRun(true)
+	r := newREPL(out, stdlib)
+
+	config := &readline.Config{
 		Prompt:            "> ",
 		HistoryFile:       getHomeBasedFile(".keai_history"),
 		InterruptPrompt:   "^C",
 		EOFPrompt:         "exit",
 		HistorySearchFold: true,
 		HistoryLimit:      getHistorySize(),
-	})
+		AutoComplete:      completer{r},
+	}
+	if utils.IsTerminal(os.Stdout) && os.Getenv("NO_COLOR") == "" {
+		config.Painter = highlighter{}
+	}
+	l, err := readline.NewEx(config)
 
 	if err != nil {
 		panic(err)
 	}
 	defer l.Close()
 
+	// input holds the lines so far of code that isn't finished
+	input := ""
 	for {
 		line, err := l.Readline()
 		if err == readline.ErrInterrupt {
-			if len(line) == 0 {
+			if len(line) == 0 && input == "" {
 				break
-			} else {
-				continue
 			}
+			input = ""
+			l.SetPrompt("> ")
+			continue
 		} else if err == io.EOF {
 			break
 		}
 
-		line = strings.TrimSpace(line)
-		lex := lexer.New(line)
-		p := parser.New(lex)
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			parser.PrintParserErrors(
-				parser.ParserErrorsParams{Errors: p.Errors(), Out: out},
-			)
+		input += line + "\n"
+		if !strings.HasPrefix(input, ":") && !balanced(input) {
+			l.SetPrompt("... ")
 			continue
 		}
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
-		}
+		l.SetPrompt("> ")
+		r.run(input)
+		input = ""
 	}
 }
@@ -2,6 +2,9 @@ package repl
 
 import (
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -16,3 +19,139 @@ func TestGetHistorySize(t *testing.T) {
 		t.Fatalf("excpted history size to be 500")
 	}
 }
+
+func TestBalanced(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let a = 1", true},
+		{"let f = fn(x) {", false},
+		{"let f = fn(x) {\nx\n}", true},
+		{"[1, 2,", false},
+		{"\"a {\"", true},
+		{"\"a \\\" b", false},
+		{"'docs", false},
+		{"# an open { in a comment", true},
+		{"1 }", true},
+	}
+	for _, tt := range tests {
+		if balanced(tt.input) != tt.expected {
+			t.Errorf("balanced(%q) should be %t", tt.input, tt.expected)
+		}
+	}
+}
+
+func newTestREPL(t *testing.T) (*repl, *strings.Builder) {
+	t.Setenv("HOME", t.TempDir())
+	out := &strings.Builder{}
+	return newREPL(out, "let std = 1\n"), out
+}
+
+func TestMetaCommands(t *testing.T) {
+	r, out := newTestREPL(t)
+	path := filepath.Join(t.TempDir(), "lib.keai")
+	os.WriteFile(path, []byte("let double = fn(x, by=2) {\n"+
+		"    'Doubles x.\n    Or multiplies it by by.'\n"+
+		"    x * by\n}\n"), 0644)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = 1\n", "1\n"},
+		{":type a", "integer\n"},
+		{":load " + path, ""},
+		{":doc double", "double(x, by = 2)\nDoubles x.\nOr multiplies it by by.\n"},
+		{":doc a", "a isn't a function\n"},
+		{":env", "a = 1\ndouble = fn\n"},
+		{"double(a)", "2\n"},
+		{":nope", "Unknown command :nope, try :help\n"},
+		{":reset", ""},
+		{":env", ""},
+		{"std", "1\n"},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		r.run(tt.input)
+		if out.String() != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected,
+				out.String())
+		}
+	}
+
+	out.Reset()
+	r.run(":time 1 + 1")
+	if !strings.HasPrefix(out.String(), "2\ntook ") {
+		t.Errorf(":time: got %q", out.String())
+	}
+}
+
+func TestComplete(t *testing.T) {
+	r, _ := newTestREPL(t)
+	r.run("let person = {\"name\": \"k\", \"age\": 3}")
+
+	tests := []struct {
+		line     string
+		expected []string
+	}{
+		{"per", []string{"son"}},
+		{"util.ty", []string{"pe"}},
+		{"person.na", []string{"me"}},
+		{"fore", []string{"ach"}},
+		{":re", []string{"set"}},
+	}
+	for _, tt := range tests {
+		line := []rune(tt.line)
+		got, _ := completer{r}.Do(line, len(line))
+		strs := []string{}
+		for _, s := range got {
+			strs = append(strs, string(s))
+		}
+		if !reflect.DeepEqual(strs, tt.expected) {
+			t.Errorf("%q: expected %v, got %v", tt.line, tt.expected, strs)
+		}
+	}
+
+	r.run("let s = \"x\"")
+	got := r.complete("s.")
+	if len(got) == 0 || !strings.HasPrefix(got[0], "s.") {
+		t.Errorf("expected string methods, got %v", got)
+	}
+}
+
+func TestPretty(t *testing.T) {
+	r, out := newTestREPL(t)
+	r.run("{\"b\": [1, 2], \"a\": 1}")
+	if out.String() != "{a: 1, b: [1, 2]}\n" {
+		t.Errorf("got %q", out.String())
+	}
+
+	out.Reset()
+	long := strings.Repeat("x", 70)
+	r.run("{\"long\": \"" + long + "\", \"nested\": {\"list\": [\"" +
+		long + "\", 1]}, \"short\": [1]}")
+	expected := "{\n" +
+		"    long: " + long + ",\n" +
+		"    nested: {\n" +
+		"        list: [\n" +
+		"            " + long + ",\n" +
+		"            1,\n" +
+		"        ],\n" +
+		"    },\n" +
+		"    short: [1],\n" +
+		"}\n"
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	got := string(highlighter{}.Paint([]rune("let s = \"x\" # hi"), 0))
+	expected := colorKeyword + "let" + colorReset + " s = " +
+		colorString + "\"x\"" + colorReset + " " +
+		colorComment + "# hi" + colorReset
+	if got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
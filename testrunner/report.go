@@ -0,0 +1,239 @@
+package testrunner
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Formats are the report formats Write supports.
+var Formats = []string{"human", "tap", "junit"}
+
+// Write writes a report in one of Formats.
+func Write(w io.Writer, r *Report, format string) error {
+	switch format {
+	case "human":
+		writeHuman(w, r)
+	case "tap":
+		writeTAP(w, r)
+	case "junit":
+		return writeJUnit(w, r)
+	default:
+		return fmt.Errorf(
+			"unknown format '%s', expected one of: %s",
+			format,
+			strings.Join(Formats, ", "),
+		)
+	}
+	return nil
+}
+
+// ms formats a duration in milliseconds.
+func ms(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d.Microseconds())/1000)
+}
+
+// indent indents every line of s.
+func indent(s, prefix string) string {
+	s = strings.TrimRight(s, "\n")
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
+
+// labels start each line of the human format.
+var labels = map[Status]string{Pass: "ok", Fail: "FAIL", Skip: "skip"}
+
+// writeHuman prints a line per test, with the failures and output of the
+// tests that failed, followed by a summary.
+func writeHuman(w io.Writer, r *Report) {
+	for _, f := range r.Files {
+		if f.Err != "" {
+			fmt.Fprintf(w, "FAIL  %s (%s)\n", f.Path, ms(f.Duration))
+			fmt.Fprintln(w, indent(f.Err, "      "))
+			if f.Output != "" {
+				fmt.Fprintln(w, indent(f.Output, "      | "))
+			}
+			continue
+		}
+
+		for _, t := range f.Tests {
+			fmt.Fprintf(w, "%-5s %s > %s", labels[t.Status], f.Path, t.Name)
+			if t.Status != Skip {
+				fmt.Fprintf(w, " (%s)", ms(t.Duration))
+			}
+			fmt.Fprintln(w)
+
+			if t.Status == Fail {
+				// a failure that goes over more than one line, like a
+				// diff, is one bullet
+				for _, msg := range t.Failures {
+					fmt.Fprintln(w, "      - "+indent(msg, "        ")[8:])
+				}
+				if t.Output != "" {
+					fmt.Fprintln(w, indent(t.Output, "      | "))
+				}
+			}
+		}
+	}
+
+	fmt.Fprintf(
+		w,
+		"\n%d passed, %d failed, %d skipped",
+		r.Count(Pass),
+		r.Count(Fail),
+		r.Count(Skip),
+	)
+	if n := r.Errors(); n > 0 {
+		fmt.Fprintf(w, ", %d files with errors", n)
+	}
+	fmt.Fprintf(w, " (%s)\n", ms(r.Duration))
+}
+
+// writeTAP prints a TAP version 13 stream, with a test point per test.
+func writeTAP(w io.Writer, r *Report) {
+	fmt.Fprintln(w, "TAP version 13")
+
+	n := 0
+	for _, f := range r.Files {
+		fmt.Fprintf(w, "# %s\n", f.Path)
+		if f.Err != "" {
+			n++
+			fmt.Fprintf(w, "not ok %d - %s\n", n, f.Path)
+			writeYAML(w, []string{f.Err}, f.Output, f.Duration)
+			continue
+		}
+
+		for _, t := range f.Tests {
+			n++
+			switch t.Status {
+			case Pass:
+				fmt.Fprintf(w, "ok %d - %s\n", n, t.Name)
+			case Skip:
+				fmt.Fprintf(w, "ok %d - %s # SKIP\n", n, t.Name)
+			case Fail:
+				fmt.Fprintf(w, "not ok %d - %s\n", n, t.Name)
+				writeYAML(w, t.Failures, t.Output, t.Duration)
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "1..%d\n", n)
+}
+
+// writeYAML prints the YAML block describing a failed TAP test point.
+func writeYAML(
+	w io.Writer,
+	failures []string,
+	output string,
+	d time.Duration,
+) {
+	fmt.Fprintln(w, "  ---")
+	fmt.Fprintln(w, "  failures:")
+	for _, msg := range failures {
+		fmt.Fprintf(w, "    - %q\n", msg)
+	}
+	if output != "" {
+		fmt.Fprintln(w, "  output: |")
+		fmt.Fprintln(w, indent(output, "    "))
+	}
+	fmt.Fprintf(w, "  duration_ms: %.1f\n", float64(d.Microseconds())/1000)
+	fmt.Fprintln(w, "  ...")
+}
+
+// JUnit XML, as read by most CI servers.
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Errors    int         `xml:"errors,attr"`
+	Skipped   int         `xml:"skipped,attr"`
+	Time      string      `xml:"time,attr"`
+	Cases     []junitCase `xml:"testcase"`
+	Error     *junitIssue `xml:"error,omitempty"`
+	SystemOut string      `xml:"system-out,omitempty"`
+}
+
+type junitCase struct {
+	Name      string      `xml:"name,attr"`
+	Classname string      `xml:"classname,attr"`
+	Time      string      `xml:"time,attr"`
+	Failure   *junitIssue `xml:"failure,omitempty"`
+	Skipped   *struct{}   `xml:"skipped,omitempty"`
+	SystemOut string      `xml:"system-out,omitempty"`
+}
+
+type junitIssue struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// seconds formats a duration for JUnit.
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// writeJUnit prints a JUnit XML report, with a testsuite per file.
+func writeJUnit(w io.Writer, r *Report) error {
+	suites := junitSuites{
+		Tests:    r.Count(Pass) + r.Count(Fail) + r.Count(Skip),
+		Failures: r.Count(Fail),
+		Errors:   r.Errors(),
+		Skipped:  r.Count(Skip),
+		Time:     seconds(r.Duration),
+	}
+
+	for _, f := range r.Files {
+		s := junitSuite{
+			Name:     f.Path,
+			Tests:    len(f.Tests),
+			Failures: f.Count(Fail),
+			Skipped:  f.Count(Skip),
+			Time:     seconds(f.Duration),
+		}
+		if f.Err != "" {
+			s.Errors = 1
+			s.Error = &junitIssue{Message: "error loading file", Text: f.Err}
+			s.SystemOut = f.Output
+		}
+
+		for _, t := range f.Tests {
+			c := junitCase{
+				Name:      t.Name,
+				Classname: f.Path,
+				Time:      seconds(t.Duration),
+				SystemOut: t.Output,
+			}
+			switch t.Status {
+			case Fail:
+				c.Failure = &junitIssue{
+					Message: t.Failures[0],
+					Text:    strings.Join(t.Failures, "\n"),
+				}
+			case Skip:
+				c.Skipped = &struct{}{}
+			}
+			s.Cases = append(s.Cases, c)
+		}
+		suites.Suites = append(suites.Suites, s)
+	}
+
+	fmt.Fprint(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+	return nil
+}
@@ -0,0 +1,251 @@
+// Package testrunner implements keai test. It finds *_test.keai files,
+// loads each one into its own environment to collect the tests it
+// registers with core.test, then runs the tests one at a time and reports
+// the results.
+package testrunner
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/zautumnz/keai/evaluator"
+	"github.com/zautumnz/keai/lexer"
+	"github.com/zautumnz/keai/parser"
+	"github.com/zautumnz/keai/utils"
+)
+
+// Suffix marks test files.
+const Suffix = "_test.keai"
+
+// Status is the outcome of a test.
+type Status string
+
+// Test statuses
+const (
+	Pass Status = "pass"
+	Fail Status = "fail"
+	Skip Status = "skip"
+)
+
+// Options configures Run.
+type Options struct {
+	// Timeout stops a test that runs longer, if it's not zero
+	Timeout time.Duration
+}
+
+// Result is the result of one test.
+type Result struct {
+	Name       string
+	Status     Status
+	Assertions int
+	Failures   []string
+	Output     string
+	Duration   time.Duration
+}
+
+// FileResult is the result of a test file.
+type FileResult struct {
+	// Path is the test file
+	Path string
+
+	// Err is set if the file couldn't be loaded, in which case none of
+	// its tests ran
+	Err string
+
+	// Output is what the file printed while loading
+	Output string
+
+	Tests    []*Result
+	Duration time.Duration
+}
+
+// Failed returns true if the file or any of its tests failed.
+func (f *FileResult) Failed() bool {
+	return f.Err != "" || f.Count(Fail) > 0
+}
+
+// Count returns how many of the file's tests have a status.
+func (f *FileResult) Count(s Status) int {
+	n := 0
+	for _, t := range f.Tests {
+		if t.Status == s {
+			n++
+		}
+	}
+	return n
+}
+
+// Report holds the results of a run.
+type Report struct {
+	Files    []*FileResult
+	Duration time.Duration
+}
+
+// Failed returns true if anything failed.
+func (r *Report) Failed() bool {
+	for _, f := range r.Files {
+		if f.Failed() {
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns how many tests have a status.
+func (r *Report) Count(s Status) int {
+	n := 0
+	for _, f := range r.Files {
+		n += f.Count(s)
+	}
+	return n
+}
+
+// Errors returns how many files couldn't be loaded.
+func (r *Report) Errors() int {
+	n := 0
+	for _, f := range r.Files {
+		if f.Err != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Discover returns the test files in paths, sorted. Directories are
+// searched recursively, skipping hidden directories and keai_modules.
+func Discover(paths []string) ([]string, error) {
+	return utils.FindFiles(paths, Suffix)
+}
+
+// loaded is a test file whose tests have been collected.
+type loaded struct {
+	result *FileResult
+	tests  *evaluator.TestFile
+}
+
+// Run runs the tests in the given files. Every file is loaded before any
+// tests run, so core.test.only in one file skips the tests in the others.
+func Run(files []string, opts Options) *Report {
+	start := time.Now()
+	report := &Report{}
+
+	all := []*loaded{}
+	only := false
+	for _, path := range files {
+		l := load(path)
+		report.Files = append(report.Files, l.result)
+		if l.tests == nil {
+			continue
+		}
+		all = append(all, l)
+		for _, tc := range l.tests.Tests {
+			only = only || tc.Only
+		}
+	}
+
+	for _, l := range all {
+		for _, tc := range l.tests.Tests {
+			r := &Result{Name: tc.Name, Status: Skip}
+			if !tc.Skip && (!only || tc.Only) {
+				runTest(r, l.tests, tc, opts.Timeout)
+			}
+			l.result.Tests = append(l.result.Tests, r)
+			l.result.Duration += r.Duration
+		}
+	}
+
+	report.Duration = time.Since(start)
+	return report
+}
+
+// load parses and evaluates a test file in a new environment.
+func load(path string) *loaded {
+	start := time.Now()
+	l := &loaded{result: &FileResult{Path: path}}
+	defer func() {
+		l.result.Duration = time.Since(start)
+	}()
+
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		l.result.Err = err.Error()
+		return l
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		l.result.Err = "parse errors:\n" + strings.Join(p.Errors(), "\n")
+		return l
+	}
+	evaluator.Register(path, string(src), program)
+
+	evaluator.ResetModules()
+	l.result.Output = capture(func() {
+		env := evaluator.NewStdlibEnvironment()
+		l.tests, err = evaluator.CollectTests(program, env)
+	})
+	if err != nil {
+		l.result.Err = err.Error()
+	}
+	return l
+}
+
+// runTest runs a test, filling in its result.
+func runTest(
+	r *Result,
+	file *evaluator.TestFile,
+	tc *evaluator.TestCase,
+	timeout time.Duration,
+) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	var res *evaluator.TestResult
+	r.Output = capture(func() {
+		res = evaluator.RunTest(ctx, file, tc)
+	})
+	r.Duration = time.Since(start)
+
+	r.Assertions = res.Assertions
+	r.Failures = res.Failures
+	if ctx.Err() == context.DeadlineExceeded {
+		r.Failures = append(r.Failures, "timed out after "+timeout.String())
+	}
+	r.Status = Pass
+	if len(r.Failures) > 0 {
+		r.Status = Fail
+	}
+}
+
+// capture returns what f prints to STDOUT.
+func capture(f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		f()
+		return ""
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	out := make(chan string)
+	go func() {
+		b, _ := ioutil.ReadAll(r)
+		out <- string(b)
+	}()
+
+	f()
+	os.Stdout = stdout
+	w.Close()
+	s := <-out
+	r.Close()
+	return s
+}
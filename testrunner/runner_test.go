@@ -0,0 +1,201 @@
+package testrunner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFiles creates files (relative path -> contents) under dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a_test.keai":                    "",
+		"a.keai":                         "",
+		"lib/b_test.keai":                "",
+		".hidden/c_test.keai":            "",
+		"keai_modules/dep/d_test.keai":   "",
+		"keai_modules/dep/not_test.keai": "",
+	})
+
+	files, err := Discover([]string{dir, filepath.Join(dir, "a.keai")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		filepath.Join(dir, "a.keai"),
+		filepath.Join(dir, "a_test.keai"),
+		filepath.Join(dir, "lib/b_test.keai"),
+	}
+	if strings.Join(files, ",") != strings.Join(expected, ",") {
+		t.Errorf("wrong files. expected=%v, got=%v", expected, files)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"math_test.keai": `
+let add = fn (a, b) { a + b }
+core.test.before_each(fn () { print("before") })
+core.test.after_each(fn () { print("after") })
+core.test("passes", fn (t) { t(add(1, 2) == 3, "adds") })
+core.test("fails", fn (t) { t(add(1, 1) == 3, "one plus one is three") })
+core.test("crashes", fn (t) { let x = 1 + "a" })
+core.test("hangs", fn (t) { mutable i = 0; for (true) { i++ } })
+core.test("crashes in a generator", fn (t) {
+    let g = fn () { yield 1; 1 + "a" }
+    foreach x in g() {}
+})
+core.test.skip("skipped", fn (t) { t(false) })
+`,
+		"broken_test.keai": `let x = 1 + "a"`,
+	})
+
+	report := Run(
+		[]string{
+			filepath.Join(dir, "broken_test.keai"),
+			filepath.Join(dir, "math_test.keai"),
+		},
+		Options{Timeout: 50 * time.Millisecond},
+	)
+	if !report.Failed() {
+		t.Errorf("expected the run to fail")
+	}
+	if report.Errors() != 1 || report.Files[0].Err == "" {
+		t.Errorf("expected broken_test.keai to fail to load")
+	}
+
+	tests := report.Files[1].Tests
+	expected := []struct {
+		name    string
+		status  Status
+		failure string
+	}{
+		{"passes", Pass, ""},
+		{"fails", Fail, "one plus one is three"},
+		{"crashes", Fail, "exited with code 1"},
+		{"hangs", Fail, "timed out"},
+		{"crashes in a generator", Fail, "exited with code 1"},
+		{"skipped", Skip, ""},
+	}
+	if len(tests) != len(expected) {
+		t.Fatalf("wrong number of tests. expected=%d, got=%d",
+			len(expected), len(tests))
+	}
+	for i, tt := range expected {
+		r := tests[i]
+		if r.Name != tt.name || r.Status != tt.status {
+			t.Errorf("test %d: expected %s %s, got %s %s",
+				i, tt.name, tt.status, r.Name, r.Status)
+		}
+		failures := strings.Join(r.Failures, "\n")
+		if !strings.Contains(failures, tt.failure) {
+			t.Errorf("test %s: expected failure %q, got %q",
+				r.Name, tt.failure, failures)
+		}
+	}
+	if tests[0].Output != "before \nafter \n" {
+		t.Errorf("hooks didn't run around the test. got=%q", tests[0].Output)
+	}
+}
+
+func TestRunOnly(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a_test.keai": `core.test("a", fn (t) { t(false) })`,
+		"b_test.keai": `core.test.only("b", fn (t) { t(true) })`,
+	})
+
+	report := Run(
+		[]string{
+			filepath.Join(dir, "a_test.keai"),
+			filepath.Join(dir, "b_test.keai"),
+		},
+		Options{},
+	)
+	if report.Failed() {
+		t.Errorf("expected only b to run")
+	}
+	if report.Count(Pass) != 1 || report.Count(Skip) != 1 {
+		t.Errorf("expected 1 pass and 1 skip, got %d and %d",
+			report.Count(Pass), report.Count(Skip))
+	}
+}
+
+func TestWrite(t *testing.T) {
+	report := &Report{
+		Files: []*FileResult{
+			{
+				Path: "a_test.keai",
+				Tests: []*Result{
+					{Name: "passes", Status: Pass},
+					{
+						Name:     "fails",
+						Status:   Fail,
+						Failures: []string{"nope", "two\nlines"},
+					},
+					{Name: "skipped", Status: Skip},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		format   string
+		expected []string
+	}{
+		{"human", []string{
+			"ok    a_test.keai > passes",
+			"FAIL  a_test.keai > fails",
+			"      - nope\n      - two\n        lines\n",
+			"1 passed, 1 failed, 1 skipped",
+		}},
+		{"tap", []string{
+			"TAP version 13",
+			"ok 1 - passes",
+			"not ok 2 - fails",
+			`    - "nope"`,
+			"ok 3 - skipped # SKIP",
+			"1..3",
+		}},
+		{"junit", []string{
+			`<testsuites tests="3" failures="1" errors="0" skipped="1"`,
+			`<testcase name="fails" classname="a_test.keai"`,
+			`<failure message="nope">nope&#xA;two&#xA;lines</failure>`,
+			`<skipped></skipped>`,
+		}},
+	}
+
+	for _, tt := range tests {
+		var out strings.Builder
+		if err := Write(&out, report, tt.format); err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range tt.expected {
+			if !strings.Contains(out.String(), line) {
+				t.Errorf("%s report is missing %q:\n%s",
+					tt.format, line, out.String())
+			}
+		}
+	}
+
+	if err := Write(ioutil.Discard, report, "xml"); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
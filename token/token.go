@@ -9,11 +9,20 @@ type Type string
 type Token struct {
 	Type    Type
 	Literal string
+
+	// Line and Column are where the token starts, counting from 1, and
+	// EndLine and EndColumn are just past where it ends; they're all 0 for
+	// tokens that weren't read from source
+	Line      int
+	Column    int
+	EndLine   int
+	EndColumn int
 }
 
 // pre-defined Type
 const (
 	AND             = "&&"
+	ARROW           = "=>"
 	ASSIGN          = "="
 	ASTERISK        = "*"
 	ASTERISK_EQUALS = "*="
@@ -24,11 +33,13 @@ const (
 	BIT_OR          = "|"
 	COLON           = ":"
 	COMMA           = ","
+	COMMENT         = "COMMENT"
 	CURRENT_ARGS    = "..."
 	DOCSTRING       = "DOCSTRING"
 	ELSE            = "ELSE"
 	EOF             = "EOF"
 	EQ              = "=="
+	EXPORT          = "EXPORT"
 	FALSE           = "FALSE"
 	FLOAT           = "FLOAT"
 	FOR             = "FOR"
@@ -50,6 +61,7 @@ const (
 	LPAREN          = "("
 	LT              = "<"
 	LT_EQUALS       = "<="
+	MATCH           = "MATCH"
 	MINUS           = "-"
 	MINUS_EQUALS    = "-="
 	MINUS_MINUS     = "--"
@@ -59,6 +71,7 @@ const (
 	NULL            = "null"
 	OR              = "||"
 	PERIOD          = "."
+	PIPE            = "|>"
 	PLUS            = "+"
 	PLUS_EQUALS     = "+="
 	PLUS_PLUS       = "++"
@@ -75,11 +88,19 @@ const (
 	SPREAD          = "...."
 	STRING          = "STRING"
 	TRUE            = "TRUE"
+	YIELD           = "YIELD"
+
+	// optional chaining, like a?.b, a?.[i], and f?.(x), and a ?? b
+	QUESTION_PERIOD   = "?."
+	QUESTION_LBRACKET = "?.["
+	QUESTION_LPAREN   = "?.("
+	QUESTION_QUESTION = "??"
 )
 
 // reversed keywords
 var keywords = map[string]Type{
 	"else":    ELSE,
+	"export":  EXPORT,
 	"false":   FALSE,
 	"fn":      FUNCTION,
 	"for":     FOR,
@@ -88,10 +109,12 @@ var keywords = map[string]Type{
 	"import":  IMPORT,
 	"in":      IN,
 	"let":     LET,
+	"match":   MATCH,
 	"mutable": MUTABLE,
 	"null":    NULL,
 	"return":  RETURN,
 	"true":    TRUE,
+	"yield":   YIELD,
 }
 
 // LookupIdentifier used to determinate whether identifier is keyword nor not
@@ -2,6 +2,9 @@ package utils
 
 import (
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
 // IsRepl is used by the repl and environment
@@ -19,9 +22,70 @@ func SetReplOrRun(rep bool) {
 	}
 }
 
+// ExitHandler, if set, is called by ExitConditionally instead of exiting.
+// keai test uses it to fail the running test rather than stopping every
+// test.
+var ExitHandler func(code int)
+
 // ExitConditionally exits only if we're not currently in a REPL
 func ExitConditionally(code int) {
-	if !IsRepl {
-		os.Exit(code)
+	if IsRepl {
+		return
+	}
+	if ExitHandler != nil {
+		ExitHandler(code)
+		return
+	}
+	os.Exit(code)
+}
+
+// IsTerminal returns true if f is a terminal rather than a file or pipe.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// FindFiles returns the files in paths ending with suffix, sorted. Files
+// named directly are always included. Directories are searched
+// recursively, skipping hidden directories and keai_modules.
+func FindFiles(paths []string, suffix string) ([]string, error) {
+	found := map[string]bool{}
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			found[p] = true
+			continue
+		}
+
+		walk := func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			name := info.Name()
+			if info.IsDir() {
+				if path != p &&
+					(strings.HasPrefix(name, ".") || name == "keai_modules") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasSuffix(name, suffix) {
+				found[path] = true
+			}
+			return nil
+		}
+		if err := filepath.Walk(p, walk); err != nil {
+			return nil, err
+		}
+	}
+
+	files := []string{}
+	for f := range found {
+		files = append(files, f)
 	}
+	sort.Strings(files)
+	return files, nil
 }
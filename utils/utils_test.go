@@ -19,3 +19,19 @@ func TestSetReplOrRun(t *testing.T) {
 		t.Errorf("IsRepl not set to false!")
 	}
 }
+
+func TestExitHandler(t *testing.T) {
+	got := -1
+	ExitHandler = func(code int) {
+		got = code
+	}
+	defer func() {
+		ExitHandler = nil
+	}()
+
+	ExitConditionally(3)
+
+	if got != 3 {
+		t.Errorf("ExitHandler not called with 3, got %d", got)
+	}
+}